@@ -0,0 +1,18 @@
+package activities
+
+import (
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// Activities are the activities of the executor workflow, they keep submissions, deployments and
+// deployment states in the repositories they are constructed with. A worker registers them all at
+// once, each exported method is registered under its own name.
+type Activities struct {
+	submissions db.SubmissionRepository
+	deployments db.DeploymentRepository
+	states      db.StateStore
+}
+
+func NewActivities(submissions db.SubmissionRepository, deployments db.DeploymentRepository, states db.StateStore) *Activities {
+	return &Activities{submissions: submissions, deployments: deployments, states: states}
+}
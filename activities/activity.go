@@ -3,7 +3,10 @@ package activities
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"github.com/sirupsen/logrus"
+	"github.com/surajsub/temporal-rest-dsl/executors"
 	"github.com/surajsub/temporal-rest-dsl/models"
 	"go.temporal.io/sdk/activity"
 	_ "go.temporal.io/sdk/workflow"
@@ -14,7 +17,7 @@ type WorkflowLogger struct {
 }
 
 // RunActivity /*
-func RunActivity(ctx context.Context, step models.Step) (map[string]any, error) {
+func (a *Activities) RunActivity(ctx context.Context, step models.Step) (map[string]any, error) {
 	logger := GetDSLActivityLogger(ctx)
 
 	logger.Infof("[********* Running activity: %s *********]", step.Activity)
@@ -29,10 +32,20 @@ func RunActivity(ctx context.Context, step models.Step) (map[string]any, error)
 	// ✅ Send a heartbeat so that Temporal UI updates the progress
 	activity.RecordHeartbeat(ctx, fmt.Sprintf("Executing step: %s (Activity: %s)", step.ID, step.Activity))
 
-	// This is the top level action in the yaml. We support only two actions
-	if step.Action == "create" || step.Action == "delete" {
+	// This is the top level action in the yaml
+	if step.Action == "create" || step.Action == "delete" || step.Action == "update" || step.Action == "plan" {
 		logger.Infof("Running: %s for: %s ", step.Activity, step.Resource)
-		output, err := deployResource(step, logger)
+		attempt := a.recordAttemptStart(ctx, step, logger)
+		logs := newStepLog(ctx, a.submissions, step, logger)
+		stopHeartbeat := keepHeartbeating(ctx, step, logs.Latest)
+		output, err := deployResource(ctx, step, logger, logs)
+		stopHeartbeat()
+		logs.Close()
+		if err != nil && ctx.Err() != nil {
+			// The command's own error is only the signal it was stopped with
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		a.recordAttemptEnd(attempt, step, err, logger)
 		if err != nil {
 			logger.Errorf("Error in deployResource: %v", err)
 
@@ -40,8 +53,41 @@ func RunActivity(ctx context.Context, step models.Step) (map[string]any, error)
 		}
 		return output, nil
 	} else {
-		logger.Infof("NO ACTION PROVIDED IN THE YAML FILE- HENCE IGNORING Running Update")
-		return nil, nil
+		logger.Errorf("Unsupported action %s for step %s", step.Action, step.ID)
+		return nil, executors.NewNonRetryableError(executors.ErrUnsupportedOperation, "unsupported action %s for step %s", step.Action, step.ID)
 	}
 
 }
+
+// heartbeatInterval is how often a step without a heartbeat_timeout heartbeats. Temporal only
+// delivers a cancellation to an activity when it heartbeats.
+const heartbeatInterval = 10 * time.Second
+
+// keepHeartbeating records a heartbeat until the returned function is called, at half the step's
+// heartbeat_timeout so long applies are not timed out while they make progress, and every
+// heartbeatInterval otherwise so a cancellation reaches the running command. The heartbeat
+// carries the latest line of output once there is one.
+func keepHeartbeating(ctx context.Context, step models.Step, latest func() string) func() {
+	interval := heartbeatInterval
+	if timeout := activity.GetInfo(ctx).HeartbeatTimeout; timeout > 0 {
+		interval = timeout / 2
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if line := latest(); line != "" {
+					activity.RecordHeartbeat(ctx, line)
+					continue
+				}
+				activity.RecordHeartbeat(ctx, fmt.Sprintf("Executing step: %s (Activity: %s)", step.ID, step.Activity))
+			}
+		}
+	}()
+	return func() { close(done) }
+}
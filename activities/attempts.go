@@ -0,0 +1,170 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/executors"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/activity"
+	"gorm.io/datatypes"
+)
+
+// Redacted replaces the value of variables that look like credentials wherever they are stored
+const Redacted = "***"
+
+// Variable names containing any of these are treated as secrets
+var secretVariableNames = []string{"password", "passwd", "secret", "token", "private_key", "access_key", "api_key", "credential", "conn_str"}
+
+// recordAttemptStart adds the attempt row for a run of the step and returns it, nil when it could
+// not be saved. The history is an audit trail, failing to write it never fails the step.
+func (a *Activities) recordAttemptStart(ctx context.Context, step models.Step, logger *logrus.Logger) *db.SubmissionStepAttempt {
+	variables, err := json.Marshal(RedactVariables(step.Variables))
+	if err != nil {
+		logger.Warnf("Failed to marshal variables of step %s for its attempt history: %v", step.ID, err)
+		variables = []byte("{}")
+	}
+	trigger := step.Trigger
+	if trigger == "" {
+		trigger = models.TriggerInitial
+	}
+	row := db.SubmissionStepAttempt{
+		ID:              uuid.New(),
+		SubmissionID:    step.SubmissionID,
+		StepID:          step.ID,
+		Attempt:         step.Attempt,
+		ActivityAttempt: activity.GetInfo(ctx).Attempt,
+		Action:          step.Action,
+		Variables:       datatypes.JSON(variables),
+		Trigger:         trigger,
+		TriggeredBy:     step.TriggeredBy,
+		Status:          db.StepRunning,
+		StartedAt:       time.Now(),
+	}
+	if err := a.submissions.CreateAttempt(ctx, &row); err != nil {
+		logger.Warnf("Failed to record attempt of step %s: %v", step.ID, err)
+		return nil
+	}
+	return &row
+}
+
+// recordAttemptEnd stores the outcome of the attempt. Commands echo their inputs in errors, so
+// the values of the step's secret variables are redacted from the error and stderr.
+func (a *Activities) recordAttemptEnd(attempt *db.SubmissionStepAttempt, step models.Step, runErr error, logger *logrus.Logger) {
+	if attempt == nil {
+		return
+	}
+	now := time.Now()
+	attempt.Status = db.StepSuccess
+	attempt.FinishedAt = &now
+	if runErr != nil {
+		attempt.Status = db.StepFailed
+		if errors.Is(runErr, context.Canceled) {
+			attempt.Status = db.StepCancelled
+		}
+		redact := secretReplacer(step.Variables)
+		attempt.Error = redact.Replace(runErr.Error())
+		attempt.Stderr = redact.Replace(executors.Stderr(runErr))
+	}
+	// The activity's context may already be done when it timed out, the outcome is still worth keeping
+	if err := a.submissions.FinishAttempt(context.Background(), attempt); err != nil {
+		logger.Warnf("Failed to record outcome of attempt %s: %v", attempt.ID, err)
+	}
+}
+
+// RedactVariables copies the variables with the values of secret looking names replaced
+func RedactVariables(variables map[string]any) map[string]any {
+	redactedVars := make(map[string]any, len(variables))
+	for key, value := range variables {
+		switch {
+		case isSecretName(key):
+			redactedVars[key] = Redacted
+		default:
+			redactedVars[key] = redactValue(value)
+		}
+	}
+	return redactedVars
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return RedactVariables(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretVariableNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSecrets replaces the values of the secret looking variables wherever they appear in text,
+// such as an error that quotes a connection string
+func RedactSecrets(text string, variables map[string]any) string {
+	return secretReplacer(variables).Replace(text)
+}
+
+// secretReplacer replaces the values of the secret looking variables with Redacted. Longer values
+// go first so a secret that contains another one is replaced whole.
+func secretReplacer(variables map[string]any) *strings.Replacer {
+	found := map[string]bool{}
+	collectSecrets(variables, false, found)
+	values := make([]string, 0, len(found))
+	for value := range found {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, Redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// collectSecrets adds the values under secret looking names to found, secret is set below one
+func collectSecrets(value any, secret bool, found map[string]bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			collectSecrets(item, secret || isSecretName(key), found)
+		}
+	case []any:
+		for _, item := range v {
+			collectSecrets(item, secret, found)
+		}
+	case string:
+		if secret && v != "" && v != Redacted {
+			found[v] = true
+		}
+	case nil, bool:
+	default:
+		if secret {
+			found[fmt.Sprint(v)] = true
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	variables := map[string]any{
+		"region":      "eu-1",
+		"db_password": "hunter2",
+		"api_key":     "hunter2-extended",
+		"pin_secret":  float64(4242),
+		"credentials": map[string]any{"user": "admin", "key": "AKIA123"},
+		"databases":   []any{map[string]any{"name": "main", "conn_str": "host=db password=pw1"}},
+		"token":       "",
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"no secrets", "apply failed in eu-1", "apply failed in eu-1"},
+		{"secret value", "auth failed for hunter2", "auth failed for ***"},
+		{"longer secret first", "key hunter2-extended and hunter2", "key *** and ***"},
+		{"number", "pin 4242 rejected", "pin *** rejected"},
+		{"everything under a secret name", "admin AKIA123", "*** ***"},
+		{"nested in a list", "could not connect to host=db password=pw1", "could not connect to ***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RedactSecrets(tt.text, variables))
+		})
+	}
+	assert.Equal(t, "nothing to hide", RedactSecrets("nothing to hide", nil))
+}
+
+func TestRedactVariables(t *testing.T) {
+	redacted := RedactVariables(map[string]any{
+		"region":   "eu-1",
+		"password": "hunter2",
+		"nested":   map[string]any{"secret_id": "s-1", "zone": "a"},
+		"list":     []any{map[string]any{"token": "t-1"}, "plain"},
+	})
+
+	assert.Equal(t, map[string]any{
+		"region":   "eu-1",
+		"password": Redacted,
+		"nested":   map[string]any{"secret_id": Redacted, "zone": "a"},
+		"list":     []any{map[string]any{"token": Redacted}, "plain"},
+	}, redacted)
+}
@@ -3,61 +3,188 @@ package activities
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/surajsub/temporal-rest-dsl/db"
 	"github.com/surajsub/temporal-rest-dsl/models"
-	"log"
+	"go.temporal.io/sdk/temporal"
+	"gorm.io/datatypes"
 	"time"
 )
 
-// DBActivity  /*
-func DBActivity(ctx context.Context, step models.Step, submission string, activity string, status string, result map[string]any) (map[string]any, error) {
-	logger := GetDSLActivityLogger(ctx)
-
-	logger.Infof("[********* Running activity: %s ********* with result %v]", activity, result)
-
-	logger.Infof("Executing  %s for %s in workspace %s with variables: %v", step.Operation, step.Resource, step.Workspace, step.Variables)
+// ErrInvalidStepTransition is the error type of a status update the step's current status does
+// not allow
+const ErrInvalidStepTransition = "InvalidStepTransition"
 
-	logger.Infof("[******* Running the DB Activity %s ********]", step.Activity)
+// StepStatusUpdate is a change of a step's status in submission_steps
+type StepStatusUpdate struct {
+	Status string         `json:"status"`
+	Result map[string]any `json:"result,omitempty"`
+	// Error is the message of the failure that led to the status
+	Error string `json:"error,omitempty"`
+	// Attempt is the number of the run of the step, retries signalled by an operator count up
+	Attempt int `json:"attempt,omitempty"`
+}
 
-	logger.Infof("[******Update SUBMISSIONS set status= %s where SUBMISSION_ID= %s and step_id=%s ]", status, submission, "COMPLETE")
+// DBActivity records a step's status with its result. The update is rejected without retrying
+// when the step's current status does not allow the transition, and fails when another update
+// got there first so Temporal retries it against the new status.
+func (a *Activities) DBActivity(ctx context.Context, step models.Step, submission string, update StepStatusUpdate) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Setting step %s of submission %s to %s", step.ID, submission, update.Status)
 
-	// Call the deployResource function and get the output
-	//return nil, nil
+	row, err := a.submissions.GetStep(ctx, submission, step.ID)
+	if errors.Is(err, db.ErrStepNotFound) {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("step %s not found in submission %s", step.ID, submission), ErrInvalidStepTransition, err)
+	}
+	if err != nil {
+		return err
+	}
+	if !db.ValidStepTransition(row.Status, update.Status) {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("step %s can not move from %s to %s", step.ID, row.Status, update.Status), ErrInvalidStepTransition, nil)
+	}
 
+	result := update.Result
 	if result == nil {
-		logger.Debugf("Result is nil so it's an INSERT ************")
+		result = map[string]any{}
+	}
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result for step %s: %w", step.ID, err)
+	}
+	// Anyone following the submission sees the step_output event, so outputs named like secrets
+	// and the values of the step's secret variables are redacted from it
+	output, err := json.Marshal(RedactVariables(result))
+	if err != nil {
+		return fmt.Errorf("failed to marshal output for step %s: %w", step.ID, err)
+	}
+
+	from := row.Status
+	now := time.Now()
+	redact := secretReplacer(step.Variables)
+	row.Status = update.Status
+	row.StepResult = datatypes.JSON(jsonResult)
+	row.ErrorMessage = redact.Replace(update.Error)
+	row.LastUpdatedAt = now
+	if update.Attempt > 0 {
+		row.Attempt = update.Attempt
+	}
+	if row.StartedAt == nil && update.Status != db.StepSkipped {
+		row.StartedAt = &now
+	}
+	if db.StepFinished(update.Status) {
+		row.FinishedAt = &now
+	} else {
+		row.FinishedAt = nil
 	}
-	ctx = context.Background()
-	conn := db.NewPostgresManager() // Update
 
-	var outputResult string
-	if result != nil {
-		log.Printf("result: %v", result)
+	err = a.submissions.UpdateStep(ctx, row, from)
+	if errors.Is(err, db.ErrStepChanged) {
+		return fmt.Errorf("step %s changed from %s while it was being updated", step.ID, from)
 	}
-	// Convert map to json string
-	jsonStr, err := json.Marshal(result)
 	if err != nil {
-		fmt.Println(err)
-	}
-	outputResult = string(jsonStr)
-	err = conn.Update(ctx, "submission_steps",
-		map[string]any{ // SET clause
-			"status":          "SUCCESS",
-			"last_updated_at": time.Now(),
-
-			"step_result": outputResult,
-		},
-		map[string]any{ // WHERE clause
-			"provider":      step.Provider,
-			"submission_id": submission,
-			"step_id":       step.ID,
+		logger.Errorf("Failed to update step %s: %v", step.ID, err)
+		return err
+	}
+
+	a.recordEvent(ctx, &db.SubmissionEvent{
+		SubmissionID: submission,
+		StepID:       step.ID,
+		Type:         db.EventStepStatus,
+		Status:       update.Status,
+		Attempt:      row.Attempt,
+		Message:      row.ErrorMessage,
+	})
+	if update.Status == db.StepSuccess && len(update.Result) > 0 {
+		a.recordEvent(ctx, &db.SubmissionEvent{
+			SubmissionID: submission,
+			StepID:       step.ID,
+			Type:         db.EventStepOutput,
+			Status:       update.Status,
+			Attempt:      row.Attempt,
+			Data:         datatypes.JSON(redact.Replace(string(output))),
 		})
+	}
+	return nil
+}
 
+// SubmissionStatusActivity records the overall status of a submission
+func (a *Activities) SubmissionStatusActivity(ctx context.Context, submission string, status string) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("[******Update SUBMISSIONS set status= %s where ID= %s ]", status, submission)
+
+	// The event goes first, a stream that sees the submission finished has then already got it
+	a.recordEvent(ctx, &db.SubmissionEvent{SubmissionID: submission, Type: db.EventSubmissionStatus, Status: status})
+	if err := a.submissions.UpdateSubmissionStatus(ctx, submission, status); err != nil {
+		logger.Errorf("Failed to update submission status %v", err)
+		return err
+	}
+	return nil
+}
+
+// CancelStepsActivity marks every step of a cancelled submission that has not finished, and
+// every failed step still waiting on an operator, CANCELLED
+func (a *Activities) CancelStepsActivity(ctx context.Context, submission string, reason string) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Cancelling the remaining steps of submission %s", submission)
+
+	cancelled, err := a.submissions.CancelSteps(ctx, submission, reason)
 	if err != nil {
-		logger.Errorf("Failed to perform the update %v", err)
+		logger.Errorf("Failed to cancel steps of submission %s: %v", submission, err)
+		return err
+	}
+	for _, stepID := range cancelled {
+		a.recordEvent(ctx, &db.SubmissionEvent{SubmissionID: submission, StepID: stepID, Type: db.EventStepStatus, Status: db.StepCancelled, Message: reason})
+	}
+	logger.Infof("Cancelled %d steps of submission %s", len(cancelled), submission)
+	return nil
+}
+
+// RecordEventActivity adds an event the workflow itself raises, such as a failed step waiting
+// for an operator, to the submission's event stream
+func (a *Activities) RecordEventActivity(ctx context.Context, event db.SubmissionEvent) error {
+	return a.submissions.AppendEvent(ctx, &event)
+}
+
+// recordEvent adds an event that goes with a change already saved. The change stands when the
+// event can not be saved, so the failure is only logged.
+func (a *Activities) recordEvent(ctx context.Context, event *db.SubmissionEvent) {
+	if err := a.submissions.AppendEvent(ctx, event); err != nil {
+		GetDSLActivityLogger(ctx).Errorf("Failed to record %s event of submission %s: %v", event.Type, event.SubmissionID, err)
 	}
+}
+
+// InsertStepActivity adds the submission_steps row for a step that only exists at runtime, such
+// as the sub-steps of a for_each step. It does nothing if the row is already there, so it is safe
+// to retry.
+func (a *Activities) InsertStepActivity(ctx context.Context, step models.Step, submission string) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Adding step %s to submission %s", step.ID, submission)
 
-	logger.Infof("the connection is %s", conn)
-	return nil, nil
+	jsonVars, err := json.Marshal(step.Variables)
+	if err != nil {
+		return fmt.Errorf("failed to marshal variables for step %s: %w", step.ID, err)
+	}
+	row := db.SubmissionStep{
+		ID:            uuid.New(),
+		SubmissionID:  submission,
+		StepID:        step.ID,
+		Type:          step.Type,
+		Provider:      step.Provider,
+		Executor:      step.Executor,
+		Resource:      step.Resource,
+		Workspace:     step.Workspace,
+		Operation:     step.Operation,
+		DependsOn:     step.DependsOn,
+		Variables:     datatypes.JSON(jsonVars),
+		Status:        db.StepPending,
+		LastUpdatedAt: time.Now(),
+		StepResult:    datatypes.JSON("{}"),
+	}
+	if err := a.submissions.InsertStep(ctx, &row); err != nil {
+		logger.Errorf("Failed to add step %s: %v", step.ID, err)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,117 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+)
+
+// memoryActivities returns activities over an in-memory repository holding a submission with one
+// step in the given status, the repository and the submission's ID
+func memoryActivities(t *testing.T, status string) (*Activities, *db.MemorySubmissionRepository, string) {
+	t.Helper()
+	repository := db.NewMemorySubmissionRepository()
+
+	submission := db.Submission{
+		Status: db.SubmissionRunning,
+		Steps:  []db.SubmissionStep{{StepID: "vpc", Status: status}},
+	}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+	return NewActivities(repository, db.NewMemoryDeploymentRepository(), nil), repository, submission.ID.String()
+}
+
+func TestDBActivityRejectsInvalidTransition(t *testing.T) {
+	a, repository, submission := memoryActivities(t, db.StepSuccess)
+	ctx := context.Background()
+
+	err := a.DBActivity(ctx, models.Step{ID: "vpc"}, submission, StepStatusUpdate{Status: db.StepRunning})
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr), "got %v", err)
+	assert.Equal(t, ErrInvalidStepTransition, appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+
+	step, err := repository.GetStep(ctx, submission, "vpc")
+	require.NoError(t, err)
+	assert.Equal(t, db.StepSuccess, step.Status, "a rejected update leaves the step alone")
+	events, err := repository.ListEvents(ctx, submission, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestDBActivityRejectsUnknownStep(t *testing.T) {
+	a, _, submission := memoryActivities(t, db.StepPending)
+
+	err := a.DBActivity(context.Background(), models.Step{ID: "subnet"}, submission, StepStatusUpdate{Status: db.StepStarted})
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr), "got %v", err)
+	assert.True(t, appErr.NonRetryable())
+}
+
+func TestDBActivityRecordsTransition(t *testing.T) {
+	a, repository, submission := memoryActivities(t, db.StepRunning)
+	ctx := context.Background()
+
+	err := a.DBActivity(ctx, models.Step{ID: "vpc"}, submission, StepStatusUpdate{
+		Status:  db.StepSuccess,
+		Result:  map[string]any{"vpc_id": "vpc-123"},
+		Attempt: 2,
+	})
+	require.NoError(t, err)
+
+	step, err := repository.GetStep(ctx, submission, "vpc")
+	require.NoError(t, err)
+	assert.Equal(t, db.StepSuccess, step.Status)
+	assert.Equal(t, 2, step.Attempt)
+	assert.JSONEq(t, `{"vpc_id": "vpc-123"}`, string(step.StepResult))
+	assert.NotNil(t, step.StartedAt)
+	assert.NotNil(t, step.FinishedAt)
+
+	events, err := repository.ListEvents(ctx, submission, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, db.EventStepStatus, events[0].Type)
+	assert.Equal(t, db.EventStepOutput, events[1].Type)
+	assert.Equal(t, []int64{1, 2}, []int64{events[0].Seq, events[1].Seq})
+}
+
+func TestDBActivityRedactsSecrets(t *testing.T) {
+	a, repository, submission := memoryActivities(t, db.StepRunning)
+	ctx := context.Background()
+	step := models.Step{ID: "vpc", Variables: map[string]any{"db_password": "hunter2", "region": "eu-1"}}
+
+	err := a.DBActivity(ctx, step, submission, StepStatusUpdate{
+		Status: db.StepSuccess,
+		Result: map[string]any{"dsn": "postgres://admin:hunter2@db", "admin_token": "t-1", "region": "eu-1"},
+	})
+	require.NoError(t, err)
+
+	events, err := repository.ListEvents(ctx, submission, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.JSONEq(t, `{"dsn": "postgres://admin:***@db", "admin_token": "***", "region": "eu-1"}`, string(events[1].Data))
+}
+
+func TestDBActivityRedactsSecretsInErrors(t *testing.T) {
+	a, repository, submission := memoryActivities(t, db.StepRunning)
+	ctx := context.Background()
+	step := models.Step{ID: "vpc", Variables: map[string]any{"db_password": "hunter2"}}
+
+	err := a.DBActivity(ctx, step, submission, StepStatusUpdate{Status: db.StepFailed, Error: "login with hunter2 failed"})
+	require.NoError(t, err)
+
+	row, err := repository.GetStep(ctx, submission, "vpc")
+	require.NoError(t, err)
+	assert.Equal(t, "login with *** failed", row.ErrorMessage)
+	events, err := repository.ListEvents(ctx, submission, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "login with *** failed", events[0].Message)
+}
@@ -0,0 +1,18 @@
+package activities
+
+import (
+	"context"
+
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// RecordDeploymentActivity creates the deployment a submission ran against or updates its status.
+// An empty status keeps the deployment's current one, a deployment first recorded without one
+// never became active and is FAILED. An empty definition keeps the one saved by the last create or
+// update.
+func (a *Activities) RecordDeploymentActivity(ctx context.Context, deployment db.Deployment) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Recording deployment %s as %q", deployment.Key(), deployment.Status)
+
+	return a.deployments.RecordDeployment(ctx, &deployment)
+}
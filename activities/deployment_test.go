@@ -0,0 +1,32 @@
+package activities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+func TestRecordDeploymentActivityKeepsStatusOfFailedUpdate(t *testing.T) {
+	deployments := db.NewMemoryDeploymentRepository()
+	a := NewActivities(db.NewMemorySubmissionRepository(), deployments, nil)
+	ctx := context.Background()
+	key := db.DeploymentKey{Account: "acme", Project: "network", DeploymentID: "prod"}
+	deployment := db.Deployment{Account: key.Account, Project: key.Project, DeploymentID: key.DeploymentID}
+
+	created := deployment
+	created.Status = db.DeploymentActive
+	created.Definition = []byte(`{}`)
+	require.NoError(t, a.RecordDeploymentActivity(ctx, created))
+
+	failed := deployment
+	failed.LastError = "step subnet failed"
+	require.NoError(t, a.RecordDeploymentActivity(ctx, failed))
+
+	recorded, err := deployments.FindDeployment(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, db.DeploymentActive, recorded.Status)
+	assert.Equal(t, "step subnet failed", recorded.LastError)
+}
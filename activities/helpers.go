@@ -14,13 +14,31 @@ import (
 This file will invoke the function to build the resources
 */
 
-func deployResource(step models.Step, logger *logrus.Logger) (map[string]any, error) {
+func deployResource(ctx context.Context, step models.Step, logger *logrus.Logger, logs executors.CommandOutput) (map[string]any, error) {
 
 	logger.Printf("[*********** In the DeployResource Function ***************]")
 	logger.Infof("Deploying resource for Cloud Provider: %s, Resource: %s for customer %s", step.Provider, step.Resource, step.Customer)
 
+	// Terraform style executors work on a private copy of the module
+	if executors.NeedsSandbox(step.Executor) && step.Workspace != "" {
+		sandbox, err := executors.NewSandbox(step, logger)
+		if err != nil {
+			return nil, err
+		}
+		step.Workspace = sandbox.Dir
+		output, err := executeStep(ctx, step, sandbox, logger, logs)
+		sandbox.Close(err == nil, logger)
+		return output, err
+	}
+	return executeStep(ctx, step, nil, logger, logs)
+}
+
+// executeStep runs the step's operation through its executor, the output of the commands it runs
+// goes to logs. The executor stops when ctx is done.
+func executeStep(ctx context.Context, step models.Step, sandbox *executors.Sandbox, logger *logrus.Logger, logs executors.CommandOutput) (map[string]any, error) {
+
 	// Initialize the executor
-	executor, err := initializeExecutor(step, logger)
+	executor, err := initializeExecutor(step, sandbox, logger, logs)
 	if err != nil {
 		return nil, err
 	}
@@ -29,21 +47,21 @@ func deployResource(step models.Step, logger *logrus.Logger) (map[string]any, er
 	err = executor.ValidateOperation(step)
 	if err != nil {
 		logger.Errorf("invalid operation for executor: %v", err)
-		return nil, err
+		return nil, executors.NewNonRetryableError(executors.ErrUnsupportedOperation, "invalid operation for executor %s: %v", step.Executor, err)
 	}
 
 	// Execute the operation
-	output, err := executor.Execute(step, step.Executor, step.Variables)
+	output, err := executor.Execute(ctx, step, step.Executor, step.Variables)
 	if err != nil {
 		logger.Errorf("Execution failed for %s/%s: %v", step.Action, step.Resource, err)
-		return nil, fmt.Errorf("error executing %s for %s: %v", step.Action, step.Resource, err)
+		return nil, executors.ActivityError(fmt.Errorf("error executing %s for %s: %w", step.Action, step.Resource, err))
 	}
 	logger.Infof("Resource %s for customer %s deployed successfully using Executor %s", step.Resource, step.Customer, step.Executor)
 	logger.Infof("Output: %v for Executor %s", output, step.Executor)
 	return output, nil
 }
 
-func initializeExecutor(step models.Step, logger *logrus.Logger) (executors.Executor, error) {
+func initializeExecutor(step models.Step, sandbox *executors.Sandbox, logger *logrus.Logger, logs executors.CommandOutput) (executors.Executor, error) {
 	// Prepare the configuration map from the step
 	logger.Infof(" Initializing executor for %s with the activity %s", step.Executor, step.Activity)
 	config := map[string]any{
@@ -60,6 +78,13 @@ func initializeExecutor(step models.Step, logger *logrus.Logger) (executors.Exec
 		"action":         step.Action,
 		"operation":      step.Operation,
 	}
+	if sandbox != nil {
+		config["backend_config"] = sandbox.BackendConfig
+		config["state_workspace"] = sandbox.StateWorkspace
+	}
+	if logs != nil {
+		config["output"] = logs
+	}
 
 	logger.Infof("in the intializeExecutor code with  %s and then %v", step.Executor, config)
 	// Fetch and initialize the executor using the registry
@@ -0,0 +1,128 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/activity"
+	"gorm.io/datatypes"
+)
+
+// A chunk of output is saved once it has logChunkLines lines or logFlushInterval passed
+const (
+	logChunkLines    = 100
+	logFlushInterval = time.Second
+)
+
+// stepLog collects the output of the commands a step runs and saves it in chunks tagged with the
+// submission, step and attempt. The latest line is sent as the activity's heartbeat so the
+// Temporal UI shows what the step is doing. The values of the step's secret variables are
+// redacted from every line.
+type stepLog struct {
+	ctx         context.Context
+	submissions db.SubmissionRepository
+	step        models.Step
+	logger      *logrus.Logger
+	redact      *strings.Replacer
+
+	mu      sync.Mutex
+	lines   []db.LogLine
+	latest  string
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newStepLog starts collecting the output of the step, Close saves what is left
+func newStepLog(ctx context.Context, submissions db.SubmissionRepository, step models.Step, logger *logrus.Logger) *stepLog {
+	l := &stepLog{
+		ctx:         ctx,
+		submissions: submissions,
+		step:        step,
+		logger:      logger,
+		redact:      secretReplacer(step.Variables),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				l.mu.Lock()
+				l.flush()
+				l.mu.Unlock()
+			}
+		}
+	}()
+	return l
+}
+
+// WriteLine implements executors.CommandOutput
+func (l *stepLog) WriteLine(stream, line string) {
+	line = l.redact.Replace(line)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, db.LogLine{Stream: stream, Text: line, Time: time.Now()})
+	if line != "" {
+		l.latest = line
+	}
+	if len(l.lines) >= logChunkLines {
+		l.flush()
+	}
+}
+
+// Latest returns the last non-empty line written so far
+func (l *stepLog) Latest() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.latest
+}
+
+// Close saves the lines not saved yet. The attempt is recorded as finished after this, so a
+// reader following the logs has every line once it sees the attempt end.
+func (l *stepLog) Close() {
+	close(l.done)
+	<-l.stopped
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flush()
+}
+
+// flush saves the pending lines as a chunk, the caller holds mu. The logs are not worth failing
+// the step over, so a chunk that can not be saved is dropped with a warning.
+func (l *stepLog) flush() {
+	if len(l.lines) == 0 {
+		return
+	}
+	lines, err := json.Marshal(l.lines)
+	if err != nil {
+		l.logger.Warnf("Failed to marshal output of step %s: %v", l.step.ID, err)
+		l.lines = nil
+		return
+	}
+	chunk := db.SubmissionStepLog{
+		SubmissionID:    l.step.SubmissionID,
+		StepID:          l.step.ID,
+		Attempt:         l.step.Attempt,
+		ActivityAttempt: activity.GetInfo(l.ctx).Attempt,
+		Lines:           datatypes.JSON(lines),
+	}
+	l.lines = nil
+	// The output of a cancelled or timed out run is what explains it, save it regardless
+	if err := l.submissions.AppendLogs(context.Background(), &chunk); err != nil {
+		l.logger.Warnf("Failed to save output of step %s: %v", l.step.ID, err)
+	}
+	activity.RecordHeartbeat(l.ctx, l.latest)
+}
@@ -0,0 +1,63 @@
+package activities
+
+import (
+	"context"
+	"errors"
+
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ErrStateConflict is the error type of a state write that lost the race with another
+// submission of the same deployment, retrying it would overwrite the other submission's outputs
+const ErrStateConflict = "StateConflict"
+
+// LoadStateActivity returns the saved state of a deployment. A deployment without state comes
+// back with version 0 so a create can save its first version.
+func (a *Activities) LoadStateActivity(ctx context.Context, key db.DeploymentKey) (db.DeploymentState, error) {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Loading state of deployment %s", key)
+
+	state, err := a.states.Load(ctx, key)
+	if errors.Is(err, db.ErrStateNotFound) {
+		logger.Infof("Deployment %s has no saved state", key)
+		return db.DeploymentState{Account: key.Account, Project: key.Project, DeploymentID: key.DeploymentID}, nil
+	}
+	if err != nil {
+		return db.DeploymentState{}, err
+	}
+	logger.Infof("Loaded version %d of deployment %s (%s)", state.Version, key, state.Status)
+	return *state, nil
+}
+
+// SaveStateActivity saves the step outputs as the next version of the deployment's state and
+// returns the new version. version is the one the workflow loaded.
+func (a *Activities) SaveStateActivity(ctx context.Context, key db.DeploymentKey, results map[string]map[string]any, version int, submission string) (int, error) {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Saving state of deployment %s over version %d", key, version)
+
+	state, err := a.states.Save(ctx, key, results, version, submission)
+	if err != nil {
+		return 0, stateError(key, err)
+	}
+	logger.Infof("State of deployment %s saved as version %d", key, state.Version)
+	return state.Version, nil
+}
+
+// DeleteStateActivity marks the deployment's state deleted once its resources are destroyed
+func (a *Activities) DeleteStateActivity(ctx context.Context, key db.DeploymentKey, version int, submission string) error {
+	logger := GetDSLActivityLogger(ctx)
+	logger.Infof("Deleting state of deployment %s at version %d", key, version)
+
+	if _, err := a.states.Delete(ctx, key, version, submission); err != nil {
+		return stateError(key, err)
+	}
+	return nil
+}
+
+func stateError(key db.DeploymentKey, err error) error {
+	if errors.Is(err, db.ErrStateConflict) {
+		return temporal.NewNonRetryableApplicationError("state of deployment "+key.String()+" was changed by another submission", ErrStateConflict, err)
+	}
+	return err
+}
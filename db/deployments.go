@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrDeploymentNotFound is returned when no deployment has the ID or key
+var ErrDeploymentNotFound = errors.New("deployment not found")
+
+// Key returns the identity the deployment's state is kept under
+func (d Deployment) Key() DeploymentKey {
+	return DeploymentKey{Account: d.Account, Project: d.Project, DeploymentID: d.DeploymentID}
+}
+
+// DeploymentFilter narrows ListDeployments, empty fields match everything
+type DeploymentFilter struct {
+	Account string
+	Project string
+	Status  string
+}
+
+// DeploymentRepository reads and records the deployments submissions run against
+type DeploymentRepository interface {
+	// GetDeployment returns the deployment with the ID, ErrDeploymentNotFound if there is none
+	GetDeployment(ctx context.Context, id string) (*Deployment, error)
+	// FindDeployment returns the deployment with the key, ErrDeploymentNotFound if there is none
+	FindDeployment(ctx context.Context, key DeploymentKey) (*Deployment, error)
+	// ListDeployments returns the deployments matching the filter newest first, without their
+	// definitions
+	ListDeployments(ctx context.Context, filter DeploymentFilter) ([]Deployment, error)
+	// RecordDeployment creates the deployment or updates the one with its key. An empty status
+	// keeps the current one, a deployment first recorded without one never became active and is
+	// FAILED. An empty definition keeps the one saved by the last create or update.
+	RecordDeployment(ctx context.Context, deployment *Deployment) error
+}
+
+// PostgresDeploymentRepository keeps the deployments in the deployments table
+type PostgresDeploymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresDeploymentRepository(gormDB *gorm.DB) (*PostgresDeploymentRepository, error) {
+	if gormDB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	return &PostgresDeploymentRepository{db: gormDB}, nil
+}
+
+func (p *PostgresDeploymentRepository) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrDeploymentNotFound
+	}
+	return p.first(p.db.WithContext(ctx).Where("id = ?", parsedID))
+}
+
+func (p *PostgresDeploymentRepository) FindDeployment(ctx context.Context, key DeploymentKey) (*Deployment, error) {
+	return p.first(p.db.WithContext(ctx).
+		Where("account = ? AND project = ? AND deployment_id = ?", key.Account, key.Project, key.DeploymentID))
+}
+
+func (p *PostgresDeploymentRepository) first(query *gorm.DB) (*Deployment, error) {
+	var deployment Deployment
+	err := query.First(&deployment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeploymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+func (p *PostgresDeploymentRepository) ListDeployments(ctx context.Context, filter DeploymentFilter) ([]Deployment, error) {
+	query := p.db.WithContext(ctx).Omit("definition").Order("created_at DESC")
+	if filter.Account != "" {
+		query = query.Where("account = ?", filter.Account)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var deployments []Deployment
+	err := query.Find(&deployments).Error
+	return deployments, err
+}
+
+func (p *PostgresDeploymentRepository) RecordDeployment(ctx context.Context, deployment *Deployment) error {
+	existing, err := p.FindDeployment(ctx, deployment.Key())
+	if errors.Is(err, ErrDeploymentNotFound) {
+		deployment.ID = uuid.New()
+		if deployment.Status == "" {
+			deployment.Status = DeploymentFailed
+		}
+		return p.db.WithContext(ctx).Create(deployment).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]any{
+		"workflow_name":      deployment.WorkflowName,
+		"last_submission_id": deployment.LastSubmissionID,
+		"last_error":         deployment.LastError,
+		"updated_at":         time.Now(),
+	}
+	if deployment.Status != "" {
+		updates["status"] = deployment.Status
+	}
+	if len(deployment.Definition) > 0 {
+		updates["definition"] = deployment.Definition
+	}
+	return p.db.WithContext(ctx).Model(existing).Updates(updates).Error
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// deploymentRepositories returns the DeploymentRepository implementations to run a test against,
+// the Postgres one only when usePostgres finds a server
+func deploymentRepositories(t *testing.T) map[string]func(t *testing.T) DeploymentRepository {
+	t.Helper()
+	return map[string]func(t *testing.T) DeploymentRepository{
+		"memory": func(t *testing.T) DeploymentRepository {
+			return NewMemoryDeploymentRepository()
+		},
+		"postgres": func(t *testing.T) DeploymentRepository {
+			usePostgres(t)
+			_, err := MigrateUp(context.Background())
+			require.NoError(t, err)
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: logger.Default.LogMode(logger.Silent),
+			})
+			require.NoError(t, err)
+			repository, err := NewPostgresDeploymentRepository(gormDB)
+			require.NoError(t, err)
+			return repository
+		},
+	}
+}
+
+var deploymentKey = DeploymentKey{Account: "acme", Project: "network", DeploymentID: "prod"}
+
+func record(t *testing.T, repository DeploymentRepository, status, lastError, definition string) *Deployment {
+	t.Helper()
+	deployment := Deployment{
+		Account:          deploymentKey.Account,
+		Project:          deploymentKey.Project,
+		DeploymentID:     deploymentKey.DeploymentID,
+		WorkflowName:     "network",
+		Status:           status,
+		LastSubmissionID: "submission-" + status,
+		LastError:        lastError,
+	}
+	if definition != "" {
+		deployment.Definition = datatypes.JSON(definition)
+	}
+	require.NoError(t, repository.RecordDeployment(context.Background(), &deployment))
+	found, err := repository.FindDeployment(context.Background(), deploymentKey)
+	require.NoError(t, err)
+	return found
+}
+
+func TestRecordDeploymentKeepsStatusAndDefinition(t *testing.T) {
+	for name, open := range deploymentRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repository := open(t)
+
+			created := record(t, repository, DeploymentActive, "", `{"workflow_name": "v1"}`)
+			assert.Equal(t, DeploymentActive, created.Status)
+
+			// A failed update keeps the deployment active and its definition
+			failed := record(t, repository, "", "step subnet failed", "")
+			assert.Equal(t, created.ID, failed.ID)
+			assert.Equal(t, DeploymentActive, failed.Status)
+			assert.Equal(t, "step subnet failed", failed.LastError)
+			assert.JSONEq(t, `{"workflow_name": "v1"}`, string(failed.Definition))
+
+			// The next successful one clears the error
+			updated := record(t, repository, DeploymentActive, "", `{"workflow_name": "v2"}`)
+			assert.Empty(t, updated.LastError)
+			assert.JSONEq(t, `{"workflow_name": "v2"}`, string(updated.Definition))
+
+			destroyed := record(t, repository, DeploymentDestroyed, "", "")
+			assert.Equal(t, DeploymentDestroyed, destroyed.Status)
+			assert.JSONEq(t, `{"workflow_name": "v2"}`, string(destroyed.Definition))
+		})
+	}
+}
+
+func TestRecordDeploymentFirstFailure(t *testing.T) {
+	for name, open := range deploymentRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repository := open(t)
+
+			// A deployment first recorded by a failed submission never became active
+			failed := record(t, repository, "", "step vpc failed", "")
+			assert.Equal(t, DeploymentFailed, failed.Status)
+			assert.Equal(t, "step vpc failed", failed.LastError)
+			assert.Empty(t, failed.Definition)
+
+			byID, err := repository.GetDeployment(context.Background(), failed.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, deploymentKey, byID.Key())
+		})
+	}
+}
+
+func TestDeploymentNotFound(t *testing.T) {
+	for name, open := range deploymentRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repository := open(t)
+			ctx := context.Background()
+
+			_, err := repository.FindDeployment(ctx, deploymentKey)
+			assert.ErrorIs(t, err, ErrDeploymentNotFound)
+			_, err = repository.GetDeployment(ctx, "00000000-0000-0000-0000-000000000001")
+			assert.ErrorIs(t, err, ErrDeploymentNotFound)
+			_, err = repository.GetDeployment(ctx, "not-a-uuid")
+			assert.ErrorIs(t, err, ErrDeploymentNotFound)
+		})
+	}
+}
+
+func TestListDeployments(t *testing.T) {
+	for name, open := range deploymentRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repository := open(t)
+			ctx := context.Background()
+			for _, deployment := range []Deployment{
+				{Account: "acme", Project: "network", DeploymentID: "prod", Status: DeploymentActive, Definition: datatypes.JSON(`{}`)},
+				{Account: "acme", Project: "network", DeploymentID: "dev", Status: DeploymentDestroyed},
+				{Account: "globex", Project: "network", DeploymentID: "prod", Status: DeploymentActive},
+			} {
+				require.NoError(t, repository.RecordDeployment(ctx, &deployment))
+			}
+
+			all, err := repository.ListDeployments(ctx, DeploymentFilter{})
+			require.NoError(t, err)
+			assert.Len(t, all, 3)
+			for _, deployment := range all {
+				assert.Empty(t, deployment.Definition, "listings leave out the definition")
+			}
+
+			active, err := repository.ListDeployments(ctx, DeploymentFilter{Account: "acme", Status: DeploymentActive})
+			require.NoError(t, err)
+			require.Len(t, active, 1)
+			assert.Equal(t, DeploymentKey{Account: "acme", Project: "network", DeploymentID: "prod"}, active[0].Key())
+		})
+	}
+}
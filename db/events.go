@@ -0,0 +1,35 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Types of SubmissionEvent, also the event names of the SSE stream
+const (
+	// EventStepStatus is sent for every status a step moves to
+	EventStepStatus = "step_status"
+	// EventStepOutput carries the outputs of a step that succeeded
+	EventStepOutput = "step_output"
+	// EventStepWaiting is sent when a failed step parks until an operator signals it
+	EventStepWaiting = "step_waiting_signal"
+	// EventSubmissionStatus is sent when the submission as a whole changes status
+	EventSubmissionStatus = "submission_status"
+)
+
+// SubmissionEvent is a step or submission lifecycle event. Seq numbers the events of a submission
+// from 1 without gaps, in the order they were committed, so a client that lost the stream resumes
+// after the last seq it saw.
+type SubmissionEvent struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubmissionID string         `gorm:"uniqueIndex:idx_submission_events_seq" json:"submission_id"`
+	Seq          int64          `gorm:"uniqueIndex:idx_submission_events_seq" json:"seq"`
+	StepID       string         `json:"step_id,omitempty"`
+	Type         string         `json:"type"`
+	Status       string         `json:"status,omitempty"`
+	Attempt      int            `json:"attempt,omitempty"`
+	Message      string         `json:"message,omitempty"`
+	Data         datatypes.JSON `json:"data,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// FileStateStore keeps every deployment's state and history in a JSON file under Dir. It is meant
+// for local development, the lock only covers a single process.
+type FileStateStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// stateFile is the content of a deployment's file
+type stateFile struct {
+	State   DeploymentState          `json:"state"`
+	History []DeploymentStateVersion `json:"history"`
+}
+
+func NewFileStateStore(dir string) *FileStateStore {
+	return &FileStateStore{Dir: dir}
+}
+
+func (f *FileStateStore) path(key DeploymentKey) string {
+	segment := strings.NewReplacer("/", "_", "\\", "_", "..", "_")
+	return filepath.Join(f.Dir, segment.Replace(key.Account), segment.Replace(key.Project), segment.Replace(key.DeploymentID)+".json")
+}
+
+func (f *FileStateStore) read(path string) (*stateFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+func (f *FileStateStore) Load(ctx context.Context, key DeploymentKey) (*DeploymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := f.read(f.path(key))
+	if err != nil {
+		return nil, err
+	}
+	return &file.State, nil
+}
+
+func (f *FileStateStore) Save(ctx context.Context, key DeploymentKey, results map[string]map[string]any, version int, submissionID string) (*DeploymentState, error) {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return f.write(key, StateActive, data, version, submissionID)
+}
+
+func (f *FileStateStore) Delete(ctx context.Context, key DeploymentKey, version int, submissionID string) (*DeploymentState, error) {
+	return f.write(key, StateDeleted, datatypes.JSON("{}"), version, submissionID)
+}
+
+func (f *FileStateStore) write(key DeploymentKey, status string, results datatypes.JSON, version int, submissionID string) (*DeploymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.path(key)
+	file, err := f.read(path)
+	switch {
+	case errors.Is(err, ErrStateNotFound):
+		file = &stateFile{}
+	case err != nil:
+		return nil, err
+	}
+	if file.State.writtenBy(version, status, submissionID) {
+		return &file.State, nil
+	}
+	if file.State.Version != version {
+		return nil, ErrStateConflict
+	}
+
+	now := time.Now()
+	if version == 0 {
+		file.State = DeploymentState{Account: key.Account, Project: key.Project, DeploymentID: key.DeploymentID, CreatedAt: now}
+	}
+	file.State.Version = version + 1
+	file.State.Status = status
+	file.State.Results = results
+	file.State.SubmissionID = submissionID
+	file.State.UpdatedAt = now
+	file.History = append(file.History, DeploymentStateVersion{
+		ID:           uuid.New(),
+		Account:      key.Account,
+		Project:      key.Project,
+		DeploymentID: key.DeploymentID,
+		Version:      file.State.Version,
+		Status:       status,
+		Results:      results,
+		SubmissionID: submissionID,
+		CreatedAt:    now,
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+	// Write a temporary file first so a crash never leaves a half written state behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to write state file: %w", err)
+	}
+	return &file.State, nil
+}
+
+func (f *FileStateStore) History(ctx context.Context, key DeploymentKey) ([]DeploymentStateVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := f.read(f.path(key))
+	if errors.Is(err, ErrStateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file.History, nil
+}
+
+func (f *FileStateStore) List(ctx context.Context) ([]DeploymentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var states []DeploymentState
+	err := filepath.WalkDir(f.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == f.Dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		file, err := f.read(path)
+		if err != nil {
+			return err
+		}
+		if file.State.Status == StateActive {
+			states = append(states, file.State)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Key().String() < states[j].Key().String()
+	})
+	return states, nil
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// LogLine is a line an executor's command wrote
+type LogLine struct {
+	Stream string    `json:"stream"` // stdout or stderr
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// SubmissionStepLog is a chunk of LogLines written during one attempt of a step. IDs only go up,
+// so a reader that follows the logs continues after the last ID it saw.
+type SubmissionStepLog struct {
+	ID              int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubmissionID    string         `gorm:"index:idx_submission_step_logs_step" json:"submission_id"`
+	StepID          string         `gorm:"index:idx_submission_step_logs_step" json:"step_id"`
+	Attempt         int            `gorm:"index:idx_submission_step_logs_step" json:"attempt"`
+	ActivityAttempt int32          `json:"activity_attempt"`
+	Lines           datatypes.JSON `json:"lines"` // []LogLine
+	CreatedAt       time.Time      `json:"created_at"`
+}
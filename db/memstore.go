@@ -0,0 +1,435 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemorySubmissionRepository keeps submissions in memory, for tests of the handlers and activities
+// that have no database to talk to
+type MemorySubmissionRepository struct {
+	mu          sync.Mutex
+	submissions map[string]Submission
+	steps       []SubmissionStep
+	attempts    []SubmissionStepAttempt
+	events      []SubmissionEvent
+	logs        []SubmissionStepLog
+}
+
+func NewMemorySubmissionRepository() *MemorySubmissionRepository {
+	return &MemorySubmissionRepository{submissions: map[string]Submission{}}
+}
+
+func (m *MemorySubmissionRepository) CreateSubmission(ctx context.Context, submission *Submission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if submission.ID == uuid.Nil {
+		submission.ID = uuid.New()
+	}
+	if submission.CreatedAt.IsZero() {
+		submission.CreatedAt = time.Now()
+	}
+	// Postgres keeps microseconds, ListSubmissions and the cursors have to see the same times
+	submission.CreatedAt = submission.CreatedAt.Truncate(time.Microsecond)
+	for i := range submission.Steps {
+		if submission.Steps[i].ID == uuid.Nil {
+			submission.Steps[i].ID = uuid.New()
+		}
+		submission.Steps[i].SubmissionID = submission.ID.String()
+		m.steps = append(m.steps, submission.Steps[i])
+	}
+	saved := *submission
+	saved.Steps = nil
+	m.submissions[submission.ID.String()] = saved
+	return nil
+}
+
+func (m *MemorySubmissionRepository) SetSubmissionRun(ctx context.Context, id string, runID string) error {
+	return m.updateSubmission(id, func(submission *Submission) { submission.RunID = runID })
+}
+
+func (m *MemorySubmissionRepository) UpdateSubmissionStatus(ctx context.Context, id string, status string) error {
+	return m.updateSubmission(id, func(submission *Submission) { submission.Status = status })
+}
+
+func (m *MemorySubmissionRepository) updateSubmission(id string, update func(submission *Submission)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	submission, ok := m.submissions[id]
+	if !ok {
+		return ErrSubmissionNotFound
+	}
+	update(&submission)
+	m.submissions[id] = submission
+	return nil
+}
+
+func (m *MemorySubmissionRepository) GetSubmission(ctx context.Context, id string) (*Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	submission, ok := m.submissions[id]
+	if !ok {
+		return nil, ErrSubmissionNotFound
+	}
+	for _, step := range m.steps {
+		if step.SubmissionID == id {
+			submission.Steps = append(submission.Steps, step)
+		}
+	}
+	return &submission, nil
+}
+
+func (m *MemorySubmissionRepository) ListSubmissions(ctx context.Context, filter SubmissionFilter) ([]Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var submissions []Submission
+	for _, submission := range m.submissions {
+		if matchesFilter(submission, filter) {
+			submissions = append(submissions, submission)
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissionBefore(submissions[i], submissions[j]) != filter.Descending
+	})
+	if filter.Limit > 0 && len(submissions) > filter.Limit {
+		submissions = submissions[:filter.Limit]
+	}
+	return submissions, nil
+}
+
+func matchesFilter(submission Submission, filter SubmissionFilter) bool {
+	for _, field := range [][2]string{
+		{filter.Account, submission.Account},
+		{filter.Project, submission.Project},
+		{filter.DeploymentID, submission.DeploymentID},
+		{filter.Submitter, submission.Submitter},
+		{filter.Action, submission.Action},
+		{filter.Status, submission.Status},
+	} {
+		if field[0] != "" && field[0] != field[1] {
+			return false
+		}
+	}
+	if !filter.CreatedFrom.IsZero() && submission.CreatedAt.Before(filter.CreatedFrom) {
+		return false
+	}
+	if !filter.CreatedTo.IsZero() && !submission.CreatedAt.Before(filter.CreatedTo) {
+		return false
+	}
+	if filter.After != nil {
+		cursor := Submission{ID: filter.After.ID, CreatedAt: filter.After.CreatedAt}
+		if filter.Descending {
+			return submissionBefore(submission, cursor)
+		}
+		return submissionBefore(cursor, submission)
+	}
+	return true
+}
+
+// submissionBefore orders submissions the way ListSubmissions does, by creation time then ID
+func submissionBefore(a, b Submission) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+func (m *MemorySubmissionRepository) CountSteps(ctx context.Context, submissionIDs []string) (map[string]map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[string]map[string]int{}
+	for _, id := range submissionIDs {
+		for _, step := range m.steps {
+			if step.SubmissionID != id {
+				continue
+			}
+			if counts[id] == nil {
+				counts[id] = map[string]int{}
+			}
+			counts[id][step.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (m *MemorySubmissionRepository) GetStep(ctx context.Context, submissionID, stepID string) (*SubmissionStep, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.findStep(submissionID, stepID); i >= 0 {
+		step := m.steps[i]
+		return &step, nil
+	}
+	return nil, ErrStepNotFound
+}
+
+func (m *MemorySubmissionRepository) InsertStep(ctx context.Context, step *SubmissionStep) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.findStep(step.SubmissionID, step.StepID); i >= 0 {
+		*step = m.steps[i]
+		return nil
+	}
+	if step.ID == uuid.Nil {
+		step.ID = uuid.New()
+	}
+	m.steps = append(m.steps, *step)
+	return nil
+}
+
+func (m *MemorySubmissionRepository) UpdateStep(ctx context.Context, step *SubmissionStep, from string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.steps {
+		if m.steps[i].ID != step.ID {
+			continue
+		}
+		if m.steps[i].Status != from {
+			return ErrStepChanged
+		}
+		saved := &m.steps[i]
+		saved.Status = step.Status
+		saved.StepResult = step.StepResult
+		saved.ErrorMessage = step.ErrorMessage
+		saved.Attempt = step.Attempt
+		saved.StartedAt = step.StartedAt
+		saved.FinishedAt = step.FinishedAt
+		saved.LastUpdatedAt = step.LastUpdatedAt
+		return nil
+	}
+	return ErrStepChanged
+}
+
+func (m *MemorySubmissionRepository) CancelSteps(ctx context.Context, submissionID string, reason string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var cancelled []string
+	for i := range m.steps {
+		step := &m.steps[i]
+		if step.SubmissionID != submissionID || step.Status == StepCancelled || !ValidStepTransition(step.Status, StepCancelled) {
+			continue
+		}
+		step.Status = StepCancelled
+		step.ErrorMessage = reason
+		step.FinishedAt = &now
+		step.LastUpdatedAt = now
+		cancelled = append(cancelled, step.StepID)
+	}
+	return cancelled, nil
+}
+
+func (m *MemorySubmissionRepository) findStep(submissionID, stepID string) int {
+	for i, step := range m.steps {
+		if step.SubmissionID == submissionID && step.StepID == stepID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemorySubmissionRepository) CreateAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempt.ID == uuid.Nil {
+		attempt.ID = uuid.New()
+	}
+	m.attempts = append(m.attempts, *attempt)
+	return nil
+}
+
+func (m *MemorySubmissionRepository) FinishAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.attempts {
+		if m.attempts[i].ID == attempt.ID {
+			m.attempts[i].Status = attempt.Status
+			m.attempts[i].Error = attempt.Error
+			m.attempts[i].Stderr = attempt.Stderr
+			m.attempts[i].FinishedAt = attempt.FinishedAt
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MemorySubmissionRepository) ListAttempts(ctx context.Context, submissionID, stepID string) ([]SubmissionStepAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts := []SubmissionStepAttempt{}
+	for _, attempt := range m.attempts {
+		if attempt.SubmissionID == submissionID && attempt.StepID == stepID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return attempts[i].StartedAt.Before(attempts[j].StartedAt)
+	})
+	return attempts, nil
+}
+
+func (m *MemorySubmissionRepository) AppendEvent(ctx context.Context, event *SubmissionEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.submissions[event.SubmissionID]; !ok {
+		return ErrSubmissionNotFound
+	}
+	event.ID = int64(len(m.events) + 1)
+	event.Seq = 1
+	for _, existing := range m.events {
+		if existing.SubmissionID == event.SubmissionID {
+			event.Seq++
+		}
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *MemorySubmissionRepository) ListEvents(ctx context.Context, submissionID string, afterSeq int64, limit int) ([]SubmissionEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := []SubmissionEvent{}
+	for _, event := range m.events {
+		if event.SubmissionID == submissionID && event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemorySubmissionRepository) AppendLogs(ctx context.Context, chunk *SubmissionStepLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chunk.ID = int64(len(m.logs) + 1)
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now()
+	}
+	m.logs = append(m.logs, *chunk)
+	return nil
+}
+
+func (m *MemorySubmissionRepository) ListLogs(ctx context.Context, submissionID, stepID string, attempt int, afterID int64, limit int) ([]SubmissionStepLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chunks := []SubmissionStepLog{}
+	for _, chunk := range m.logs {
+		if chunk.SubmissionID == submissionID && chunk.StepID == stepID && chunk.Attempt == attempt && chunk.ID > afterID && len(chunks) < limit {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// MemoryDeploymentRepository keeps deployments in memory, for tests of the handlers and
+// activities that have no database to talk to
+type MemoryDeploymentRepository struct {
+	mu          sync.Mutex
+	deployments []Deployment
+}
+
+func NewMemoryDeploymentRepository() *MemoryDeploymentRepository {
+	return &MemoryDeploymentRepository{}
+}
+
+func (m *MemoryDeploymentRepository) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, deployment := range m.deployments {
+		if deployment.ID.String() == id {
+			return &deployment, nil
+		}
+	}
+	return nil, ErrDeploymentNotFound
+}
+
+func (m *MemoryDeploymentRepository) FindDeployment(ctx context.Context, key DeploymentKey) (*Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.find(key); i >= 0 {
+		deployment := m.deployments[i]
+		return &deployment, nil
+	}
+	return nil, ErrDeploymentNotFound
+}
+
+func (m *MemoryDeploymentRepository) find(key DeploymentKey) int {
+	for i, deployment := range m.deployments {
+		if deployment.Key() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryDeploymentRepository) ListDeployments(ctx context.Context, filter DeploymentFilter) ([]Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deployments []Deployment
+	for _, deployment := range m.deployments {
+		if (filter.Account == "" || filter.Account == deployment.Account) &&
+			(filter.Project == "" || filter.Project == deployment.Project) &&
+			(filter.Status == "" || filter.Status == deployment.Status) {
+			deployment.Definition = nil
+			deployments = append(deployments, deployment)
+		}
+	}
+	sort.SliceStable(deployments, func(i, j int) bool {
+		return deployments[i].CreatedAt.After(deployments[j].CreatedAt)
+	})
+	return deployments, nil
+}
+
+func (m *MemoryDeploymentRepository) RecordDeployment(ctx context.Context, deployment *Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	i := m.find(deployment.Key())
+	if i < 0 {
+		deployment.ID = uuid.New()
+		if deployment.Status == "" {
+			deployment.Status = DeploymentFailed
+		}
+		deployment.CreatedAt, deployment.UpdatedAt = now, now
+		m.deployments = append(m.deployments, *deployment)
+		return nil
+	}
+
+	existing := &m.deployments[i]
+	existing.WorkflowName = deployment.WorkflowName
+	existing.LastSubmissionID = deployment.LastSubmissionID
+	existing.LastError = deployment.LastError
+	existing.UpdatedAt = now
+	if deployment.Status != "" {
+		existing.Status = deployment.Status
+	}
+	if len(deployment.Definition) > 0 {
+		existing.Definition = deployment.Definition
+	}
+	return nil
+}
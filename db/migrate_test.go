@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testPostgresOnce sync.Once
+	testPostgresErr  error
+	embeddedServer   *embeddedpostgres.EmbeddedPostgres
+	embeddedDir      string
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if embeddedServer != nil {
+		if err := embeddedServer.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to stop embedded postgres: %v\n", err)
+		}
+		os.RemoveAll(embeddedDir)
+	}
+	os.Exit(code)
+}
+
+// usePostgres points sqlDB at an empty public schema. TEST_DATABASE_URL names a server to use,
+// otherwise an embedded Postgres is started once for the package. The test is skipped when
+// neither is available.
+func usePostgres(t *testing.T) {
+	t.Helper()
+	testPostgresOnce.Do(func() {
+		url := os.Getenv("TEST_DATABASE_URL")
+		if url == "" {
+			url, testPostgresErr = startEmbeddedPostgres()
+			if testPostgresErr != nil {
+				return
+			}
+		}
+		sqlDB, testPostgresErr = sql.Open("postgres", url)
+		if testPostgresErr == nil {
+			testPostgresErr = sqlDB.Ping()
+		}
+	})
+	if testPostgresErr != nil {
+		t.Skipf("no Postgres to run against: %v", testPostgresErr)
+	}
+	_, err := sqlDB.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public`)
+	require.NoError(t, err)
+}
+
+func startEmbeddedPostgres() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	embeddedDir, err = os.MkdirTemp("", "dsl-postgres-")
+	if err != nil {
+		return "", err
+	}
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(embeddedDir).
+		Logger(nil))
+	if err := server.Start(); err != nil {
+		os.RemoveAll(embeddedDir)
+		return "", err
+	}
+	embeddedServer = server
+	return fmt.Sprintf("host=localhost port=%d user=postgres password=postgres dbname=postgres sslmode=disable", port), nil
+}
+
+func userTables(t *testing.T) []string {
+	t.Helper()
+	rows, err := sqlDB.Query(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name <> 'schema_migrations' ORDER BY table_name`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		require.NoError(t, rows.Scan(&table))
+		tables = append(tables, table)
+	}
+	require.NoError(t, rows.Err())
+	return tables
+}
+
+func versions(migrations []Migration) []int64 {
+	result := make([]int64, len(migrations))
+	for i, migration := range migrations {
+		result[i] = migration.Version
+	}
+	return result
+}
+
+func TestMigrateUpDownRoundTrip(t *testing.T) {
+	usePostgres(t)
+	ctx := context.Background()
+	migrations, err := LoadMigrations()
+	require.NoError(t, err)
+
+	applied, err := MigrateUp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, versions(migrations), versions(applied))
+	tables := userTables(t)
+
+	// Every migration, newest first, goes down and up again before the one below it is reverted
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+
+		reverted, err := MigrateDown(ctx, 1)
+		require.NoError(t, err, "down %d_%s", migration.Version, migration.Name)
+		assert.Equal(t, []int64{migration.Version}, versions(reverted))
+
+		applied, err := MigrateUp(ctx)
+		require.NoError(t, err, "up again %d_%s", migration.Version, migration.Name)
+		assert.Equal(t, []int64{migration.Version}, versions(applied))
+
+		_, err = MigrateDown(ctx, 1)
+		require.NoError(t, err, "down again %d_%s", migration.Version, migration.Name)
+	}
+	assert.Empty(t, userTables(t), "reverting every migration leaves tables behind")
+
+	applied, err = MigrateUp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, versions(migrations), versions(applied))
+	assert.Equal(t, tables, userTables(t))
+}
+
+func TestMigrateDownPastFirstMigration(t *testing.T) {
+	usePostgres(t)
+	ctx := context.Background()
+	migrations, err := LoadMigrations()
+	require.NoError(t, err)
+
+	_, err = MigrateUp(ctx)
+	require.NoError(t, err)
+	reverted, err := MigrateDown(ctx, len(migrations)+5)
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(migrations))
+	assert.Equal(t, migrations[len(migrations)-1].Version, reverted[0].Version)
+
+	reverted, err = MigrateDown(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, reverted)
+}
+
+func TestMigrationStatuses(t *testing.T) {
+	usePostgres(t)
+	ctx := context.Background()
+	migrations, err := LoadMigrations()
+	require.NoError(t, err)
+
+	statuses, err := MigrationStatuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt, "migration %d is pending", status.Version)
+	}
+
+	_, err = MigrateUp(ctx)
+	require.NoError(t, err)
+	_, err = MigrateDown(ctx, 1)
+	require.NoError(t, err)
+
+	statuses, err = MigrationStatuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for i, status := range statuses {
+		assert.Equal(t, migrations[i].Version, status.Version)
+		assert.Equal(t, migrations[i].Name, status.Name)
+		if i == len(statuses)-1 {
+			assert.Nil(t, status.AppliedAt, "reverted migration %d", status.Version)
+			continue
+		}
+		assert.NotNil(t, status.AppliedAt, "applied migration %d", status.Version)
+	}
+}
+
+func TestConcurrentMigrateUpAppliesEachMigrationOnce(t *testing.T) {
+	usePostgres(t)
+	ctx := context.Background()
+	migrations, err := LoadMigrations()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	results := make([][]Migration, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = MigrateUp(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	// The lock makes one replica apply everything and the other find nothing left to do
+	assert.ElementsMatch(t, []int{0, len(migrations)}, []int{len(results[0]), len(results[1])})
+
+	var count int
+	require.NoError(t, sqlDB.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&count))
+	assert.Equal(t, len(migrations), count)
+}
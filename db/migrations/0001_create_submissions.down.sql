@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS submission_steps;
+DROP TABLE IF EXISTS submissions;
@@ -0,0 +1,33 @@
+-- Tables that used to be created by hand, IF NOT EXISTS keeps existing installs working
+CREATE TABLE IF NOT EXISTS submissions (
+    id            uuid PRIMARY KEY,
+    workflow_name text,
+    account       text,
+    submitter     text,
+    project       text,
+    action        text,
+    deployment_id text,
+    run_id        text,
+    workflow_id   text,
+    status        text,
+    created_at    timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS submission_steps (
+    id              uuid PRIMARY KEY,
+    submission_id   text,
+    step_id         text,
+    provider        text,
+    executor        text,
+    resource        text,
+    workspace       text,
+    operation       text,
+    variables       jsonb,
+    depends_on      text[],
+    last_updated_at timestamptz,
+    status          text,
+    step_result     jsonb
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_steps_submission ON submission_steps (submission_id, step_id);
+CREATE INDEX IF NOT EXISTS idx_submissions_deployment ON submissions (account, project, deployment_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS deployment_state_versions;
+DROP TABLE IF EXISTS deployment_states;
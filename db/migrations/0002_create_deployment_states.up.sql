@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS deployment_states (
+    account       text NOT NULL,
+    project       text NOT NULL,
+    deployment_id text NOT NULL,
+    version       bigint NOT NULL,
+    status        text NOT NULL,
+    results       jsonb,
+    submission_id text,
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    PRIMARY KEY (account, project, deployment_id)
+);
+
+CREATE TABLE IF NOT EXISTS deployment_state_versions (
+    id            uuid PRIMARY KEY,
+    account       text NOT NULL,
+    project       text NOT NULL,
+    deployment_id text NOT NULL,
+    version       bigint NOT NULL,
+    status        text NOT NULL,
+    results       jsonb,
+    submission_id text,
+    created_at    timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deployment_state_version ON deployment_state_versions (account, project, deployment_id, version);
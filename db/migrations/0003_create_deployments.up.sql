@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS deployments (
+    id                 uuid PRIMARY KEY,
+    account            text,
+    project            text,
+    deployment_id      text,
+    workflow_name      text,
+    status             text,
+    definition         jsonb,
+    last_submission_id text,
+    created_at         timestamptz,
+    updated_at         timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deployment ON deployments (account, project, deployment_id);
@@ -0,0 +1,5 @@
+ALTER TABLE submission_steps
+    DROP COLUMN IF EXISTS error_message,
+    DROP COLUMN IF EXISTS attempt,
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS finished_at;
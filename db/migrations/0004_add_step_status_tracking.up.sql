@@ -0,0 +1,5 @@
+ALTER TABLE submission_steps
+    ADD COLUMN IF NOT EXISTS error_message text,
+    ADD COLUMN IF NOT EXISTS attempt       bigint,
+    ADD COLUMN IF NOT EXISTS started_at    timestamptz,
+    ADD COLUMN IF NOT EXISTS finished_at   timestamptz;
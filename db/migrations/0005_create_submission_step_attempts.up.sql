@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS submission_step_attempts (
+    id               uuid PRIMARY KEY,
+    submission_id    text,
+    step_id          text,
+    attempt          bigint,
+    activity_attempt integer,
+    action           text,
+    variables        jsonb,
+    trigger          text,
+    triggered_by     text,
+    status           text,
+    error            text,
+    stderr           text,
+    started_at       timestamptz,
+    finished_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_step_attempt ON submission_step_attempts (submission_id, step_id);
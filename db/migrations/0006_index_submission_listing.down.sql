@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_submissions_status;
+DROP INDEX IF EXISTS idx_submissions_created_at;
@@ -0,0 +1,3 @@
+-- Keyset pagination of GET /v1/submissions walks this index in both directions
+CREATE INDEX IF NOT EXISTS idx_submissions_created_at ON submissions (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_submissions_status ON submissions (status);
@@ -0,0 +1,15 @@
+-- Step and submission lifecycle events streamed by GET /v1/submissions/:id/events, the ID is the
+-- SSE event ID clients resume from
+CREATE TABLE IF NOT EXISTS submission_events (
+    id            bigserial PRIMARY KEY,
+    submission_id text NOT NULL,
+    step_id       text,
+    type          text NOT NULL,
+    status        text,
+    attempt       bigint,
+    message       text,
+    data          jsonb,
+    created_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_events_submission ON submission_events (submission_id, id);
@@ -0,0 +1,13 @@
+-- Output of the commands executors run, a row is a batch of lines written during one attempt of a
+-- step. Lines are ordered by the row ID and their position in the batch.
+CREATE TABLE IF NOT EXISTS submission_step_logs (
+    id               bigserial PRIMARY KEY,
+    submission_id    text NOT NULL,
+    step_id          text NOT NULL,
+    attempt          bigint,
+    activity_attempt integer,
+    lines            jsonb NOT NULL,
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_step_logs_step ON submission_step_logs (submission_id, step_id, attempt, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_submission_events_seq;
+ALTER TABLE submission_events DROP COLUMN IF EXISTS seq;
+ALTER TABLE submissions DROP COLUMN IF EXISTS last_event_seq;
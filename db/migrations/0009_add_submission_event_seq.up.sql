@@ -0,0 +1,25 @@
+-- Events are numbered per submission from a counter on the submission's row. Taking the number
+-- locks the row until the event commits, so events become visible in seq order without gaps and a
+-- stream that resumes after seq N can not miss one committed later with a lower number.
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS last_event_seq bigint NOT NULL DEFAULT 0;
+ALTER TABLE submission_events ADD COLUMN IF NOT EXISTS seq bigint;
+
+UPDATE submission_events e
+SET seq = numbered.seq
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY submission_id ORDER BY id) AS seq
+    FROM submission_events
+) numbered
+WHERE e.id = numbered.id;
+
+UPDATE submissions s
+SET last_event_seq = counted.seq
+FROM (
+    SELECT submission_id, max(seq) AS seq
+    FROM submission_events
+    GROUP BY submission_id
+) counted
+WHERE s.id::text = counted.submission_id;
+
+ALTER TABLE submission_events ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_submission_events_seq ON submission_events (submission_id, seq);
@@ -0,0 +1 @@
+ALTER TABLE submission_steps DROP COLUMN IF EXISTS type;
@@ -0,0 +1,6 @@
+-- The type of the step, approval for manual gates and empty for executor steps, so decisions
+-- can be refused for steps that are not waiting on one. Only approval steps ever wait for
+-- approval, which marks the ones of submissions already running.
+ALTER TABLE submission_steps ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT '';
+
+UPDATE submission_steps SET type = 'approval' WHERE status = 'WAITING_APPROVAL';
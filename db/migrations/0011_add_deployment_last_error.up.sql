@@ -0,0 +1,3 @@
+-- Why the last submission of a deployment failed. A failed update or delete keeps the
+-- deployment's status, so the error is recorded next to it.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS last_error text NOT NULL DEFAULT '';
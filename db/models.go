@@ -19,23 +19,25 @@ type SubmissionRetryInput struct {
 }
 
 type Submission struct {
-	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
-	WorkflowName string
-	Account      string
-	Submitter    string
-	Project      string
-	Action       string
-	DeploymentID string
-	RunID        string
-	WorkflowID   string
-	CreatedAt    time.Time
-	Steps        []SubmissionStep `gorm:"foreignKey:SubmissionID"`
+	ID           uuid.UUID        `gorm:"type:uuid;primaryKey" json:"id"`
+	WorkflowName string           `json:"workflow_name"`
+	Account      string           `json:"account"`
+	Submitter    string           `json:"submitter"`
+	Project      string           `json:"project"`
+	Action       string           `json:"action"`
+	DeploymentID string           `json:"deployment_id"`
+	RunID        string           `json:"run_id"`
+	WorkflowID   string           `json:"workflow_id"`
+	Status       string           `json:"status"` // See the Submission* statuses
+	CreatedAt    time.Time        `json:"created_at"`
+	Steps        []SubmissionStep `gorm:"foreignKey:SubmissionID" json:"steps,omitempty"`
 }
 
 type SubmissionStep struct {
 	ID            uuid.UUID `gorm:"type:uuid;primaryKey"`
 	SubmissionID  string
 	StepID        string
+	Type          string // approval for manual gates, empty for executor steps
 	Provider      string
 	Executor      string
 	Resource      string
@@ -44,8 +46,12 @@ type SubmissionStep struct {
 	Variables     datatypes.JSON
 	DependsOn     pq.StringArray `gorm:"type:text[]"`
 	LastUpdatedAt time.Time
-	Status        string // PENDING, SUCCESS, FAILED
+	Status        string // See the Step* statuses
 	StepResult    datatypes.JSON
+	ErrorMessage  string
+	Attempt       int
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
 }
 
 
@@ -60,4 +66,51 @@ type StepResult struct {
 	StepID     string                 `json:"step_id"`
 	Status string				 `json:"step_status"`
 	StepResult map[string]interface{} `json:"step_result"`
-}
\ No newline at end of file
+}
+
+// Deployment is the set of resources one workflow definition manages, identified by
+// account/project/deployment_id. The submissions that created, updated or deleted it share that
+// identity, its outputs live in the StateStore.
+type Deployment struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Account      string    `gorm:"uniqueIndex:idx_deployment" json:"account"`
+	Project      string    `gorm:"uniqueIndex:idx_deployment" json:"project"`
+	DeploymentID string    `gorm:"uniqueIndex:idx_deployment" json:"deployment_id"`
+	WorkflowName string    `json:"workflow_name"`
+	Status       string    `json:"status"` // ACTIVE, DESTROYED, FAILED
+	// Definition is the workflow document of the last successful create or update with its secrets
+	// redacted, a delete submitted without steps runs against it. It is never returned by the API.
+	Definition       datatypes.JSON `json:"definition,omitempty"`
+	LastSubmissionID string         `json:"last_submission_id"`
+	// LastError is why the last submission failed, cleared by the next one that succeeds
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Deployment lifecycle statuses
+const (
+	DeploymentActive    = "ACTIVE"
+	DeploymentDestroyed = "DESTROYED"
+	DeploymentFailed    = "FAILED"
+)
+
+// SubmissionStepAttempt is one run of a step's executor activity. Temporal retries and retries
+// signalled by an operator each add a row, so earlier errors and inputs are kept.
+type SubmissionStepAttempt struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	SubmissionID string    `gorm:"index:idx_step_attempt" json:"submission_id"`
+	StepID       string    `gorm:"index:idx_step_attempt" json:"step_id"`
+	// Attempt is the workflow's run of the step, ActivityAttempt the Temporal attempt within it
+	Attempt         int            `json:"attempt"`
+	ActivityAttempt int32          `json:"activity_attempt"`
+	Action          string         `json:"action"`
+	Variables       datatypes.JSON `json:"variables"` // Secrets are redacted
+	Trigger         string         `json:"trigger"`   // initial, retry or rollback
+	TriggeredBy     string         `json:"triggered_by,omitempty"`
+	Status          string         `json:"status"` // RUNNING, SUCCESS, FAILED, CANCELLED
+	Error           string         `json:"error,omitempty"`
+	Stderr          string         `json:"stderr,omitempty"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      *time.Time     `json:"finished_at,omitempty"`
+}
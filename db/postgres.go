@@ -19,12 +19,13 @@ var (
 	GormDB *gorm.DB
 	sqlDB  *sql.DB
 	once   sync.Once
+	dsn    string
 )
 
 // InitDB 
 func InitDB(dbuser,dbpassword,dbname string) error {
 	var err error
-	dsn := fmt.Sprintf(
+	dsn = fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		getEnv("DB_HOST", "localhost"),
 		getEnv("DB_USER", dbuser),
@@ -51,6 +52,11 @@ func InitDB(dbuser,dbpassword,dbname string) error {
 	return err
 }
 
+// ConnString returns the connection string InitDB connected with
+func ConnString() string {
+	return dsn
+}
+
 func getEnv(key, fallback string) string {
 	if val, ok := os.LookupEnv(key); ok {
 		return val
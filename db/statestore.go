@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Deployment state statuses
+const (
+	StateActive  = "ACTIVE"
+	StateDeleted = "DELETED"
+)
+
+var (
+	// ErrStateNotFound is returned when a deployment has never saved any state
+	ErrStateNotFound = errors.New("deployment state not found")
+	// ErrStateConflict is returned when the state changed since the version the caller loaded
+	ErrStateConflict = errors.New("deployment state was changed by another submission")
+)
+
+// DeploymentKey identifies a deployment's state
+type DeploymentKey struct {
+	Account      string `json:"account"`
+	Project      string `json:"project"`
+	DeploymentID string `json:"deployment_id"`
+}
+
+func (k DeploymentKey) String() string {
+	return fmt.Sprintf("%s/%s/%s", k.Account, k.Project, k.DeploymentID)
+}
+
+// DeploymentState is the latest saved outputs of a deployment. Version starts at 1 and goes up
+// with every save, deleted deployments keep their row with status DELETED.
+type DeploymentState struct {
+	Account      string         `gorm:"primaryKey" json:"account"`
+	Project      string         `gorm:"primaryKey" json:"project"`
+	DeploymentID string         `gorm:"primaryKey" json:"deployment_id"`
+	Version      int            `json:"version"`
+	Status       string         `json:"status"` // ACTIVE, DELETED
+	Results      datatypes.JSON `json:"results"`
+	SubmissionID string         `json:"submission_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// DeploymentStateVersion is one saved version of a deployment's state
+type DeploymentStateVersion struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	Account      string         `gorm:"uniqueIndex:idx_deployment_state_version" json:"account"`
+	Project      string         `gorm:"uniqueIndex:idx_deployment_state_version" json:"project"`
+	DeploymentID string         `gorm:"uniqueIndex:idx_deployment_state_version" json:"deployment_id"`
+	Version      int            `gorm:"uniqueIndex:idx_deployment_state_version" json:"version"`
+	Status       string         `json:"status"`
+	Results      datatypes.JSON `json:"results"`
+	SubmissionID string         `json:"submission_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// Key returns the deployment the state belongs to
+func (s DeploymentState) Key() DeploymentKey {
+	return DeploymentKey{Account: s.Account, Project: s.Project, DeploymentID: s.DeploymentID}
+}
+
+// writtenBy reports whether the state is the version a write of status by submissionID over
+// version produces. An activity whose write committed but whose reply was lost is retried with
+// the version it loaded, and has to get its own write back instead of a conflict.
+func (s DeploymentState) writtenBy(version int, status string, submissionID string) bool {
+	return s.Version == version+1 && s.Status == status && submissionID != "" && s.SubmissionID == submissionID
+}
+
+// Outputs decodes the step results kept in the state
+func (s DeploymentState) Outputs() (map[string]map[string]any, error) {
+	results := make(map[string]map[string]any)
+	if len(s.Results) == 0 {
+		return results, nil
+	}
+	if err := json.Unmarshal(s.Results, &results); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state of %s: %w", s.Key(), err)
+	}
+	return results, nil
+}
+
+// StateStore keeps the step outputs of every deployment so updates and deletes can run against
+// them from any worker. Writes are optimistically locked: they pass the version they loaded, 0
+// for a deployment without state, and fail with ErrStateConflict if it has moved on since. A
+// write the same submission already made over that version returns the saved state again.
+type StateStore interface {
+	// Load returns the latest state of the deployment, ErrStateNotFound if there is none
+	Load(ctx context.Context, key DeploymentKey) (*DeploymentState, error)
+	// Save stores results as the next version of the deployment's state
+	Save(ctx context.Context, key DeploymentKey, results map[string]map[string]any, version int, submissionID string) (*DeploymentState, error)
+	// Delete marks the deployment's state deleted, its history is kept
+	Delete(ctx context.Context, key DeploymentKey, version int, submissionID string) (*DeploymentState, error)
+	// History returns every version of the deployment's state, oldest first
+	History(ctx context.Context, key DeploymentKey) ([]DeploymentStateVersion, error)
+	// List returns the state of every deployment that has not been deleted
+	List(ctx context.Context) ([]DeploymentState, error)
+}
+
+// NewStateStore returns the store the workflow outputs of every deployment are kept in.
+// STATE_STORE picks the implementation: postgres (default) in gormDB or file for local
+// development, which keeps the states under STATE_STORE_DIR.
+func NewStateStore(gormDB *gorm.DB) (StateStore, error) {
+	switch store := getEnv("STATE_STORE", "postgres"); store {
+	case "postgres":
+		return NewPostgresStateStore(gormDB)
+	case "file":
+		return NewFileStateStore(getEnv("STATE_STORE_DIR", "./storage/state")), nil
+	default:
+		return nil, fmt.Errorf("unknown STATE_STORE %q, expected postgres or file", store)
+	}
+}
+
+// PostgresStateStore keeps the states in the deployment_states table and every version of them
+// in deployment_state_versions
+type PostgresStateStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStateStore stores the states in the tables created by the migrations
+func NewPostgresStateStore(gormDB *gorm.DB) (*PostgresStateStore, error) {
+	if gormDB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	return &PostgresStateStore{db: gormDB}, nil
+}
+
+func (p *PostgresStateStore) Load(ctx context.Context, key DeploymentKey) (*DeploymentState, error) {
+	var state DeploymentState
+	err := p.db.WithContext(ctx).
+		Where("account = ? AND project = ? AND deployment_id = ?", key.Account, key.Project, key.DeploymentID).
+		First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p *PostgresStateStore) Save(ctx context.Context, key DeploymentKey, results map[string]map[string]any, version int, submissionID string) (*DeploymentState, error) {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return p.write(ctx, key, StateActive, data, version, submissionID)
+}
+
+func (p *PostgresStateStore) Delete(ctx context.Context, key DeploymentKey, version int, submissionID string) (*DeploymentState, error) {
+	return p.write(ctx, key, StateDeleted, datatypes.JSON("{}"), version, submissionID)
+}
+
+// write moves the state from version to version+1 and records the new version in the history,
+// both in one transaction
+func (p *PostgresStateStore) write(ctx context.Context, key DeploymentKey, status string, results datatypes.JSON, version int, submissionID string) (*DeploymentState, error) {
+	now := time.Now()
+	state := DeploymentState{
+		Account:      key.Account,
+		Project:      key.Project,
+		DeploymentID: key.DeploymentID,
+		Version:      version + 1,
+		Status:       status,
+		Results:      results,
+		SubmissionID: submissionID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if version == 0 {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&state)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrStateConflict
+			}
+		} else {
+			res := tx.Model(&DeploymentState{}).
+				Where("account = ? AND project = ? AND deployment_id = ? AND version = ?", key.Account, key.Project, key.DeploymentID, version).
+				Updates(map[string]any{
+					"version":       state.Version,
+					"status":        status,
+					"results":       results,
+					"submission_id": submissionID,
+					"updated_at":    now,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrStateConflict
+			}
+			if err := tx.Where("account = ? AND project = ? AND deployment_id = ?", key.Account, key.Project, key.DeploymentID).First(&state).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&DeploymentStateVersion{
+			ID:           uuid.New(),
+			Account:      key.Account,
+			Project:      key.Project,
+			DeploymentID: key.DeploymentID,
+			Version:      state.Version,
+			Status:       status,
+			Results:      results,
+			SubmissionID: submissionID,
+			CreatedAt:    now,
+		}).Error
+	})
+	if errors.Is(err, ErrStateConflict) {
+		current, loadErr := p.Load(ctx, key)
+		if loadErr == nil && current.writtenBy(version, status, submissionID) {
+			return current, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p *PostgresStateStore) History(ctx context.Context, key DeploymentKey) ([]DeploymentStateVersion, error) {
+	var versions []DeploymentStateVersion
+	err := p.db.WithContext(ctx).
+		Where("account = ? AND project = ? AND deployment_id = ?", key.Account, key.Project, key.DeploymentID).
+		Order("version").
+		Find(&versions).Error
+	return versions, err
+}
+
+func (p *PostgresStateStore) List(ctx context.Context) ([]DeploymentState, error) {
+	var states []DeploymentState
+	err := p.db.WithContext(ctx).
+		Where("status = ?", StateActive).
+		Order("account, project, deployment_id").
+		Find(&states).Error
+	return states, err
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stateStores returns the StateStore implementations to run a test against, the Postgres one
+// only when usePostgres finds a server
+func stateStores(t *testing.T) map[string]func(t *testing.T) StateStore {
+	t.Helper()
+	return map[string]func(t *testing.T) StateStore{
+		"file": func(t *testing.T) StateStore {
+			return NewFileStateStore(t.TempDir())
+		},
+		"postgres": func(t *testing.T) StateStore {
+			usePostgres(t)
+			_, err := MigrateUp(context.Background())
+			require.NoError(t, err)
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: logger.Default.LogMode(logger.Silent),
+			})
+			require.NoError(t, err)
+			store, err := NewPostgresStateStore(gormDB)
+			require.NoError(t, err)
+			return store
+		},
+	}
+}
+
+var stateKey = DeploymentKey{Account: "acme", Project: "network", DeploymentID: "prod"}
+
+func historyVersions(t *testing.T, store StateStore) []string {
+	t.Helper()
+	history, err := store.History(context.Background(), stateKey)
+	require.NoError(t, err)
+	versions := make([]string, len(history))
+	for i, version := range history {
+		versions[i] = version.Status + " by " + version.SubmissionID
+	}
+	return versions
+}
+
+func TestStateStoreSaveAndDelete(t *testing.T) {
+	for name, open := range stateStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			_, err := store.Load(ctx, stateKey)
+			require.ErrorIs(t, err, ErrStateNotFound)
+
+			created, err := store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-1"}}, 0, "create")
+			require.NoError(t, err)
+			assert.Equal(t, 1, created.Version)
+
+			updated, err := store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-2"}}, 1, "update")
+			require.NoError(t, err)
+			assert.Equal(t, 2, updated.Version)
+
+			loaded, err := store.Load(ctx, stateKey)
+			require.NoError(t, err)
+			assert.Equal(t, StateActive, loaded.Status)
+			assert.Equal(t, "update", loaded.SubmissionID)
+			outputs, err := loaded.Outputs()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]map[string]any{"vpc": {"id": "vpc-2"}}, outputs)
+
+			deleted, err := store.Delete(ctx, stateKey, 2, "delete")
+			require.NoError(t, err)
+			assert.Equal(t, 3, deleted.Version)
+			assert.Equal(t, StateDeleted, deleted.Status)
+
+			states, err := store.List(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, states)
+			assert.Equal(t, []string{"ACTIVE by create", "ACTIVE by update", "DELETED by delete"}, historyVersions(t, store))
+		})
+	}
+}
+
+func TestStateStoreConflict(t *testing.T) {
+	for name, open := range stateStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			_, err := store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-1"}}, 0, "create")
+			require.NoError(t, err)
+
+			// A second create and an update over a version that moved on lose
+			_, err = store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-x"}}, 0, "other-create")
+			assert.ErrorIs(t, err, ErrStateConflict)
+			_, err = store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-2"}}, 1, "update")
+			require.NoError(t, err)
+			_, err = store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-3"}}, 1, "stale-update")
+			assert.ErrorIs(t, err, ErrStateConflict)
+			_, err = store.Delete(ctx, stateKey, 1, "stale-delete")
+			assert.ErrorIs(t, err, ErrStateConflict)
+
+			loaded, err := store.Load(ctx, stateKey)
+			require.NoError(t, err)
+			assert.Equal(t, 2, loaded.Version)
+			assert.Equal(t, "update", loaded.SubmissionID)
+			assert.Equal(t, []string{"ACTIVE by create", "ACTIVE by update"}, historyVersions(t, store))
+		})
+	}
+}
+
+func TestStateStoreRetryOfCommittedWrite(t *testing.T) {
+	for name, open := range stateStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			results := map[string]map[string]any{"vpc": {"id": "vpc-1"}}
+
+			// The activity's first attempt committed, its retry writes over the same version again
+			first, err := store.Save(ctx, stateKey, results, 0, "create")
+			require.NoError(t, err)
+			retried, err := store.Save(ctx, stateKey, results, 0, "create")
+			require.NoError(t, err)
+			assert.Equal(t, first.Version, retried.Version)
+
+			updated, err := store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-2"}}, 1, "update")
+			require.NoError(t, err)
+			retried, err = store.Save(ctx, stateKey, map[string]map[string]any{"vpc": {"id": "vpc-2"}}, 1, "update")
+			require.NoError(t, err)
+			assert.Equal(t, updated.Version, retried.Version)
+
+			_, err = store.Delete(ctx, stateKey, 2, "delete")
+			require.NoError(t, err)
+			deleted, err := store.Delete(ctx, stateKey, 2, "delete")
+			require.NoError(t, err)
+			assert.Equal(t, 3, deleted.Version)
+
+			// Each write is recorded once
+			assert.Equal(t, []string{"ACTIVE by create", "ACTIVE by update", "DELETED by delete"}, historyVersions(t, store))
+
+			// Only the submission that wrote the version gets it back, and only for the version it
+			// wrote over
+			_, err = store.Delete(ctx, stateKey, 2, "other-delete")
+			assert.ErrorIs(t, err, ErrStateConflict)
+			_, err = store.Save(ctx, stateKey, results, 1, "delete")
+			assert.ErrorIs(t, err, ErrStateConflict)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import "sort"
+
+// Statuses of a row in submission_steps
+const (
+	StepPending         = "PENDING"
+	StepStarted         = "STARTED"
+	StepRunning         = "RUNNING"
+	StepWaitingApproval = "WAITING_APPROVAL"
+	StepRetrying        = "RETRYING"
+	StepFailed          = "FAILED"
+	StepIgnored         = "IGNORED"
+	StepSuccess         = "SUCCESS"
+	StepSkipped         = "SKIPPED"
+	StepRolledBack      = "ROLLED_BACK"
+	StepRollbackFailed  = "ROLLBACK_FAILED"
+	StepCancelled       = "CANCELLED"
+)
+
+// stepTransitions lists the statuses a step can move to from each status. A failed step waits
+// for an operator to retry or ignore it, a successful one can only be rolled back. Cancelling the
+// submission cancels every step that has not finished yet and the failed ones still waiting.
+var stepTransitions = map[string][]string{
+	StepPending:         {StepStarted, StepSkipped, StepSuccess, StepFailed, StepCancelled},
+	StepStarted:         {StepRunning, StepWaitingApproval, StepSuccess, StepFailed, StepCancelled},
+	StepRunning:         {StepSuccess, StepFailed, StepCancelled},
+	StepWaitingApproval: {StepSuccess, StepFailed, StepCancelled},
+	StepFailed:          {StepRetrying, StepIgnored, StepCancelled},
+	StepRetrying:        {StepRunning, StepWaitingApproval, StepCancelled},
+	StepSuccess:         {StepRolledBack, StepRollbackFailed},
+}
+
+// Overall statuses of a submission
+const (
+	SubmissionRunning        = "RUNNING"
+	SubmissionCompleted      = "COMPLETED"
+	SubmissionFailed         = "FAILED"
+	SubmissionRolledBack     = "ROLLED_BACK"
+	SubmissionRollbackFailed = "ROLLBACK_FAILED"
+	SubmissionCancelled      = "CANCELLED"
+	SubmissionTerminated     = "TERMINATED"
+)
+
+// ValidStepTransition reports whether a step can move from one status to another. Writing the
+// status a step already has is allowed so the bookkeeping can be retried.
+func ValidStepTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, status := range stepTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StepFinished reports whether a step in the status is no longer being worked on
+func StepFinished(status string) bool {
+	switch status {
+	case StepFailed, StepIgnored, StepSuccess, StepSkipped, StepRolledBack, StepRollbackFailed, StepCancelled:
+		return true
+	}
+	return false
+}
+
+// CancellableStepStatuses returns the statuses a step can be cancelled from
+func CancellableStepStatuses() []string {
+	var statuses []string
+	for from := range stepTransitions {
+		if from != StepCancelled && ValidStepTransition(from, StepCancelled) {
+			statuses = append(statuses, from)
+		}
+	}
+	sort.Strings(statuses)
+	return statuses
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allStepStatuses = []string{
+	StepPending, StepStarted, StepRunning, StepWaitingApproval, StepRetrying, StepFailed,
+	StepIgnored, StepSuccess, StepSkipped, StepRolledBack, StepRollbackFailed, StepCancelled,
+}
+
+func TestValidStepTransitionEveryPair(t *testing.T) {
+	// Every move a step can make besides staying where it is, anything not listed is rejected
+	allowed := map[[2]string]bool{
+		{StepPending, StepStarted}:   true,
+		{StepPending, StepSkipped}:   true,
+		{StepPending, StepSuccess}:   true,
+		{StepPending, StepFailed}:    true,
+		{StepPending, StepCancelled}: true,
+
+		{StepStarted, StepRunning}:         true,
+		{StepStarted, StepWaitingApproval}: true,
+		{StepStarted, StepSuccess}:         true,
+		{StepStarted, StepFailed}:          true,
+		{StepStarted, StepCancelled}:       true,
+
+		{StepRunning, StepSuccess}:   true,
+		{StepRunning, StepFailed}:    true,
+		{StepRunning, StepCancelled}: true,
+
+		{StepWaitingApproval, StepSuccess}:   true,
+		{StepWaitingApproval, StepFailed}:    true,
+		{StepWaitingApproval, StepCancelled}: true,
+
+		{StepFailed, StepRetrying}:  true,
+		{StepFailed, StepIgnored}:   true,
+		{StepFailed, StepCancelled}: true,
+
+		{StepRetrying, StepRunning}:         true,
+		{StepRetrying, StepWaitingApproval}: true,
+		{StepRetrying, StepCancelled}:       true,
+
+		{StepSuccess, StepRolledBack}:     true,
+		{StepSuccess, StepRollbackFailed}: true,
+	}
+
+	for _, from := range allStepStatuses {
+		for _, to := range allStepStatuses {
+			want := from == to || allowed[[2]string{from, to}]
+			assert.Equal(t, want, ValidStepTransition(from, to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestValidStepTransitionRetryOfFailedStep(t *testing.T) {
+	path := []string{StepPending, StepStarted, StepRunning, StepFailed, StepRetrying, StepRunning, StepSuccess}
+	for i := 1; i < len(path); i++ {
+		assert.True(t, ValidStepTransition(path[i-1], path[i]), "%s -> %s", path[i-1], path[i])
+	}
+	// A failed step has to go through RETRYING to run again
+	assert.False(t, ValidStepTransition(StepFailed, StepRunning))
+	assert.False(t, ValidStepTransition(StepFailed, StepStarted))
+	assert.False(t, ValidStepTransition(StepRetrying, StepSuccess))
+}
+
+func TestValidStepTransitionSuccessOnlyRollsBack(t *testing.T) {
+	for _, to := range allStepStatuses {
+		want := to == StepSuccess || to == StepRolledBack || to == StepRollbackFailed
+		assert.Equal(t, want, ValidStepTransition(StepSuccess, to), "SUCCESS -> %s", to)
+	}
+}
+
+func TestTerminalStepStatuses(t *testing.T) {
+	for _, from := range []string{StepSkipped, StepIgnored, StepRolledBack, StepRollbackFailed, StepCancelled} {
+		for _, to := range allStepStatuses {
+			assert.Equal(t, from == to, ValidStepTransition(from, to), "%s -> %s", from, to)
+		}
+		assert.True(t, StepFinished(from), from)
+	}
+}
+
+func TestValidStepTransitionUnknownStatus(t *testing.T) {
+	assert.False(t, ValidStepTransition("DONE", StepSuccess))
+	assert.False(t, ValidStepTransition(StepPending, "DONE"))
+}
+
+func TestStepFinished(t *testing.T) {
+	finished := map[string]bool{
+		StepFailed: true, StepIgnored: true, StepSuccess: true, StepSkipped: true,
+		StepRolledBack: true, StepRollbackFailed: true, StepCancelled: true,
+	}
+	for _, status := range allStepStatuses {
+		assert.Equal(t, finished[status], StepFinished(status), status)
+	}
+}
+
+func TestCancellableStepStatuses(t *testing.T) {
+	assert.Equal(t, []string{
+		StepFailed, StepPending, StepRetrying, StepRunning, StepStarted, StepWaitingApproval,
+	}, CancellableStepStatuses())
+}
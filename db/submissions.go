@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSubmissionNotFound is returned when no submission has the ID
+	ErrSubmissionNotFound = errors.New("submission not found")
+	// ErrStepNotFound is returned when the submission has no step with the ID
+	ErrStepNotFound = errors.New("step not found in submission")
+	// ErrStepChanged is returned when a step's status changed since it was read
+	ErrStepChanged = errors.New("step was changed by another update")
+)
+
+// SubmissionFilter narrows ListSubmissions, empty fields match everything
+type SubmissionFilter struct {
+	Account      string
+	Project      string
+	DeploymentID string
+	Submitter    string
+	Action       string
+	Status       string
+	// CreatedFrom is inclusive and CreatedTo exclusive, zero times leave the range open
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	// Descending lists the newest submissions first
+	Descending bool
+	// After continues a listing after the submission the cursor points at
+	After *SubmissionCursor
+	// Limit caps the number of submissions returned, 0 returns all of them
+	Limit int
+}
+
+// SubmissionCursor is the position of a submission in a listing ordered by creation time, the ID
+// breaks ties between submissions created at the same time
+type SubmissionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// SubmissionRepository reads and writes submissions, their steps and the attempts of the steps.
+// main hands the Postgres one to the handlers and activities, tests can use a
+// MemorySubmissionRepository.
+type SubmissionRepository interface {
+	// CreateSubmission saves the submission together with its Steps
+	CreateSubmission(ctx context.Context, submission *Submission) error
+	// SetSubmissionRun records the Temporal run the submission was started as
+	SetSubmissionRun(ctx context.Context, id string, runID string) error
+	// UpdateSubmissionStatus sets the overall status of the submission
+	UpdateSubmissionStatus(ctx context.Context, id string, status string) error
+	// GetSubmission returns the submission with its steps, ErrSubmissionNotFound if there is none
+	GetSubmission(ctx context.Context, id string) (*Submission, error)
+	// ListSubmissions returns the submissions matching the filter ordered by creation time, without
+	// their steps
+	ListSubmissions(ctx context.Context, filter SubmissionFilter) ([]Submission, error)
+	// CountSteps returns the number of steps in each status for every one of the submissions
+	CountSteps(ctx context.Context, submissionIDs []string) (map[string]map[string]int, error)
+
+	// GetStep returns a step of the submission, ErrStepNotFound if there is none
+	GetStep(ctx context.Context, submissionID, stepID string) (*SubmissionStep, error)
+	// InsertStep adds the step unless the submission already has a step with its StepID
+	InsertStep(ctx context.Context, step *SubmissionStep) error
+	// UpdateStep saves the status, result, error, attempt and timestamps of the step if its status
+	// is still from, ErrStepChanged otherwise
+	UpdateStep(ctx context.Context, step *SubmissionStep, from string) error
+	// CancelSteps moves every step of the submission that can still be cancelled to CANCELLED with
+	// the reason as its error, and returns the IDs of the steps it cancelled
+	CancelSteps(ctx context.Context, submissionID string, reason string) ([]string, error)
+
+	// CreateAttempt saves a new run of a step
+	CreateAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error
+	// FinishAttempt saves the status, error, stderr and finish time of the attempt
+	FinishAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error
+	// ListAttempts returns every run of a step, oldest first
+	ListAttempts(ctx context.Context, submissionID, stepID string) ([]SubmissionStepAttempt, error)
+
+	// AppendEvent adds an event to the submission's stream and sets its ID and seq
+	AppendEvent(ctx context.Context, event *SubmissionEvent) error
+	// ListEvents returns up to limit events of the submission with a seq after afterSeq, in order
+	ListEvents(ctx context.Context, submissionID string, afterSeq int64, limit int) ([]SubmissionEvent, error)
+
+	// AppendLogs saves a chunk of a step's command output and sets its ID
+	AppendLogs(ctx context.Context, chunk *SubmissionStepLog) error
+	// ListLogs returns up to limit chunks of the attempt of the step with an ID after afterID, in
+	// order
+	ListLogs(ctx context.Context, submissionID, stepID string, attempt int, afterID int64, limit int) ([]SubmissionStepLog, error)
+}
+
+// PostgresSubmissionRepository keeps the submissions in the submissions, submission_steps and
+// submission_step_attempts tables
+type PostgresSubmissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresSubmissionRepository(gormDB *gorm.DB) (*PostgresSubmissionRepository, error) {
+	if gormDB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	return &PostgresSubmissionRepository{db: gormDB}, nil
+}
+
+func (p *PostgresSubmissionRepository) CreateSubmission(ctx context.Context, submission *Submission) error {
+	return p.db.WithContext(ctx).Create(submission).Error
+}
+
+func (p *PostgresSubmissionRepository) SetSubmissionRun(ctx context.Context, id string, runID string) error {
+	return p.updateSubmission(ctx, id, "run_id", runID)
+}
+
+func (p *PostgresSubmissionRepository) UpdateSubmissionStatus(ctx context.Context, id string, status string) error {
+	return p.updateSubmission(ctx, id, "status", status)
+}
+
+func (p *PostgresSubmissionRepository) updateSubmission(ctx context.Context, id string, column string, value any) error {
+	res := p.db.WithContext(ctx).Model(&Submission{}).Where("id = ?", id).Update(column, value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSubmissionNotFound
+	}
+	return nil
+}
+
+func (p *PostgresSubmissionRepository) GetSubmission(ctx context.Context, id string) (*Submission, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrSubmissionNotFound
+	}
+	var submission Submission
+	err = p.db.WithContext(ctx).Preload("Steps").First(&submission, "id = ?", parsedID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubmissionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (p *PostgresSubmissionRepository) ListSubmissions(ctx context.Context, filter SubmissionFilter) ([]Submission, error) {
+	query := p.db.WithContext(ctx)
+	for _, field := range [][2]string{
+		{"account", filter.Account},
+		{"project", filter.Project},
+		{"deployment_id", filter.DeploymentID},
+		{"submitter", filter.Submitter},
+		{"action", filter.Action},
+		{"status", filter.Status},
+	} {
+		if field[1] != "" {
+			query = query.Where(field[0]+" = ?", field[1])
+		}
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedTo)
+	}
+
+	if filter.Descending {
+		query = query.Order("created_at DESC, id DESC")
+		if filter.After != nil {
+			query = query.Where("(created_at, id) < (?, ?)", filter.After.CreatedAt, filter.After.ID)
+		}
+	} else {
+		query = query.Order("created_at, id")
+		if filter.After != nil {
+			query = query.Where("(created_at, id) > (?, ?)", filter.After.CreatedAt, filter.After.ID)
+		}
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var submissions []Submission
+	err := query.Find(&submissions).Error
+	return submissions, err
+}
+
+func (p *PostgresSubmissionRepository) CountSteps(ctx context.Context, submissionIDs []string) (map[string]map[string]int, error) {
+	counts := map[string]map[string]int{}
+	if len(submissionIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		SubmissionID string
+		Status       string
+		Count        int
+	}
+	err := p.db.WithContext(ctx).Model(&SubmissionStep{}).
+		Select("submission_id, status, count(*) AS count").
+		Where("submission_id IN ?", submissionIDs).
+		Group("submission_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if counts[row.SubmissionID] == nil {
+			counts[row.SubmissionID] = map[string]int{}
+		}
+		counts[row.SubmissionID][row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (p *PostgresSubmissionRepository) GetStep(ctx context.Context, submissionID, stepID string) (*SubmissionStep, error) {
+	var step SubmissionStep
+	err := p.db.WithContext(ctx).
+		Where("submission_id = ? AND step_id = ?", submissionID, stepID).
+		First(&step).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStepNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &step, nil
+}
+
+func (p *PostgresSubmissionRepository) InsertStep(ctx context.Context, step *SubmissionStep) error {
+	return p.db.WithContext(ctx).
+		Where("submission_id = ? AND step_id = ?", step.SubmissionID, step.StepID).
+		FirstOrCreate(step).Error
+}
+
+func (p *PostgresSubmissionRepository) UpdateStep(ctx context.Context, step *SubmissionStep, from string) error {
+	res := p.db.WithContext(ctx).Model(&SubmissionStep{}).
+		Where("id = ? AND status = ?", step.ID, from).
+		Select("status", "step_result", "error_message", "attempt", "started_at", "finished_at", "last_updated_at").
+		Updates(step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrStepChanged
+	}
+	return nil
+}
+
+func (p *PostgresSubmissionRepository) CancelSteps(ctx context.Context, submissionID string, reason string) ([]string, error) {
+	now := time.Now()
+	var stepIDs []string
+	err := p.db.WithContext(ctx).Raw(`UPDATE submission_steps
+		SET status = ?, error_message = ?, finished_at = ?, last_updated_at = ?
+		WHERE submission_id = ? AND status IN ?
+		RETURNING step_id`, StepCancelled, reason, now, now, submissionID, CancellableStepStatuses()).
+		Scan(&stepIDs).Error
+	return stepIDs, err
+}
+
+func (p *PostgresSubmissionRepository) CreateAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error {
+	return p.db.WithContext(ctx).Create(attempt).Error
+}
+
+func (p *PostgresSubmissionRepository) FinishAttempt(ctx context.Context, attempt *SubmissionStepAttempt) error {
+	return p.db.WithContext(ctx).Model(&SubmissionStepAttempt{}).
+		Where("id = ?", attempt.ID).
+		Select("status", "error", "stderr", "finished_at").
+		Updates(attempt).Error
+}
+
+func (p *PostgresSubmissionRepository) ListAttempts(ctx context.Context, submissionID, stepID string) ([]SubmissionStepAttempt, error) {
+	attempts := []SubmissionStepAttempt{}
+	err := p.db.WithContext(ctx).
+		Where("submission_id = ? AND step_id = ?", submissionID, stepID).
+		Order("started_at").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+// AppendEvent takes the event's seq from the submission's counter. The update keeps the
+// submission's row locked until the event is committed, so a concurrent append waits for it and
+// readers never see a seq before the ones below it.
+func (p *PostgresSubmissionRepository) AppendEvent(ctx context.Context, event *SubmissionEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var seq int64
+		err := tx.Raw(`UPDATE submissions SET last_event_seq = last_event_seq + 1 WHERE id = ? RETURNING last_event_seq`, event.SubmissionID).
+			Scan(&seq).Error
+		if err != nil {
+			return err
+		}
+		if seq == 0 {
+			return ErrSubmissionNotFound
+		}
+		event.Seq = seq
+		return tx.Create(event).Error
+	})
+}
+
+func (p *PostgresSubmissionRepository) ListEvents(ctx context.Context, submissionID string, afterSeq int64, limit int) ([]SubmissionEvent, error) {
+	events := []SubmissionEvent{}
+	err := p.db.WithContext(ctx).
+		Where("submission_id = ? AND seq > ?", submissionID, afterSeq).
+		Order("seq").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (p *PostgresSubmissionRepository) AppendLogs(ctx context.Context, chunk *SubmissionStepLog) error {
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now()
+	}
+	return p.db.WithContext(ctx).Create(chunk).Error
+}
+
+func (p *PostgresSubmissionRepository) ListLogs(ctx context.Context, submissionID, stepID string, attempt int, afterID int64, limit int) ([]SubmissionStepLog, error) {
+	chunks := []SubmissionStepLog{}
+	err := p.db.WithContext(ctx).
+		Where("submission_id = ? AND step_id = ? AND attempt = ? AND id > ?", submissionID, stepID, attempt, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&chunks).Error
+	return chunks, err
+}
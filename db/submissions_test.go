@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// repositories returns the SubmissionRepository implementations to run a test against, the
+// Postgres one only when usePostgres finds a server
+func repositories(t *testing.T) map[string]func(t *testing.T) SubmissionRepository {
+	t.Helper()
+	return map[string]func(t *testing.T) SubmissionRepository{
+		"memory": func(t *testing.T) SubmissionRepository {
+			return NewMemorySubmissionRepository()
+		},
+		"postgres": func(t *testing.T) SubmissionRepository {
+			usePostgres(t)
+			_, err := MigrateUp(context.Background())
+			require.NoError(t, err)
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: logger.Default.LogMode(logger.Silent),
+			})
+			require.NoError(t, err)
+			repository, err := NewPostgresSubmissionRepository(gormDB)
+			require.NoError(t, err)
+			return repository
+		},
+	}
+}
+
+func submissionIDs(submissions []Submission) []uuid.UUID {
+	ids := make([]uuid.UUID, len(submissions))
+	for i, submission := range submissions {
+		ids[i] = submission.ID
+	}
+	return ids
+}
+
+func TestListSubmissionsOrdersByCreationTimeThenID(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// The last three share a creation time, so only their IDs order them
+	submissions := []Submission{
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000009"), CreatedAt: start},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000008"), CreatedAt: start.Add(time.Second)},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), CreatedAt: start.Add(time.Minute)},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), CreatedAt: start.Add(time.Minute)},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000003"), CreatedAt: start.Add(time.Minute)},
+	}
+	oldestFirst := submissionIDs(submissions)
+	newestFirst := make([]uuid.UUID, len(oldestFirst))
+	for i, id := range oldestFirst {
+		newestFirst[len(oldestFirst)-1-i] = id
+	}
+
+	for name, open := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			repository := open(t)
+			ctx := context.Background()
+			// Created out of order, so neither implementation can lean on insertion order
+			for _, i := range []int{3, 0, 4, 2, 1} {
+				submission := submissions[i]
+				submission.Account = "acme"
+				submission.Status = SubmissionRunning
+				require.NoError(t, repository.CreateSubmission(ctx, &submission))
+			}
+
+			for _, descending := range []bool{false, true} {
+				want := oldestFirst
+				if descending {
+					want = newestFirst
+				}
+
+				listed, err := repository.ListSubmissions(ctx, SubmissionFilter{Descending: descending})
+				require.NoError(t, err)
+				assert.Equal(t, want, submissionIDs(listed), "descending %v", descending)
+
+				// Paging two at a time from a cursor in the middle of the tie gives the same order
+				var paged []uuid.UUID
+				filter := SubmissionFilter{Descending: descending, Limit: 2}
+				for {
+					page, err := repository.ListSubmissions(ctx, filter)
+					require.NoError(t, err)
+					paged = append(paged, submissionIDs(page)...)
+					if len(page) < filter.Limit {
+						break
+					}
+					last := page[len(page)-1]
+					filter.After = &SubmissionCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+				}
+				assert.Equal(t, want, paged, "paged, descending %v", descending)
+			}
+		})
+	}
+}
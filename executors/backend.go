@@ -0,0 +1,257 @@
+package executors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// State backends a submission or customer can choose
+const (
+	BackendLocal = "local"
+	BackendPG    = "pg"
+	BackendS3    = "s3"
+	BackendMinIO = "minio" // S3 backend with the settings a MinIO server needs
+	BackendHTTP  = "http"
+)
+
+// SupportedBackends lists the backend types the sandbox can generate configuration for
+var SupportedBackends = []string{BackendLocal, BackendPG, BackendS3, BackendMinIO, BackendHTTP}
+
+// backendConfigFile holds the generated settings passed to init with -backend-config
+const backendConfigFile = "backend.tfbackend"
+
+// CustomerBackends holds each customer's default state backend from customers.yaml with its
+// settings already expanded. Steps without a backend of their own look it up on the worker, so
+// the credentials never travel in the workflow input or its history.
+var CustomerBackends = map[string]*models.Backend{}
+
+// PostgresConnString is the pg backend's conn_str when the backend config has none, it is set to
+// the application's own database when the DB is initialised
+var PostgresConnString string
+
+// StateKey is where a step's state lives in the backend
+func StateKey(step models.Step) string {
+	return strings.Join([]string{
+		pathSegment(step.Customer),
+		pathSegment(step.Project),
+		pathSegment(step.DeploymentID),
+		pathSegment(step.ID),
+	}, "/")
+}
+
+// ValidateBackend checks the backend type and the settings it can not do without
+func ValidateBackend(backend *models.Backend) error {
+	if backend == nil {
+		return nil
+	}
+	switch backend.Type {
+	case BackendLocal:
+	case BackendPG:
+		if _, ok := backend.Config["conn_str"]; !ok && PostgresConnString == "" {
+			return fmt.Errorf("pg backend needs a conn_str")
+		}
+	case BackendS3, BackendMinIO:
+		if _, ok := backend.Config["bucket"]; !ok {
+			return fmt.Errorf("%s backend needs a bucket", backend.Type)
+		}
+		if _, ok := backend.Config["endpoint"]; backend.Type == BackendMinIO && !ok {
+			return fmt.Errorf("minio backend needs an endpoint")
+		}
+	case BackendHTTP:
+		if _, ok := backend.Config["address"]; !ok {
+			return fmt.Errorf("http backend needs an address")
+		}
+	default:
+		return fmt.Errorf("unsupported backend %q, expected one of %v", backend.Type, SupportedBackends)
+	}
+	return nil
+}
+
+// RegisterCustomerBackend validates a customer's default backend and expands its string settings
+// against the worker's environment, e.g. password: ${TF_HTTP_PASSWORD}
+func RegisterCustomerBackend(customer string, backend *models.Backend) error {
+	if err := ValidateBackend(backend); err != nil {
+		return err
+	}
+	config := make(map[string]any, len(backend.Config))
+	for key, value := range backend.Config {
+		config[key] = expandEnv(value)
+	}
+	CustomerBackends[customer] = &models.Backend{Type: backend.Type, Config: config}
+	return nil
+}
+
+// ValidateSubmittedBackend checks a backend that comes with a submission. A submission can only
+// choose where on the worker's side its state is kept, local files under StateRoot or the pg
+// backend in the application's own database. The state holds the outputs and often secrets, so
+// backends that store it on another path or host are only taken from the operator's
+// customers.yaml, whose settings are also the only ones expanded against the worker's environment.
+func ValidateSubmittedBackend(backend *models.Backend) error {
+	if err := ValidateBackend(backend); err != nil {
+		return err
+	}
+	if backend == nil {
+		return nil
+	}
+	if backend.Type != BackendLocal && backend.Type != BackendPG {
+		return fmt.Errorf("%s backends can only be configured as the customer's backend", backend.Type)
+	}
+	if len(backend.Config) > 0 {
+		return fmt.Errorf("backend settings %s can not be submitted, configure them as the customer's backend instead", strings.Join(sortedKeys(backend.Config), ", "))
+	}
+	return nil
+}
+
+func expandEnv(value any) any {
+	switch v := value.(type) {
+	case string:
+		return os.ExpandEnv(v)
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded[key] = expandEnv(item)
+		}
+		return expanded
+	case []any:
+		expanded := make([]any, len(v))
+		for i, item := range v {
+			expanded[i] = expandEnv(item)
+		}
+		return expanded
+	}
+	return value
+}
+
+// backendSettings returns the backend block type and the settings for the step's state. Backends
+// that keep every state in one place (pg) return the workspace the state is stored under.
+func backendSettings(backend *models.Backend, step models.Step) (string, map[string]any, string, error) {
+	if err := ValidateBackend(backend); err != nil {
+		return "", nil, "", err
+	}
+	settings := make(map[string]any, len(backend.Config))
+	for key, value := range backend.Config {
+		settings[key] = value
+	}
+	key := StateKey(step)
+
+	switch backend.Type {
+	case BackendLocal:
+		root, _ := settings["dir"].(string)
+		delete(settings, "dir")
+		if root == "" {
+			root = StateRoot
+		}
+		path, err := filepath.Abs(filepath.Join(root, key+".tfstate"))
+		if err != nil {
+			return "", nil, "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", nil, "", fmt.Errorf("failed to create state dir: %w", err)
+		}
+		settings["path"] = path
+		return BackendLocal, settings, "", nil
+	case BackendPG:
+		if _, ok := settings["conn_str"]; !ok {
+			settings["conn_str"] = PostgresConnString
+		}
+		// Workspace names can't contain slashes
+		return BackendPG, settings, strings.ReplaceAll(key, "/", "_"), nil
+	case BackendS3, BackendMinIO:
+		prefix, _ := settings["key_prefix"].(string)
+		delete(settings, "key_prefix")
+		settings["key"] = strings.TrimPrefix(prefix+"/"+key+".tfstate", "/")
+		if backend.Type == BackendMinIO {
+			endpoint := settings["endpoint"]
+			delete(settings, "endpoint")
+			settings["endpoints"] = map[string]any{"s3": endpoint}
+			setDefault(settings, "region", "us-east-1")
+			setDefault(settings, "use_path_style", true)
+			setDefault(settings, "skip_credentials_validation", true)
+			setDefault(settings, "skip_region_validation", true)
+			setDefault(settings, "skip_requesting_account_id", true)
+			setDefault(settings, "skip_metadata_api_check", true)
+			setDefault(settings, "skip_s3_checksum", true)
+		}
+		return BackendS3, settings, "", nil
+	default:
+		address := strings.TrimSuffix(fmt.Sprint(settings["address"]), "/") + "/" + key
+		settings["address"] = address
+		setDefault(settings, "lock_address", address)
+		setDefault(settings, "unlock_address", address)
+		return BackendHTTP, settings, "", nil
+	}
+}
+
+func setDefault(settings map[string]any, key string, value any) {
+	if _, ok := settings[key]; !ok {
+		settings[key] = value
+	}
+}
+
+// renderBackendSettings writes the settings in the HCL attribute syntax -backend-config files use
+func renderBackendSettings(settings map[string]any) string {
+	var b strings.Builder
+	for _, key := range sortedKeys(settings) {
+		fmt.Fprintf(&b, "%s = %s\n", key, hclValue(settings[key]))
+	}
+	return b.String()
+}
+
+func hclValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			parts = append(parts, fmt.Sprintf("%s = %s", key, hclValue(v[key])))
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, hclValue(item))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// InitWorkingDir runs init with the sandbox's generated backend configuration and, for backends
+// that store states by workspace, selects the step's workspace. binary is terraform or tofu.
+func (e *ExecutorBase) InitWorkingDir(ctx context.Context, binary string) error {
+	args := []string{"init", "-input=false"}
+	if e.BackendConfig != "" {
+		args = append(args, "-reconfigure", "-backend-config="+e.BackendConfig)
+	}
+	cmd := Command(ctx, binary, args...)
+	cmd.Dir = e.Workspace
+	if err := RunCommand(cmd, e.Logger, e.Output); err != nil {
+		return err
+	}
+	if e.StateWorkspace == "" {
+		return nil
+	}
+	e.Logger.Infof("Selecting state workspace %s", e.StateWorkspace)
+	cmd = Command(ctx, binary, "workspace", "select", "-or-create", e.StateWorkspace)
+	cmd.Dir = e.Workspace
+	return RunCommand(cmd, e.Logger, e.Output)
+}
@@ -0,0 +1,271 @@
+package executors
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// usePostgresConnString sets the application's database the pg backend falls back on
+func usePostgresConnString(t *testing.T, connString string) {
+	t.Helper()
+	previous := PostgresConnString
+	PostgresConnString = connString
+	t.Cleanup(func() { PostgresConnString = previous })
+}
+
+func TestValidateSubmittedBackend(t *testing.T) {
+	usePostgresConnString(t, "postgres://app@db/app")
+	tests := []struct {
+		name    string
+		backend *models.Backend
+		want    string
+	}{
+		{name: "none"},
+		{name: "local", backend: &models.Backend{Type: BackendLocal}},
+		{name: "pg in the application's database", backend: &models.Backend{Type: BackendPG}},
+		{
+			name:    "local dir",
+			backend: &models.Backend{Type: BackendLocal, Config: map[string]any{"dir": "/etc"}},
+			want:    "backend settings dir can not be submitted, configure them as the customer's backend instead",
+		},
+		{
+			name:    "pg conn_str",
+			backend: &models.Backend{Type: BackendPG, Config: map[string]any{"schema_name": "states", "conn_str": "postgres://attacker"}},
+			want:    "backend settings conn_str, schema_name can not be submitted, configure them as the customer's backend instead",
+		},
+		{
+			name:    "s3",
+			backend: &models.Backend{Type: BackendS3, Config: map[string]any{"bucket": "somewhere"}},
+			want:    "s3 backends can only be configured as the customer's backend",
+		},
+		{
+			name:    "minio",
+			backend: &models.Backend{Type: BackendMinIO, Config: map[string]any{"bucket": "states", "endpoint": "http://minio:9000"}},
+			want:    "minio backends can only be configured as the customer's backend",
+		},
+		{
+			name:    "http",
+			backend: &models.Backend{Type: BackendHTTP, Config: map[string]any{"address": "https://collector.example.com"}},
+			want:    "http backends can only be configured as the customer's backend",
+		},
+		{
+			name:    "unsupported",
+			backend: &models.Backend{Type: "consul"},
+			want:    `unsupported backend "consul", expected one of [local pg s3 minio http]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSubmittedBackend(tt.backend)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBackend(t *testing.T) {
+	usePostgresConnString(t, "")
+	tests := []struct {
+		name    string
+		backend *models.Backend
+		want    string
+	}{
+		{"pg without a database", &models.Backend{Type: BackendPG}, "pg backend needs a conn_str"},
+		{"s3 without a bucket", &models.Backend{Type: BackendS3}, "s3 backend needs a bucket"},
+		{"minio without a bucket", &models.Backend{Type: BackendMinIO, Config: map[string]any{"endpoint": "http://minio:9000"}}, "minio backend needs a bucket"},
+		{"minio without an endpoint", &models.Backend{Type: BackendMinIO, Config: map[string]any{"bucket": "states"}}, "minio backend needs an endpoint"},
+		{"http without an address", &models.Backend{Type: BackendHTTP}, "http backend needs an address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, ValidateBackend(tt.backend), tt.want)
+		})
+	}
+}
+
+func TestBackendSettings(t *testing.T) {
+	root := useSandboxRoots(t)
+	usePostgresConnString(t, "postgres://app@db/app")
+	step := models.Step{ID: "vpc", Customer: "acme", Project: "network", DeploymentID: "prod"}
+	defaultState, err := filepath.Abs(filepath.Join(root, "tfstate", "acme", "network", "prod", "vpc.tfstate"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		backend       *models.Backend
+		step          models.Step
+		wantType      string
+		wantSettings  map[string]any
+		wantWorkspace string
+	}{
+		{
+			name:         "local under the state root",
+			backend:      &models.Backend{Type: BackendLocal},
+			wantType:     BackendLocal,
+			wantSettings: map[string]any{"path": defaultState},
+		},
+		{
+			name:         "local in an operator configured dir",
+			backend:      &models.Backend{Type: BackendLocal, Config: map[string]any{"dir": filepath.Join(root, "states")}},
+			wantType:     BackendLocal,
+			wantSettings: map[string]any{"path": filepath.Join(root, "states", "acme", "network", "prod", "vpc.tfstate")},
+		},
+		{
+			name:     "local keeps every ID one directory deep",
+			backend:  &models.Backend{Type: BackendLocal},
+			step:     models.Step{ID: "vpc", Customer: "acme", Project: "../../etc", DeploymentID: "prod/x"},
+			wantType: BackendLocal,
+			wantSettings: map[string]any{
+				"path": filepath.Join(root, "tfstate", "acme", "____etc", "prod_x", "vpc.tfstate"),
+			},
+		},
+		{
+			name:          "pg in the application's database",
+			backend:       &models.Backend{Type: BackendPG},
+			wantType:      BackendPG,
+			wantSettings:  map[string]any{"conn_str": "postgres://app@db/app"},
+			wantWorkspace: "acme_network_prod_vpc",
+		},
+		{
+			name:          "pg with its own database",
+			backend:       &models.Backend{Type: BackendPG, Config: map[string]any{"conn_str": "postgres://states@pg/states", "schema_name": "tf"}},
+			wantType:      BackendPG,
+			wantSettings:  map[string]any{"conn_str": "postgres://states@pg/states", "schema_name": "tf"},
+			wantWorkspace: "acme_network_prod_vpc",
+		},
+		{
+			name:         "s3",
+			backend:      &models.Backend{Type: BackendS3, Config: map[string]any{"bucket": "states", "region": "eu-west-1"}},
+			wantType:     BackendS3,
+			wantSettings: map[string]any{"bucket": "states", "region": "eu-west-1", "key": "acme/network/prod/vpc.tfstate"},
+		},
+		{
+			name:         "s3 with a key prefix",
+			backend:      &models.Backend{Type: BackendS3, Config: map[string]any{"bucket": "states", "key_prefix": "terraform"}},
+			wantType:     BackendS3,
+			wantSettings: map[string]any{"bucket": "states", "key": "terraform/acme/network/prod/vpc.tfstate"},
+		},
+		{
+			name:     "minio defaults",
+			backend:  &models.Backend{Type: BackendMinIO, Config: map[string]any{"bucket": "states", "endpoint": "http://minio:9000"}},
+			wantType: BackendS3,
+			wantSettings: map[string]any{
+				"bucket":                      "states",
+				"key":                         "acme/network/prod/vpc.tfstate",
+				"endpoints":                   map[string]any{"s3": "http://minio:9000"},
+				"region":                      "us-east-1",
+				"use_path_style":              true,
+				"skip_credentials_validation": true,
+				"skip_region_validation":      true,
+				"skip_requesting_account_id":  true,
+				"skip_metadata_api_check":     true,
+				"skip_s3_checksum":            true,
+			},
+		},
+		{
+			name: "minio keeps configured settings",
+			backend: &models.Backend{Type: BackendMinIO, Config: map[string]any{
+				"bucket": "states", "endpoint": "http://minio:9000", "region": "eu-central-1", "use_path_style": false,
+			}},
+			wantType: BackendS3,
+			wantSettings: map[string]any{
+				"bucket":                      "states",
+				"key":                         "acme/network/prod/vpc.tfstate",
+				"endpoints":                   map[string]any{"s3": "http://minio:9000"},
+				"region":                      "eu-central-1",
+				"use_path_style":              false,
+				"skip_credentials_validation": true,
+				"skip_region_validation":      true,
+				"skip_requesting_account_id":  true,
+				"skip_metadata_api_check":     true,
+				"skip_s3_checksum":            true,
+			},
+		},
+		{
+			name:     "http",
+			backend:  &models.Backend{Type: BackendHTTP, Config: map[string]any{"address": "https://state.example.com/", "username": "tf"}},
+			wantType: BackendHTTP,
+			wantSettings: map[string]any{
+				"address":        "https://state.example.com/acme/network/prod/vpc",
+				"lock_address":   "https://state.example.com/acme/network/prod/vpc",
+				"unlock_address": "https://state.example.com/acme/network/prod/vpc",
+				"username":       "tf",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := step
+			if tt.step.ID != "" {
+				s = tt.step
+			}
+			blockType, settings, workspace, err := backendSettings(tt.backend, s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, blockType)
+			assert.Equal(t, tt.wantSettings, settings)
+			assert.Equal(t, tt.wantWorkspace, workspace)
+			if path, ok := settings["path"].(string); ok {
+				assert.DirExists(t, filepath.Dir(path))
+			}
+		})
+	}
+}
+
+func TestBackendSettingsLeavesConfigAlone(t *testing.T) {
+	backend := &models.Backend{Type: BackendS3, Config: map[string]any{"bucket": "states", "key_prefix": "tf"}}
+
+	_, _, _, err := backendSettings(backend, models.Step{ID: "vpc"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"bucket": "states", "key_prefix": "tf"}, backend.Config)
+}
+
+func TestRenderBackendSettings(t *testing.T) {
+	rendered := renderBackendSettings(map[string]any{
+		"bucket":         "states",
+		"password":       `p"ss\word`,
+		"use_path_style": true,
+		"max_retries":    5,
+		"endpoints":      map[string]any{"s3": "http://minio:9000", "sts": "http://sts:9000"},
+		"scopes":         []any{"read", "write"},
+		"token":          nil,
+	})
+
+	assert.Equal(t, `bucket = "states"
+endpoints = { s3 = "http://minio:9000", sts = "http://sts:9000" }
+max_retries = 5
+password = "p\"ss\\word"
+scopes = ["read", "write"]
+token = null
+use_path_style = true
+`, rendered)
+}
+
+func TestHCLValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"string", "eu-west-1", `"eu-west-1"`},
+		{"escaped string", "a\"b\nc", `"a\"b\nc"`},
+		{"bool", false, "false"},
+		{"integer", 3, "3"},
+		{"float", 1.5, "1.5"},
+		{"nil", nil, "null"},
+		{"empty map", map[string]any{}, "{  }"},
+		{"nested", map[string]any{"b": []any{1, "x"}, "a": map[string]any{"c": true}}, `{ a = { c = true }, b = [1, "x"] }`},
+		{"empty list", []any{}, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hclValue(tt.value))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package executors
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/surajsub/temporal-rest-dsl/models"
@@ -26,22 +27,22 @@ func NewBicepExecutor(customer, workspace, provider, resource, provisioner, acti
 	}
 }
 
-func (b *BicepExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (b *BicepExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 	b.Logger.Infof("Executing BicepExecutor with action %s and operation %s", b.Action, step.Operation)
 
 	switch b.Action {
 	case "create":
-		output, nil := b.ExecuteCreateOperation(step)
+		output, nil := b.ExecuteCreateOperation(ctx, step)
 		return output, nil
 	case "delete":
 		log.Printf("Starting 'destroy' operation for resource: %s", b.Resource)
-		err := b.Destroy()
+		err := b.Destroy(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("error during destroy: %v", err)
+			return nil, fmt.Errorf("error during destroy: %w", err)
 		}
 		return map[string]any{"status": "destroyed"}, nil
 	default:
-		return nil, fmt.Errorf("unsupported operation [ %s ] for Bicep", step.Operation)
+		return nil, unsupportedOperation(step.Operation, "Bicep")
 	}
 }
 
@@ -51,7 +52,7 @@ func (t *BicepExecutor) ValidateOperation(step models.Step) error {
 }
 
 // Apply applies the Bicep configuration and captures outputs
-func (t *BicepExecutor) Apply() (map[string]any, error) {
+func (t *BicepExecutor) Apply(ctx context.Context) (map[string]any, error) {
 	varArgs := FormatBicepVariables(t.Variables)
 
 	args := []string{
@@ -62,7 +63,7 @@ func (t *BicepExecutor) Apply() (map[string]any, error) {
 	}
 
 	args = append(args, varArgs...)
-	cmd := exec.Command("az", args...)
+	cmd := Command(ctx, "az", args...)
 	log.Printf("Executing command: %v in workspace: %s", cmd.Args, t.Workspace)
 	cmd.Dir = t.Workspace
 
@@ -90,24 +91,24 @@ func (t *BicepExecutor) Apply() (map[string]any, error) {
 }
 
 // Destroy destroys the Terraform-managed resources
-func (t *BicepExecutor) Destroy() error {
+func (t *BicepExecutor) Destroy(ctx context.Context) error {
 	varArgs := FormatBicepVariables(t.Variables)
 	log.Printf("Running 'terraform destroy' for resource: %s", t.Resource)
 	args := append([]string{"destroy", "-input=false", "-auto-approve"}, varArgs...)
-	cmd := exec.Command("terraform", args...)
+	cmd := Command(ctx, "terraform", args...)
 	cmd.Dir = t.Workspace
-	return RunCommand(cmd, t.Logger)
+	return RunCommand(cmd, t.Logger, t.Output)
 }
 
-func (b *BicepExecutor) ExecuteCreateOperation(step models.Step) (map[string]any, error) {
+func (b *BicepExecutor) ExecuteCreateOperation(ctx context.Context, step models.Step) (map[string]any, error) {
 	log.Printf("Executing Bicep [ ****** Execute Create ******** ]  for resource %s", b.Resource)
 	log.Printf("Starting 'deploy' operation for resource: %s", b.Resource)
 
 	log.Printf("Running the deploy with the following payload %v", b)
 
-	output, err := b.Apply()
+	output, err := b.Apply(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error during apply: %v", err)
+		return nil, fmt.Errorf("error during apply: %w", err)
 	}
 	return output, nil
 }
@@ -0,0 +1,72 @@
+package executors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Error types for failures that retrying the activity will not fix. Steps can list these, or any
+// other application error type, under retry.non_retryable.
+const (
+	ErrExecutorNotFound     = "ExecutorNotFound"
+	ErrUnsupportedOperation = "UnsupportedOperation"
+	ErrInvalidVariables     = "InvalidVariables"
+)
+
+// Terraform and OpenTofu diagnostics that mean the variables passed to the command were rejected
+var invalidVariableDiagnostics = []string{
+	"No value for required variable",
+	"Invalid value for variable",
+	"Invalid value for input variable",
+	"Value for undeclared variable",
+	"Variables not allowed",
+}
+
+// NewNonRetryableError returns an error that fails the step without Temporal retrying it
+func NewNonRetryableError(errType string, format string, args ...any) error {
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf(format, args...), errType, nil)
+}
+
+func unsupportedOperation(operation, executor string) error {
+	return NewNonRetryableError(ErrUnsupportedOperation, "unsupported operation %s for %s", operation, executor)
+}
+
+// commandError builds the error for a failed command, marking it non-retryable when stderr shows
+// the variables were rejected
+func commandError(err error, stderr string) error {
+	for _, diagnostic := range invalidVariableDiagnostics {
+		if strings.Contains(stderr, diagnostic) {
+			return NewNonRetryableError(ErrInvalidVariables, "command failed: %v\nstderr: %s", err, stderr)
+		}
+	}
+	return fmt.Errorf("command failed: %w\nstderr: %s", err, stderr)
+}
+
+// ActivityError keeps the type and retryability of an application error that was wrapped with
+// more context on its way up, Temporal only looks at the outermost error.
+func ActivityError(err error) error {
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || error(appErr) == err {
+		return err
+	}
+	if appErr.NonRetryable() {
+		return temporal.NewNonRetryableApplicationError(err.Error(), appErr.Type(), nil)
+	}
+	return temporal.NewApplicationError(err.Error(), appErr.Type())
+}
+
+// Stderr returns the stderr summary a failed command reported, empty when the error did not come
+// from a command
+func Stderr(err error) string {
+	if err == nil {
+		return ""
+	}
+	_, stderr, found := strings.Cut(err.Error(), "\nstderr: ")
+	if !found {
+		return ""
+	}
+	return stderr
+}
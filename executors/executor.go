@@ -1,6 +1,7 @@
 package executors
 
 import (
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/surajsub/temporal-rest-dsl/models"
@@ -8,8 +9,9 @@ import (
 	"log"
 )
 
+// Executor runs a step's operation. Execute stops its commands and requests when ctx is done.
 type Executor interface {
-	Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error)
+	Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error)
 	ValidateOperation(step models.Step) error
 }
 
@@ -24,6 +26,11 @@ type ExecutorBase struct {
 	Action              string
 	Operation           string
 	Logger              *logrus.Logger
+	// Set when the executor runs in a sandbox with a generated backend, see InitWorkingDir
+	BackendConfig  string
+	StateWorkspace string
+	// Output receives the output of the commands the executor runs, nil drops it
+	Output CommandOutput
 }
 
 // Constructor for ExecutorBase
@@ -46,7 +53,7 @@ func NewExecutorBase(customer, workspace, provider, resource, provisioner, actio
 	}
 }
 
-func (e *ExecutorBase) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (e *ExecutorBase) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 	// Default implementation
 
 	log.Printf("Executing operation %s for executor %s", e.Action, e.Provider)
@@ -32,12 +32,12 @@ func NewGitExecutor(customer, workspace, provider, resource, provisioner, action
 	}
 }
 
-func (g *GitExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (g *GitExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 	g.Logger.Infof("Executing GitExecutor with action %s and operation %s", g.Action, step.Operation)
 
 	if step.Operation == "create_issue" && g.Action == "create" {
 
-		issueURL,issueNumber, err := g.CreateGitHubIssue(payload)
+		issueURL,issueNumber, err := g.CreateGitHubIssue(ctx, payload)
 		if err != nil {
 			return nil, err
 		}
@@ -47,7 +47,7 @@ func (g *GitExecutor) Execute(step models.Step, executor string, payload map[str
 	}
 
 	if step.Operation == "poll_issue_status" && g.Action == "create" {
-		err := g.PollGitHubIssueStatus(payload, payload["token"].(string))
+		err := g.PollGitHubIssueStatus(ctx, payload, payload["token"].(string))
 		if err != nil {
 			return nil, err
 		}
@@ -59,7 +59,7 @@ func (g *GitExecutor) Execute(step models.Step, executor string, payload map[str
 
 		return nil, nil
 	}
-	return nil, errors.New("unsupported operation for GitExecutor")
+	return nil, unsupportedOperation(step.Operation, "GitExecutor")
 }
 
 func (g *GitExecutor) CheckOperation(step models.Step) error {
@@ -91,8 +91,7 @@ func (g *GitExecutor) ValidateOperation(step models.Step) error {
 	return nil
 }
 
-func (g *GitExecutor) CreateGitHubIssue(payload map[string]any) (string,string, error) {
-	ctx := context.Background()
+func (g *GitExecutor) CreateGitHubIssue(ctx context.Context, payload map[string]any) (string,string, error) {
 	client := CreateGitHubClient(payload["token"].(string))
 	g.Logger.Infof("Creating GitHub issue...for Project %s", g.Project)
 
@@ -114,7 +113,7 @@ func (g *GitExecutor) CreateGitHubIssue(payload map[string]any) (string,string,
 		return "", "",err
 	}
 
-	log.Printf("How to make this work ------ %s", issue.GetNumber())
+	log.Printf("How to make this work ------ %d", issue.GetNumber())
 
 	apiIssue, _, err := client.Issues.Get(ctx, repoOwner, repoName, int(issue.GetNumber()))
 	if err != nil {
@@ -128,8 +127,7 @@ func (g *GitExecutor) CreateGitHubIssue(payload map[string]any) (string,string,
 }
 
 
-func (g *GitExecutor) PollGitHubIssueStatus(payload map[string]any, token string) error {
-	ctx := context.Background()
+func (g *GitExecutor) PollGitHubIssueStatus(ctx context.Context, payload map[string]any, token string) error {
 	client := CreateGitHubClient(payload["token"].(string))
 	g.Logger.Infof("Polling GitHub issue...for Project %s", g.Project)
 
@@ -216,6 +214,10 @@ g.Logger.Infof("Printing the data issue number is %d", convertedIssue)
 		}
 
 		g.Logger.Infof("GitHub issue not yet closed, polling again...")
-		time.Sleep(2 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Minute):
+		}
 	}
 }
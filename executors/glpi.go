@@ -1,6 +1,7 @@
 package executors
 
 import (
+	"context"
 	"github.com/surajsub/temporal-rest-dsl/models"
 )
 
@@ -19,7 +20,7 @@ func NewGLPIExecutor(customer, workspace, provider, resource, provisioner, actio
 	}
 }
 
-func (g *GLPIExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (g *GLPIExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 
 	return nil, nil
 }
@@ -27,6 +28,6 @@ func (g *GLPIExecutor) Execute(step models.Step, executor string, payload map[st
 func (g *GLPIExecutor) ValidateOperation(step models.Step) error {
 
 	// What does the GLPI Executor need to validate
-	g.Logger.Info("Validating operation as provided in the input %s", step.Operation)
+	g.Logger.Infof("Validating operation as provided in the input %s", step.Operation)
 	return nil
 }
@@ -13,7 +13,9 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/sirupsen/logrus"
@@ -64,16 +66,26 @@ func CreateGitHubClient(token string) *github.Client {
 	return github.NewClient(tc)
 }
 
-// FormatVariables Utility function to format Terraform variable arguments
-func FormatVariables(variables map[string]any) []string {
-	var vars []string
-	//var singleQuote = "'"
-	for key, value := range variables {
-		vars = append(vars, fmt.Sprintf("-var=%s=%v", key, value))
-		//log.Printf("Formatted variables: %v", vars)
+// VariablesFile is the file the variables of a Terraform, OpenTofu or Infracost step are written
+// to. Both tools load *.auto.tfvars.json on their own, and JSON keeps lists, maps, numbers and
+// strings with spaces or quotes as they are.
+const VariablesFile = "dsl.auto.tfvars.json"
+
+// WriteVariables writes the executor's variables to VariablesFile in its workspace. The file can
+// hold secrets, so only the worker's user can read it.
+func (e *ExecutorBase) WriteVariables() error {
+	variables := e.Variables
+	if variables == nil {
+		variables = map[string]any{}
 	}
-
-	return vars
+	data, err := json.MarshalIndent(variables, "", "  ")
+	if err != nil {
+		return NewNonRetryableError(ErrInvalidVariables, "variables can not be written as JSON: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(e.Workspace, VariablesFile), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", VariablesFile, err)
+	}
+	return nil
 }
 
 // FormatBicepVariables Utility function to format binary variables
@@ -89,9 +101,9 @@ func FormatBicepVariables(variables map[string]any) []string {
 }
 
 // Utility function to capture Terraform outputs
-func CaptureTerraformOutputs(workspace string, logger *logrus.Logger) (map[string]any, error) {
+func CaptureTerraformOutputs(ctx context.Context, workspace string, logger *logrus.Logger) (map[string]any, error) {
 	logger.Infof("Capturing Terraform outputs for workspace: %s", workspace)
-	cmd := exec.Command("terraform", "output", "-json")
+	cmd := Command(ctx, "terraform", "output", "-json")
 	cmd.Dir = workspace
 
 	outputBytes, err := cmd.Output()
@@ -115,10 +127,10 @@ func CaptureTerraformOutputs(workspace string, logger *logrus.Logger) (map[strin
 }
 
 // Utility function to capture OpenTofu outputs
-func CaptureOpenTofuOutputs(workspace string, logger *logrus.Logger) (map[string]any, error) {
+func CaptureOpenTofuOutputs(ctx context.Context, workspace string, logger *logrus.Logger) (map[string]any, error) {
 
 	logger.Infof("Capturing Opentofu outputs for workspace: %s", workspace)
-	cmd := exec.Command("tofu", "output", "-json")
+	cmd := Command(ctx, "tofu", "output", "-json")
 	cmd.Dir = workspace
 
 	outputBytes, err := cmd.Output()
@@ -141,6 +153,22 @@ func CaptureOpenTofuOutputs(workspace string, logger *logrus.Logger) (map[string
 	return outputs, nil
 }
 
+// InterruptGrace is how long a cancelled command has to exit after SIGINT before it is killed.
+// Terraform and OpenTofu use it to release their state lock. EXECUTOR_INTERRUPT_GRACE overrides it.
+var InterruptGrace = durationOrDefault("EXECUTOR_INTERRUPT_GRACE", 30*time.Second)
+
+// Command returns a command bound to ctx. When ctx is done the command gets SIGINT and is killed
+// if it is still running InterruptGrace later, so a cancelled or timed out activity never leaves a
+// hung terraform apply behind.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = InterruptGrace
+	return cmd
+}
+
 // RunCommand
 
 // Utility function to run shell commands
@@ -157,16 +185,13 @@ func OLDRunCommand(cmd *exec.Cmd, logger *logrus.Logger) error {
 	return err
 }
 
-func RunCommand(cmd *exec.Cmd, logger *logrus.Logger) error {
-	var stderrBuf bytes.Buffer
-
-	// Capture both stdout and stderr
-	//cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+func RunCommand(cmd *exec.Cmd, logger *logrus.Logger, output CommandOutput) error {
+	stderrBuf, flush := captureOutput(cmd, output)
 
 	logger.Infof("Running command: %s", strings.Join(cmd.Args, " "))
 
 	err := cmd.Run()
+	flush()
 
 	//stdoutStr := stdoutBuf.String()
 	stderrStr := stderrBuf.String()
@@ -180,7 +205,7 @@ func RunCommand(cmd *exec.Cmd, logger *logrus.Logger) error {
 			logger.Errorf("stderr: %s", stderrStr)
 		}
 
-		return fmt.Errorf("command failed: %w\nstderr: %s", err, stderrStr)
+		return commandError(err, stderrStr)
 	}
 
 	//if stdoutStr != "" {
@@ -190,6 +215,80 @@ func RunCommand(cmd *exec.Cmd, logger *logrus.Logger) error {
 	return nil
 }
 
+// RunDetailedPlan runs a plan that was started with -detailed-exitcode. Exit code 2 means the
+// plan succeeded and found changes, so it is reported as changed rather than as a failure.
+func RunDetailedPlan(cmd *exec.Cmd, logger *logrus.Logger, output CommandOutput) (bool, error) {
+	stderrBuf, flush := captureOutput(cmd, output)
+
+	logger.Infof("Running command: %s", strings.Join(cmd.Args, " "))
+
+	err := cmd.Run()
+	flush()
+	if err == nil {
+		return false, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return true, nil
+	}
+
+	stderrStr := extractError(strings.TrimSpace(stderrBuf.String()))
+	logger.Errorf("Command failed: %v", err)
+	logger.Errorf("stderr: %s", stderrStr)
+	return false, commandError(err, stderrStr)
+}
+
+// CommandOutput receives every line the commands of an executor write, stream is stdout or stderr
+type CommandOutput interface {
+	WriteLine(stream, line string)
+}
+
+// captureOutput sends the command's stdout and stderr to output line by line and keeps stderr for
+// the error summary. Without an output stdout is dropped as before. The returned function hands
+// over a last line that did not end in a newline, call it once the command finished.
+func captureOutput(cmd *exec.Cmd, output CommandOutput) (*bytes.Buffer, func()) {
+	stderrBuf := &bytes.Buffer{}
+	if output == nil {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderrBuf)
+		return stderrBuf, func() {}
+	}
+	stdout := &lineWriter{stream: "stdout", output: output}
+	stderr := &lineWriter{stream: "stderr", output: output}
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderrBuf, stderr)
+	return stderrBuf, func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// lineWriter splits what is written to it into lines for a CommandOutput
+type lineWriter struct {
+	stream  string
+	output  CommandOutput
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.output.WriteLine(w.stream, strings.TrimSuffix(string(w.pending[:i]), "\r"))
+		w.pending = w.pending[i+1:]
+	}
+}
+
+func (w *lineWriter) flush() {
+	if len(w.pending) > 0 {
+		w.output.WriteLine(w.stream, strings.TrimSuffix(string(w.pending), "\r"))
+		w.pending = nil
+	}
+}
+
 // Get the Secrets
 
 func GetSecretsProvider(providerType string, config map[string]string) (SecretsProvider, error) {
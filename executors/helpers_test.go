@@ -0,0 +1,56 @@
+package executors
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestWriteVariables(t *testing.T) {
+	workspace := t.TempDir()
+	base := &ExecutorBase{Workspace: workspace, Variables: map[string]any{
+		"name":       `web "primary" server`,
+		"subnet_ids": []any{"subnet-1", "subnet-2"},
+		"count":      float64(3),
+		"enabled":    true,
+		"tags":       map[string]any{"team": "infra", "cost center": "ops"},
+	}}
+
+	require.NoError(t, base.WriteVariables())
+
+	path := filepath.Join(workspace, VariablesFile)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written map[string]any
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, base.Variables, written)
+}
+
+func TestWriteVariablesWithoutVariables(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, (&ExecutorBase{Workspace: workspace}).WriteVariables())
+
+	data, err := os.ReadFile(filepath.Join(workspace, VariablesFile))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(data))
+}
+
+func TestWriteVariablesNotJSON(t *testing.T) {
+	base := &ExecutorBase{Workspace: t.TempDir(), Variables: map[string]any{"callback": func() {}}}
+
+	err := base.WriteVariables()
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr), "got %v", err)
+	assert.Equal(t, ErrInvalidVariables, appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+}
@@ -2,6 +2,7 @@ package executors
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
@@ -27,7 +28,7 @@ func NewHTTPExecutor(config map[string]any) Executor {
 	}
 }
 
-func (h *HTTPExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (h *HTTPExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 
 	authToken, err := decrypt(h.Headers["Authorization"])
 	if err != nil {
@@ -53,7 +54,7 @@ func (h *HTTPExecutor) Execute(step models.Step, executor string, payload map[st
 
 	// Build the HTTP request
 	url := h.BaseURL
-	req, err := http.NewRequest(step.Operation, url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, step.Operation, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
 	}
@@ -129,7 +130,7 @@ func (h *HTTPExecutor) ValidateOperation(step models.Step) error {
 	}
 
 	if !supportedOperations[step.Operation] {
-		return fmt.Errorf("unsupported HTTP operation: %s", step.Operation)
+		return NewNonRetryableError(ErrUnsupportedOperation, "unsupported HTTP operation: %s", step.Operation)
 	}
 	return nil
 }
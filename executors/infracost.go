@@ -2,11 +2,11 @@ package executors
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/surajsub/temporal-rest-dsl/models"
@@ -27,12 +27,12 @@ func NewInfraCostExecutor(customer, workspace, provider, resource, provisioner,
 	}
 }
 
-func (ice *InfraCostExecutor) EstimateCost(operation, workspace string) (map[string]any, any) {
+func (ice *InfraCostExecutor) EstimateCost(ctx context.Context, operation, workspace string) (map[string]any, any) {
 	ice.Logger.Infof("Estimating cost with %s in workspace: %s with %s", operation, workspace, ice.Provisioner)
 	//log.Printf("Estimating cost with %s in workspace: %s with %s", operation, workspace, ice.Provisioner)
 
 	// Show the plan in JSON format
-	cmd := exec.Command(operation, "breakdown", "--path", "plan.json", "--fields", "all", "--format", "json", "--out-file", "output.json")
+	cmd := Command(ctx, operation, "breakdown", "--path", "plan.json", "--fields", "all", "--format", "json", "--out-file", "output.json")
 	cmd.Dir = workspace
 
 	err := cmd.Run()
@@ -58,7 +58,6 @@ func (ice *InfraCostExecutor) EstimateCost(operation, workspace string) (map[str
 
 	fmt.Printf("the parsed json is %s\n", parsedJSON["totalMonthlyCost"])
 
-	fmt.Sprintf("the total cost of the resource is %s %s\n", parsedJSON["totalMonthlyCost"], parsedJSON["currency"])
 	var totalCost = fmt.Sprintf("%s %s", parsedJSON["totalMonthlyCost"], parsedJSON["currency"])
 
 	// Return the extracted data
@@ -67,7 +66,7 @@ func (ice *InfraCostExecutor) EstimateCost(operation, workspace string) (map[str
 	}, nil
 }
 
-func (ice *InfraCostExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (ice *InfraCostExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 
 	log.Printf("Executing InfraCostExecutor with action %s", ice.Action)
 	//var costEstimate map[string]interface{}
@@ -80,27 +79,27 @@ func (ice *InfraCostExecutor) Execute(step models.Step, executor string, payload
 		//var provisioningExecutor = payload["provisioner"].(string)
 		ice.Logger.Infof("Executing Cost Estimate for resource %s using the executor %s", ice.Resource, executor)
 		ice.Logger.Infof("Starting 'deploy' operation for resource: %s", ice.Resource)
-		err := ice.Init(executor)
+		err := ice.Init(ctx, executor)
 		if err != nil {
-			return nil, fmt.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
 		}
 
-		err = ice.PlanOut(executor)
+		err = ice.PlanOut(ctx, executor)
 		if err != nil {
-			return nil, fmt.Errorf("error during planout: %v", err)
+			return nil, fmt.Errorf("error during planout: %w", err)
 		}
 
-		err = ice.Show(executor)
+		err = ice.Show(ctx, executor)
 		if err != nil {
-			return nil, fmt.Errorf("error during Terraform show: %v", err)
+			return nil, fmt.Errorf("error during Terraform show: %w", err)
 		}
 
-		costEstimate, _ := ice.EstimateCost(executor, ice.Workspace)
+		costEstimate, _ := ice.EstimateCost(ctx, executor, ice.Workspace)
 		ice.Logger.Infof("Cost Estimate for the resource: %v", costEstimate)
 		return costEstimate, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported operation %s for InfracostExecutor", step.Operation)
+		return nil, unsupportedOperation(step.Operation, "InfracostExecutor")
 	}
 
 }
@@ -113,31 +112,30 @@ func (ice *InfraCostExecutor) ValidateOperation(step models.Step) error {
 }
 
 // Init initializes in the specified workspace
-func (ice *InfraCostExecutor) Init(executor string) error {
+func (ice *InfraCostExecutor) Init(ctx context.Context, executor string) error {
 	//log.Printf("Initializing  init .. %s in workspace: %s with provisioner %s", executor, ice.Workspace, ice.Provisioner)
 	ice.Logger.Infof("Initializing %s in workspace: %s", executor, ice.Workspace)
-	args := append([]string{"init"})
-	cmd := exec.Command(ice.Provisioner, args...)
-	cmd.Dir = ice.Workspace
-	return RunCommand(cmd, ice.Logger)
+	return ice.InitWorkingDir(ctx, ice.Provisioner)
 }
 
 // PlanOut Run the plan and out runs the Terraform plan command
-func (ice *InfraCostExecutor) PlanOut(executor string) error {
-	varArgs := FormatVariables(ice.Variables)
+func (ice *InfraCostExecutor) PlanOut(ctx context.Context, executor string) error {
+	if err := ice.WriteVariables(); err != nil {
+		return err
+	}
 	ice.Logger.Infof("Running '%s plan and out' for resource: %s", ice.Provisioner, ice.Resource)
-	args := append([]string{"plan", "-input=false", "-out=plan.binary"}, varArgs...)
-	cmd := exec.Command(ice.Provisioner, args...)
+	args := []string{"plan", "-input=false", "-out=plan.binary"}
+	cmd := Command(ctx, ice.Provisioner, args...)
 	cmd.Dir = ice.Workspace
-	return RunCommand(cmd, ice.Logger)
+	return RunCommand(cmd, ice.Logger, ice.Output)
 }
 
 // Show Run the plan to convert to json
-func (ice *InfraCostExecutor) Show(executor string) error {
+func (ice *InfraCostExecutor) Show(ctx context.Context, executor string) error {
 	ice.Logger.Infof("Initializing %s in workspace: %s", executor, ice.Workspace)
 
 	// Define the command
-	cmd := exec.Command(ice.Provisioner, "show", "-json", "plan.binary")
+	cmd := Command(ctx, ice.Provisioner, "show", "-json", "plan.binary")
 
 	// Set the working directory
 	cmd.Dir = ice.Workspace
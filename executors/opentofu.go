@@ -1,10 +1,13 @@
 package executors
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/surajsub/temporal-rest-dsl/models"
 	"log"
-	"os/exec"
+	"os"
+	"path/filepath"
 )
 
 type OpenTFExecutor struct {
@@ -18,7 +21,7 @@ func NewOpenTFExecutor(customer, workspace, provider, resource, provisioner, act
 	}
 }
 
-func (o *OpenTFExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (o *OpenTFExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 	// Initialize the Opentofu executor
 
 	// Ensure Logger is not nil before usage
@@ -32,42 +35,101 @@ func (o *OpenTFExecutor) Execute(step models.Step, executor string, payload map[
 		o.Logger.Debugf("Starting 'create' operation for resource: %s", o.Resource)
 
 		// Initialize, plan, and apply Opentofu
-		err := o.Init()
+		err := o.Init(ctx)
 		if err != nil {
 			o.Logger.Errorf("error during init: %v", err)
-			return nil, fmt.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
 		}
 
-		err = o.Plan()
+		err = o.Plan(ctx)
 		if err != nil {
 			o.Logger.Errorf("error during plan: %v", err)
-			return nil, fmt.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
 		}
 
-		output, err := o.Apply()
+		output, err := o.Apply(ctx)
 		if err != nil {
 			o.Logger.Errorf("error during Apply: %v", err)
-			return nil, fmt.Errorf("error during apply: %v", err)
+			return nil, fmt.Errorf("error during apply: %w", err)
 		}
 		return output, nil
 	case "delete":
 		o.Logger.Infof("Starting 'delete' operation for resource: %s", o.Resource)
 
+		// The sandbox is a fresh copy of the module, init installs the providers and connects it
+		// to the deployment's state
+		err := o.Init(ctx)
+		if err != nil {
+			o.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
 		// Added the plan for the destroy operation.. else it would encounter a failure
-		err := o.Plan()
+		err = o.Plan(ctx)
 		if err != nil {
 			o.Logger.Errorf("error during Plan for Delete: %v", err)
-			return nil, fmt.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
 		}
-		err = o.Destroy()
+		err = o.Destroy(ctx)
 		if err != nil {
 			o.Logger.Errorf("error during Destroy for Delete: %v", err)
-			return nil, fmt.Errorf("error during destroy: %v", err)
+			return nil, fmt.Errorf("error during destroy: %w", err)
 		}
 		return map[string]any{"status": "destroyed"}, nil
+	case "update":
+		o.Logger.Infof("Starting 'update' operation for resource: %s", o.Resource)
+
+		err := o.Init(ctx)
+		if err != nil {
+			o.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
+		changed, err := o.PlanChanges(ctx)
+		if err != nil {
+			o.Logger.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
+		}
+
+		var output map[string]any
+		if changed {
+			output, err = o.Apply(ctx)
+			if err != nil {
+				o.Logger.Errorf("error during Apply: %v", err)
+				return nil, fmt.Errorf("error during apply: %w", err)
+			}
+		} else {
+			o.Logger.Infof("No changes for resource %s, skipping apply", o.Resource)
+			output, err = CaptureOpenTofuOutputs(ctx, o.Workspace, o.Logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to capture outputs: %w", err)
+			}
+		}
+		output[ChangedKey] = changed
+		return output, nil
+	case "plan":
+		o.Logger.Infof("Starting 'plan' operation for resource: %s", o.Resource)
+
+		err := o.Init(ctx)
+		if err != nil {
+			o.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
+		err = o.PlanOut(ctx)
+		if err != nil {
+			o.Logger.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
+		}
+
+		err = o.Show(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error during show: %w", err)
+		}
+		return ReadPlanSummary(filepath.Join(o.Workspace, "plan.json"))
 	default:
 		o.Logger.Errorf("unsupported operation %s for OpenTofuExecutor", step.Operation)
-		return nil, fmt.Errorf("unsupported operation %s for OpenTofuExecutor", step.Operation)
+		return nil, unsupportedOperation(step.Operation, "OpenTofuExecutor")
 	}
 }
 
@@ -91,38 +153,93 @@ func (o *OpenTFExecutor) destroy(payload map[string]any) (map[string]any, error)
 }
 
 // Init initializes OpenTofu in the specified workspace
-func (o *OpenTFExecutor) Init() error {
+func (o *OpenTFExecutor) Init(ctx context.Context) error {
 	log.Printf("Initializing OpenTofu in workspace: %s", o.Workspace)
-	cmd := exec.Command("tofu", "init")
-	cmd.Dir = o.Workspace
-	return RunCommand(cmd, o.Logger)
+	return o.InitWorkingDir(ctx, "tofu")
 }
 
 // Plan runs the OpenTofu plan command
-func (o *OpenTFExecutor) Plan() error {
-	varArgs := FormatVariables(o.Variables)
+func (o *OpenTFExecutor) Plan(ctx context.Context) error {
+	if err := o.WriteVariables(); err != nil {
+		return err
+	}
 	o.Logger.Infof("Running 'Opentofu plan' for resource : %s", o.Resource)
-	args := append([]string{"plan", "-input=false"}, varArgs...)
-	cmd := exec.Command("tofu", args...)
+	args := []string{"plan", "-input=false"}
+	cmd := Command(ctx, "tofu", args...)
+	cmd.Dir = o.Workspace
+	return RunCommand(cmd, o.Logger, o.Output)
+}
+
+// PlanChanges runs the OpenTofu plan command and reports whether it would change anything
+func (o *OpenTFExecutor) PlanChanges(ctx context.Context) (bool, error) {
+	if err := o.WriteVariables(); err != nil {
+		return false, err
+	}
+	o.Logger.Infof("Running 'Opentofu plan -detailed-exitcode' for resource : %s", o.Resource)
+	args := []string{"plan", "-input=false", "-detailed-exitcode"}
+	cmd := Command(ctx, "tofu", args...)
+	cmd.Dir = o.Workspace
+	return RunDetailedPlan(cmd, o.Logger, o.Output)
+}
+
+// PlanOut runs the OpenTofu plan command and saves the plan to plan.binary
+func (o *OpenTFExecutor) PlanOut(ctx context.Context) error {
+	if err := o.WriteVariables(); err != nil {
+		return err
+	}
+	o.Logger.Infof("Running 'Opentofu plan and out' for resource: %s", o.Resource)
+	args := []string{"plan", "-input=false", "-out=plan.binary"}
+	cmd := Command(ctx, "tofu", args...)
 	cmd.Dir = o.Workspace
-	return RunCommand(cmd, o.Logger)
+	return RunCommand(cmd, o.Logger, o.Output)
+}
+
+// Show converts plan.binary to plan.json
+func (o *OpenTFExecutor) Show(ctx context.Context) error {
+	o.Logger.Infof("Running 'tofu show and out' for resource: %s", o.Workspace)
+
+	cmd := Command(ctx, "tofu", "show", "-json", "plan.binary")
+	cmd.Dir = o.Workspace
+
+	outputFilePath := filepath.Join(o.Workspace, "plan.json")
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		o.Logger.Errorf("failed to create plan.json file: %v", err)
+		return fmt.Errorf("failed to create plan.json file: %w", err)
+	}
+	defer outputFile.Close()
+
+	cmd.Stdout = outputFile
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		o.Logger.Errorf("failed to run tofu show: %s:  %v", stderr.String(), err)
+		return fmt.Errorf("failed to run tofu show: %s:  %w", stderr.String(), err)
+	}
+
+	o.Logger.Debugf("OpenTofu plan successfully exported to: %s", outputFilePath)
+	return nil
 }
 
 // Apply applies the OpenTofu configuration and captures outputs
-func (o *OpenTFExecutor) Apply() (map[string]any, error) {
-	varArgs := FormatVariables(o.Variables)
-	args := append([]string{"apply", "-input=false", "-auto-approve"}, varArgs...)
-	cmd := exec.Command("tofu", args...)
+func (o *OpenTFExecutor) Apply(ctx context.Context) (map[string]any, error) {
+	if err := o.WriteVariables(); err != nil {
+		return nil, err
+	}
+	args := []string{"apply", "-input=false", "-auto-approve"}
+	cmd := Command(ctx, "tofu", args...)
 	o.Logger.Debugf("Executing command: %v in workspace: %s", cmd.Args, o.Workspace)
 
 	cmd.Dir = o.Workspace
 
-	err := RunCommand(cmd, o.Logger)
+	err := RunCommand(cmd, o.Logger, o.Output)
 	if err != nil {
 		return nil, err
 	}
 
-	outputs, err := CaptureOpenTofuOutputs(o.Workspace, o.Logger)
+	outputs, err := CaptureOpenTofuOutputs(ctx, o.Workspace, o.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture outputs: %w", err)
 	}
@@ -131,14 +248,16 @@ func (o *OpenTFExecutor) Apply() (map[string]any, error) {
 }
 
 // Destroy destroys the OpenTofu-managed resources
-func (o *OpenTFExecutor) Destroy() error {
-	varArgs := FormatVariables(o.Variables)
+func (o *OpenTFExecutor) Destroy(ctx context.Context) error {
+	if err := o.WriteVariables(); err != nil {
+		return err
+	}
 	o.Logger.Infof("Running 'opentofu destroy' for resource: %s", o.Resource)
-	args := append([]string{"destroy", "-input=false", "-auto-approve"}, varArgs...)
-	cmd := exec.Command("tofu", args...)
+	args := []string{"destroy", "-input=false", "-auto-approve"}
+	cmd := Command(ctx, "tofu", args...)
 	cmd.Dir = o.Workspace
 
-	err := RunCommand(cmd, o.Logger)
+	err := RunCommand(cmd, o.Logger, o.Output)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,91 @@
+package executors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// PlanKey is where the plan summary is stored in the result of a plan-only step
+const PlanKey = "plan"
+
+// terraformPlan is the subset of `terraform show -json` output we care about
+type terraformPlan struct {
+	PlannedValues struct {
+		Outputs map[string]struct {
+			Sensitive bool `json:"sensitive"`
+			Value     any  `json:"value"`
+		} `json:"outputs"`
+	} `json:"planned_values"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// PlanChange is a single resource the plan would touch
+type PlanChange struct {
+	Address string   `json:"address"`
+	Actions []string `json:"actions"`
+}
+
+// PlanSummary is what a plan-only step reports instead of applying
+type PlanSummary struct {
+	Add     int          `json:"add"`
+	Change  int          `json:"change"`
+	Destroy int          `json:"destroy"`
+	Changes []PlanChange `json:"changes"`
+}
+
+// ReadPlanSummary parses a plan.json written by `show -json` into the step result for a plan-only
+// run. Outputs whose values are already known at plan time are returned at the top level like they
+// would be after an apply, so downstream steps can still reference them.
+func ReadPlanSummary(planFile string) (map[string]any, error) {
+	content, err := os.ReadFile(planFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var plan terraformPlan
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+
+	summary := PlanSummary{Changes: []PlanChange{}}
+	for _, rc := range plan.ResourceChanges {
+		add, change, destroy := countActions(rc.Change.Actions)
+		if add+change+destroy == 0 {
+			continue
+		}
+		summary.Add += add
+		summary.Change += change
+		summary.Destroy += destroy
+		summary.Changes = append(summary.Changes, PlanChange{Address: rc.Address, Actions: rc.Change.Actions})
+	}
+
+	output := map[string]any{PlanKey: summary}
+	for name, out := range plan.PlannedValues.Outputs {
+		if out.Value != nil && !out.Sensitive {
+			output[name] = out.Value
+		}
+	}
+	return output, nil
+}
+
+// countActions maps the action list of a resource change to the add/change/destroy counters the
+// way terraform reports them, a replace counts as both an add and a destroy.
+func countActions(actions []string) (add, change, destroy int) {
+	for _, action := range actions {
+		switch action {
+		case "create":
+			add++
+		case "update":
+			change++
+		case "delete":
+			destroy++
+		}
+	}
+	return add, change, destroy
+}
@@ -0,0 +1,93 @@
+package executors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountActions(t *testing.T) {
+	tests := []struct {
+		name                 string
+		actions              []string
+		add, change, destroy int
+	}{
+		{"create", []string{"create"}, 1, 0, 0},
+		{"update", []string{"update"}, 0, 1, 0},
+		{"delete", []string{"delete"}, 0, 0, 1},
+		{"replace", []string{"delete", "create"}, 1, 0, 1},
+		{"create before destroy", []string{"create", "delete"}, 1, 0, 1},
+		{"no-op", []string{"no-op"}, 0, 0, 0},
+		{"read", []string{"read"}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, change, destroy := countActions(tt.actions)
+			assert.Equal(t, []int{tt.add, tt.change, tt.destroy}, []int{add, change, destroy})
+		})
+	}
+}
+
+func TestReadPlanSummary(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(planFile, []byte(`{
+		"planned_values": {
+			"outputs": {
+				"vpc_cidr": {"sensitive": false, "value": "10.0.0.0/16"},
+				"zones": {"sensitive": false, "value": ["a", "b"]},
+				"password": {"sensitive": true, "value": "hunter2"},
+				"vpc_id": {"sensitive": false}
+			}
+		},
+		"resource_changes": [
+			{"address": "aws_vpc.main", "change": {"actions": ["create"]}},
+			{"address": "aws_subnet.a", "change": {"actions": ["update"]}},
+			{"address": "aws_instance.web", "change": {"actions": ["delete", "create"]}},
+			{"address": "aws_s3_bucket.logs", "change": {"actions": ["no-op"]}},
+			{"address": "aws_eip.old", "change": {"actions": ["delete"]}}
+		]
+	}`), 0o600))
+
+	result, err := ReadPlanSummary(planFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		PlanKey: PlanSummary{
+			Add:     2,
+			Change:  1,
+			Destroy: 2,
+			Changes: []PlanChange{
+				{Address: "aws_vpc.main", Actions: []string{"create"}},
+				{Address: "aws_subnet.a", Actions: []string{"update"}},
+				{Address: "aws_instance.web", Actions: []string{"delete", "create"}},
+				{Address: "aws_eip.old", Actions: []string{"delete"}},
+			},
+		},
+		// Known at plan time and not sensitive
+		"vpc_cidr": "10.0.0.0/16",
+		"zones":    []any{"a", "b"},
+	}, result)
+}
+
+func TestReadPlanSummaryWithoutChanges(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(planFile, []byte(`{}`), 0o600))
+
+	result, err := ReadPlanSummary(planFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{PlanKey: PlanSummary{Changes: []PlanChange{}}}, result)
+}
+
+func TestReadPlanSummaryErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ReadPlanSummary(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "failed to read plan file")
+
+	invalid := filepath.Join(dir, "plan.json")
+	require.NoError(t, os.WriteFile(invalid, []byte("not json"), 0o600))
+	_, err = ReadPlanSummary(invalid)
+	assert.ErrorContains(t, err, "failed to parse plan file")
+}
@@ -15,7 +15,10 @@ const (
 	OPA       = "opa"
 	VAULT     = "vault"
 
+	DEPLOY          = "deploy"
 	DESTROY         = "destroy"
+	UPDATE          = "update"
+	PLAN            = "plan"
 	CostEstimate    = "cost_estimate"
 	REPORT          = "report"
 	CreateIssue     = "create_issue"
@@ -23,6 +26,9 @@ const (
 	CREATE          = "create"
 	DELETE          = "delete"
 	GETCREDS        = "getcreds"
+
+	// ChangedKey is added to the output of an update to report whether the apply changed anything
+	ChangedKey = "changed"
 )
 
 type ExecutorConstructor func(config map[string]any) Executor
@@ -45,7 +51,7 @@ func createBase(config map[string]any) *ExecutorBase {
 	logger.SetFormatter(&logrus.JSONFormatter{}) // Structured logging
 	logger.SetLevel(logrus.InfoLevel)
 	logger.Debugf("Initializing ExecutorBase with Logger")
-	return &ExecutorBase{
+	base := &ExecutorBase{
 		Customer:    config["customer"].(string),
 		Workspace:   config["workspace"].(string),
 		Provider:    config["provider"].(string),
@@ -56,6 +62,11 @@ func createBase(config map[string]any) *ExecutorBase {
 
 		Logger: logger,
 	}
+	// Optional, only set for steps that run in a sandbox
+	base.BackendConfig, _ = config["backend_config"].(string)
+	base.StateWorkspace, _ = config["state_workspace"].(string)
+	base.Output, _ = config["output"].(CommandOutput)
+	return base
 }
 
 // GetExecutor retrieves an executor from the registry
@@ -63,25 +74,70 @@ func GetExecutor(name string, config map[string]any) (Executor, error) {
 
 	constructor, exists := registry[name]
 	if !exists {
-		return nil, fmt.Errorf("executor %s not found", name)
+		return nil, NewNonRetryableError(ErrExecutorNotFound, "executor %s not found", name)
 	}
 	return constructor(config), nil
 }
 
+// HasExecutor reports whether an executor with the given name is registered
+func HasExecutor(name string) bool {
+	_, exists := registry[name]
+	return exists
+}
+
+// SupportedOperations returns the operations registered for an executor
+func SupportedOperations(name string) []string {
+	return supportedOperations[name]
+}
+
+// CanDestroy reports whether the executor has a delete path that undoes a create
+func CanDestroy(name string) bool {
+	for _, operation := range supportedOperations[name] {
+		if operation == DELETE || operation == DESTROY {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUpdate reports whether the executor can update a resource in place
+func CanUpdate(name string) bool {
+	for _, operation := range supportedOperations[name] {
+		if operation == UPDATE {
+			return true
+		}
+	}
+	return false
+}
+
+// CanPlan reports whether the executor can take part in a plan-only submission without changing
+// anything. Cost estimates only ever build a plan so they are included.
+func CanPlan(name string) bool {
+	if name == INFRACOST {
+		return true
+	}
+	for _, operation := range supportedOperations[name] {
+		if operation == PLAN {
+			return true
+		}
+	}
+	return false
+}
+
 // Init functions
 
 func init() {
 	// Register the Executors
 	RegisterExecutor(TERRAFORM, func(config map[string]any) Executor {
 		return &TerraformExecutor{ExecutorBase: createBase(config)}
-	}, []string{CREATE, DELETE})
+	}, []string{CREATE, DELETE, UPDATE, PLAN, DEPLOY, DESTROY})
 	RegisterExecutor(INFRACOST, func(config map[string]any) Executor {
 		return &InfraCostExecutor{ExecutorBase: createBase(config), Submitter: config["submitter"].(string), Project: config["project"].(string)}
 	}, []string{CostEstimate, REPORT})
 	RegisterExecutor(GIT, func(config map[string]any) Executor {
 		return &GitExecutor{ExecutorBase: createBase(config), Submitter: config["submitter"].(string), Project: config["project"].(string)}
 	}, []string{CreateIssue, PollIssueStatus})
-	RegisterExecutor(OPENTOFU, func(config map[string]any) Executor { return &OpenTFExecutor{ExecutorBase: createBase(config)} }, []string{CREATE, DELETE})
+	RegisterExecutor(OPENTOFU, func(config map[string]any) Executor { return &OpenTFExecutor{ExecutorBase: createBase(config)} }, []string{CREATE, DELETE, UPDATE, PLAN, DEPLOY, DESTROY})
 	RegisterExecutor(VAULT, func(config map[string]any) Executor {
 		return &VaultExecutor{ExecutorBase: createBase(config)}
 	}, []string{GETCREDS})
@@ -0,0 +1,230 @@
+package executors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// Cleanup policies for step sandboxes
+const (
+	CleanupAlways    = "always"
+	CleanupOnSuccess = "on_success"
+	CleanupNever     = "never"
+)
+
+// sandboxBackendFile is written into a sandbox to declare the backend block the generated
+// settings are for
+const sandboxBackendFile = "sandbox_backend.tf"
+
+var (
+	// SandboxRoot is where step sandboxes are created, SANDBOX_ROOT overrides it
+	SandboxRoot = envOrDefault("SANDBOX_ROOT", "./storage/sandboxes")
+	// StateRoot keeps the local Terraform/OpenTofu state of every deployment, STATE_ROOT overrides it
+	StateRoot = envOrDefault("STATE_ROOT", "./storage/tfstate")
+)
+
+// Files that belong to a single working directory and are never copied into a sandbox
+var workspaceOnlyFiles = []string{".terraform", "terraform.tfstate", "terraform.tfstate.backup", "plan.binary", "plan.json", sandboxBackendFile, backendConfigFile, VariablesFile}
+
+// NeedsSandbox reports whether the executor runs Terraform/OpenTofu in the step's workspace
+func NeedsSandbox(executor string) bool {
+	return executor == TERRAFORM || executor == OPENTOFU || executor == INFRACOST
+}
+
+// Sandbox is a private copy of a step's module source. It is keyed by submission and step so
+// concurrent submissions never share a .terraform dir or plan files, and its state is kept in the
+// configured backend (local files under StateRoot by default) under a key derived from
+// account/project/deployment/step so later updates and deletes of the deployment find it again.
+type Sandbox struct {
+	Dir     string
+	Cleanup string
+	// BackendConfig is the file passed to init with -backend-config, empty when the module
+	// manages its own backend
+	BackendConfig string
+	// StateWorkspace is the workspace to select for backends that key states by workspace
+	StateWorkspace string
+}
+
+// NewSandbox copies the step's workspace into a fresh sandbox. Copying again into an existing
+// sandbox (an activity retry) refreshes the configuration and keeps the .terraform dir.
+func NewSandbox(step models.Step, logger *logrus.Logger) (*Sandbox, error) {
+	if step.SubmissionID == "" {
+		return nil, fmt.Errorf("step %s has no submission id to key its sandbox", step.ID)
+	}
+	cleanup := step.WorkspaceCleanup
+	if cleanup == "" {
+		cleanup = CleanupAlways
+	}
+	sandbox := &Sandbox{
+		Dir:     filepath.Join(SandboxRoot, pathSegment(step.SubmissionID), pathSegment(step.ID)),
+		Cleanup: cleanup,
+	}
+
+	logger.Infof("Creating sandbox %s for step %s from %s", sandbox.Dir, step.ID, step.Workspace)
+	if err := CopyWorkspace(step.Workspace, sandbox.Dir); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox for step %s: %w", step.ID, err)
+	}
+
+	if step.Backend == nil {
+		step.Backend = CustomerBackends[step.Customer]
+	} else if err := ValidateSubmittedBackend(step.Backend); err != nil {
+		return nil, NewNonRetryableError(ErrInvalidVariables, "step %s: %v", step.ID, err)
+	}
+	declared, err := hasBackend(step.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	if declared && step.Backend == nil {
+		// The module manages its own state, nothing to isolate
+		logger.Infof("Module %s configures its own backend, leaving it alone", step.Workspace)
+		return sandbox, nil
+	}
+	if err := sandbox.writeBackend(step, declared); err != nil {
+		return nil, err
+	}
+	return sandbox, nil
+}
+
+// writeBackend generates the backend configuration for the step's state. The backend block is
+// only declared when the module does not declare one itself.
+func (s *Sandbox) writeBackend(step models.Step, declared bool) error {
+	backend := step.Backend
+	if backend == nil {
+		backend = &models.Backend{Type: BackendLocal}
+	}
+	blockType, settings, workspace, err := backendSettings(backend, step)
+	if err != nil {
+		return NewNonRetryableError(ErrInvalidVariables, "step %s: %v", step.ID, err)
+	}
+	if !declared {
+		block := fmt.Sprintf("terraform {\n  backend %q {}\n}\n", blockType)
+		if err := os.WriteFile(filepath.Join(s.Dir, sandboxBackendFile), []byte(block), 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, backendConfigFile), []byte(renderBackendSettings(settings)), 0600); err != nil {
+		return err
+	}
+	s.BackendConfig = backendConfigFile
+	s.StateWorkspace = workspace
+	return nil
+}
+
+// Close removes the sandbox according to its cleanup policy
+func (s *Sandbox) Close(succeeded bool, logger *logrus.Logger) {
+	if s.Cleanup == CleanupNever || (s.Cleanup == CleanupOnSuccess && !succeeded) {
+		logger.Infof("Keeping sandbox %s (cleanup: %s)", s.Dir, s.Cleanup)
+		return
+	}
+	if err := os.RemoveAll(s.Dir); err != nil {
+		logger.Warnf("Failed to remove sandbox %s: %v", s.Dir, err)
+		return
+	}
+	// Drop the submission's directory once its last sandbox is gone
+	_ = os.Remove(filepath.Dir(s.Dir))
+}
+
+// hasBackend reports whether any of the module's .tf files declares a backend block
+func hasBackend(workspace string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(workspace, "*.tf"))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		if strings.Contains(string(data), "backend \"") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CopyWorkspace copies the configuration in src to dst. Working files such as .terraform, plan
+// files and local state are left out so every copy starts clean.
+func CopyWorkspace(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && workspaceOnly(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func workspaceOnly(name string) bool {
+	for _, file := range workspaceOnlyFiles {
+		if name == file {
+			return true
+		}
+	}
+	// Numbered backups such as terraform.tfstate.1712345678.backup
+	return strings.HasPrefix(name, "terraform.tfstate")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// pathSegment makes an ID safe to use as a single directory name
+func pathSegment(id string) string {
+	if id == "" {
+		return "_"
+	}
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(id)
+}
+
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func durationOrDefault(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(envOrDefault(key, ""))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
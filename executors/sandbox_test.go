@@ -0,0 +1,94 @@
+package executors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// useSandboxRoots points the sandboxes and local states at a temporary directory
+func useSandboxRoots(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	sandboxRoot, stateRoot := SandboxRoot, StateRoot
+	SandboxRoot, StateRoot = filepath.Join(root, "sandboxes"), filepath.Join(root, "tfstate")
+	t.Cleanup(func() { SandboxRoot, StateRoot = sandboxRoot, stateRoot })
+	return root
+}
+
+// fakeBinary puts a script named binary on the PATH that records each call's working directory
+// and arguments, one call per line, in the returned file
+func fakeBinary(t *testing.T, binary string) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$(pwd) $*\" >> " + calls + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, binary), []byte(script), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+func TestDeleteInFreshSandboxInitsBackendBeforeDestroy(t *testing.T) {
+	tests := []struct {
+		executor string
+		binary   string
+		build    func(base *ExecutorBase) Executor
+	}{
+		{TERRAFORM, "terraform", func(base *ExecutorBase) Executor { return &TerraformExecutor{ExecutorBase: base} }},
+		{OPENTOFU, "tofu", func(base *ExecutorBase) Executor { return &OpenTFExecutor{ExecutorBase: base} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.executor, func(t *testing.T) {
+			root := useSandboxRoots(t)
+			calls := fakeBinary(t, tt.binary)
+			module := filepath.Join(root, "module")
+			require.NoError(t, os.MkdirAll(filepath.Join(module, ".terraform"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(module, "main.tf"), []byte(`resource "null_resource" "vpc" {}`), 0o644))
+
+			step := models.Step{
+				ID:           "vpc",
+				Executor:     tt.executor,
+				Workspace:    module,
+				Customer:     "acme",
+				Project:      "network",
+				DeploymentID: "prod",
+				SubmissionID: "submission-1",
+				Action:       "delete",
+			}
+			sandbox, err := NewSandbox(step, logrus.New())
+			require.NoError(t, err)
+			// Nothing of the module's own working directory comes along
+			assert.NoDirExists(t, filepath.Join(sandbox.Dir, ".terraform"))
+
+			base := NewExecutorBase(step.Customer, sandbox.Dir, "", step.ID, tt.binary, step.Action, "")
+			base.BackendConfig = sandbox.BackendConfig
+			result, err := tt.build(base).Execute(context.Background(), step, tt.executor, nil)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"status": "destroyed"}, result)
+
+			data, err := os.ReadFile(calls)
+			require.NoError(t, err)
+			dir, err := filepath.Abs(sandbox.Dir)
+			require.NoError(t, err)
+			assert.Equal(t, []string{
+				dir + " init -input=false -reconfigure -backend-config=" + backendConfigFile,
+				dir + " plan -input=false",
+				dir + " destroy -input=false -auto-approve",
+			}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+
+			// init is pointed at the state the create left under the deployment's key
+			backend, err := os.ReadFile(filepath.Join(sandbox.Dir, backendConfigFile))
+			require.NoError(t, err)
+			state, err := filepath.Abs(filepath.Join(StateRoot, "acme", "network", "prod", "vpc.tfstate"))
+			require.NoError(t, err)
+			assert.Equal(t, "path = \""+state+"\"\n", string(backend))
+		})
+	}
+}
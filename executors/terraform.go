@@ -2,11 +2,11 @@ package executors
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/surajsub/temporal-rest-dsl/models"
 
 	"os"
-	"os/exec"
 	"path/filepath"
 )
 
@@ -21,7 +21,7 @@ func NewTerraformExecutor(customer, workspace, provider, resource, provisioner,
 	}
 }
 
-func (t *TerraformExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]any, error) {
+func (t *TerraformExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]any, error) {
 	// Initialize the Terraform executor
 
 	// Ensure Logger is not nil before usage
@@ -35,42 +35,101 @@ func (t *TerraformExecutor) Execute(step models.Step, executor string, payload m
 		t.Logger.Debugf("Starting 'create' operation for resource: %s", t.Resource)
 
 		// Initialize, plan, and apply Terraform
-		err := t.Init()
+		err := t.Init(ctx)
 		if err != nil {
 			t.Logger.Errorf("error during init: %v", err)
 			return nil, fmt.Errorf("error during init: %w", err)
 		}
 
-		err = t.Plan()
+		err = t.Plan(ctx)
 		if err != nil {
-			t.Logger.Errorf("error during plan: %w", err)
+			t.Logger.Errorf("error during plan: %v", err)
 			return nil, fmt.Errorf("error during plan: %w", err)
 		}
 
-		output, err := t.Apply()
+		output, err := t.Apply(ctx)
 		if err != nil {
-			t.Logger.Errorf("error during Apply: %w", err)
+			t.Logger.Errorf("error during Apply: %v", err)
 			return nil, fmt.Errorf("error during apply: %w", err)
 		}
 		return output, nil
 	case "delete":
 		t.Logger.Infof("Starting 'delete' operation for resource: %s", t.Resource)
 
+		// The sandbox is a fresh copy of the module, init installs the providers and connects it
+		// to the deployment's state
+		err := t.Init(ctx)
+		if err != nil {
+			t.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
 		// Added the plan for the destroy operation.. else it would encounter a failure
-		err := t.Plan()
+		err = t.Plan(ctx)
 		if err != nil {
 			t.Logger.Errorf("error during Plan for Delete: %v", err)
-			return nil, fmt.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
 		}
-		err = t.Destroy()
+		err = t.Destroy(ctx)
 		if err != nil {
 			t.Logger.Errorf("error during Destroy for Delete: %v", err)
-			return nil, fmt.Errorf("error during destroy: %v", err)
+			return nil, fmt.Errorf("error during destroy: %w", err)
 		}
 		return map[string]any{"status": "destroyed"}, nil
+	case "update":
+		t.Logger.Infof("Starting 'update' operation for resource: %s", t.Resource)
+
+		err := t.Init(ctx)
+		if err != nil {
+			t.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
+		changed, err := t.PlanChanges(ctx)
+		if err != nil {
+			t.Logger.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
+		}
+
+		var output map[string]any
+		if changed {
+			output, err = t.Apply(ctx)
+			if err != nil {
+				t.Logger.Errorf("error during Apply: %v", err)
+				return nil, fmt.Errorf("error during apply: %w", err)
+			}
+		} else {
+			t.Logger.Infof("No changes for resource %s, skipping apply", t.Resource)
+			output, err = CaptureTerraformOutputs(ctx, t.Workspace, t.Logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to capture outputs: %w", err)
+			}
+		}
+		output[ChangedKey] = changed
+		return output, nil
+	case "plan":
+		t.Logger.Infof("Starting 'plan' operation for resource: %s", t.Resource)
+
+		err := t.Init(ctx)
+		if err != nil {
+			t.Logger.Errorf("error during init: %v", err)
+			return nil, fmt.Errorf("error during init: %w", err)
+		}
+
+		err = t.PlanOut(ctx)
+		if err != nil {
+			t.Logger.Errorf("error during plan: %v", err)
+			return nil, fmt.Errorf("error during plan: %w", err)
+		}
+
+		err = t.Show(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error during show: %w", err)
+		}
+		return ReadPlanSummary(filepath.Join(t.Workspace, "plan.json"))
 	default:
 		t.Logger.Errorf("unsupported operation %s for TerraformExecutor", step.Operation)
-		return nil, fmt.Errorf("unsupported operation %s for TerraformExecutor", step.Operation)
+		return nil, unsupportedOperation(step.Operation, "TerraformExecutor")
 	}
 }
 
@@ -82,41 +141,56 @@ func (t *TerraformExecutor) ValidateOperation(step models.Step) error {
 }
 
 // Init initializes Terraform in the specified workspace
-func (t *TerraformExecutor) Init() error {
+func (t *TerraformExecutor) Init(ctx context.Context) error {
 	t.Logger.Infof("Initializing Terraform in workspace: %s", t.Workspace)
-	cmd := exec.Command("terraform", "init")
-	cmd.Dir = t.Workspace
-	return RunCommand(cmd, t.Logger)
+	return t.InitWorkingDir(ctx, "terraform")
 
 }
 
 // Plan runs the Terraform plan command
-func (t *TerraformExecutor) Plan() error {
-	varArgs := FormatVariables(t.Variables)
+func (t *TerraformExecutor) Plan(ctx context.Context) error {
+	if err := t.WriteVariables(); err != nil {
+		return err
+	}
 	t.Logger.Infof("Running 'terraform plan' for resource : %s", t.Resource)
-	args := append([]string{"plan", "-input=false"}, varArgs...)
-	cmd := exec.Command("terraform", args...)
+	args := []string{"plan", "-input=false"}
+	cmd := Command(ctx, "terraform", args...)
 	cmd.Dir = t.Workspace
 	
-	return RunCommand(cmd, t.Logger)
+	return RunCommand(cmd, t.Logger, t.Output)
+}
+
+// PlanChanges runs the Terraform plan command and reports whether it would change anything
+func (t *TerraformExecutor) PlanChanges(ctx context.Context) (bool, error) {
+	if err := t.WriteVariables(); err != nil {
+		return false, err
+	}
+	t.Logger.Infof("Running 'terraform plan -detailed-exitcode' for resource : %s", t.Resource)
+	args := []string{"plan", "-input=false", "-detailed-exitcode"}
+	cmd := Command(ctx, "terraform", args...)
+	cmd.Dir = t.Workspace
+
+	return RunDetailedPlan(cmd, t.Logger, t.Output)
 }
 
 // Run the plan and out runs the Terraform plan command
-func (t *TerraformExecutor) PlanOut() error {
-	varArgs := FormatVariables(t.Variables)
+func (t *TerraformExecutor) PlanOut(ctx context.Context) error {
+	if err := t.WriteVariables(); err != nil {
+		return err
+	}
 	t.Logger.Infof("Running 'terraform plan and out' for resource: %s", t.Resource)
-	args := append([]string{"plan", "-input=false", "-out=plan.binary"}, varArgs...)
-	cmd := exec.Command("terraform", args...)
+	args := []string{"plan", "-input=false", "-out=plan.binary"}
+	cmd := Command(ctx, "terraform", args...)
 	cmd.Dir = t.Workspace
-	return RunCommand(cmd, t.Logger)
+	return RunCommand(cmd, t.Logger, t.Output)
 }
 
 // Run the plan to conver to json
-func (t *TerraformExecutor) Show() error {
+func (t *TerraformExecutor) Show(ctx context.Context) error {
 	t.Logger.Infof("Running 'terraform show and out' for resource: %s", t.Workspace)
 
 	// Define the command
-	cmd := exec.Command("terraform", "show", "-json", "plan.binary")
+	cmd := Command(ctx, "terraform", "show", "-json", "plan.binary")
 
 	// Set the working directory
 	cmd.Dir = t.Workspace
@@ -147,20 +221,22 @@ func (t *TerraformExecutor) Show() error {
 }
 
 // Apply applies the Terraform configuration and captures outputs
-func (t *TerraformExecutor) Apply() (map[string]any, error) {
-	varArgs := FormatVariables(t.Variables)
-	args := append([]string{"apply", "-input=false", "-auto-approve"}, varArgs...)
-	cmd := exec.Command("terraform", args...)
+func (t *TerraformExecutor) Apply(ctx context.Context) (map[string]any, error) {
+	if err := t.WriteVariables(); err != nil {
+		return nil, err
+	}
+	args := []string{"apply", "-input=false", "-auto-approve"}
+	cmd := Command(ctx, "terraform", args...)
 	t.Logger.Debugf("Executing command: %v in workspace: %s", cmd.Args, t.Workspace)
 
 	cmd.Dir = t.Workspace
 
-	err := RunCommand(cmd, t.Logger)
+	err := RunCommand(cmd, t.Logger, t.Output)
 	if err != nil {
 		return nil, err
 	}
 
-	outputs, err := CaptureTerraformOutputs(t.Workspace, t.Logger)
+	outputs, err := CaptureTerraformOutputs(ctx, t.Workspace, t.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture outputs: %w", err)
 	}
@@ -169,14 +245,16 @@ func (t *TerraformExecutor) Apply() (map[string]any, error) {
 }
 
 // Destroy destroys the Terraform-managed resources
-func (t *TerraformExecutor) Destroy() error {
-	varArgs := FormatVariables(t.Variables)
+func (t *TerraformExecutor) Destroy(ctx context.Context) error {
+	if err := t.WriteVariables(); err != nil {
+		return err
+	}
 	t.Logger.Infof("Running 'terraform destroy' for resource: %s", t.Resource)
-	args := append([]string{"destroy", "-input=false", "-auto-approve"}, varArgs...)
-	cmd := exec.Command("terraform", args...)
+	args := []string{"destroy", "-input=false", "-auto-approve"}
+	cmd := Command(ctx, "terraform", args...)
 	cmd.Dir = t.Workspace
 
-	err := RunCommand(cmd, t.Logger)
+	err := RunCommand(cmd, t.Logger, t.Output)
 	if err != nil {
 		return err
 	}
@@ -2,7 +2,6 @@ package executors
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -29,11 +28,11 @@ func NewVaultExecutor(customer, workspace, provider, resource, provisioner, acti
 	}
 }
 
-func (v *VaultExecutor) Execute(step models.Step, executor string, payload map[string]any) (map[string]interface{}, error) {
-	v.Logger.Infof("Executing Vault Executor with action %s and operation %s", v.Operation)
+func (v *VaultExecutor) Execute(ctx context.Context, step models.Step, executor string, payload map[string]any) (map[string]interface{}, error) {
+	v.Logger.Infof("Executing Vault Executor with action %s and operation %s", v.Action, v.Operation)
 
 	if step.ID == "getcreds" {
-		data, err := v.GetCredentialsFromVault(payload["url"].(string), payload["mount_path"].(string), payload["secret_path"].(string), step.SecretId, step.RoleID)
+		data, err := v.GetCredentialsFromVault(ctx, payload["url"].(string), payload["mount_path"].(string), payload["secret_path"].(string), step.SecretId, step.RoleID)
 		if err != nil {
 			return nil, err
 		}
@@ -41,7 +40,7 @@ func (v *VaultExecutor) Execute(step models.Step, executor string, payload map[s
 		return data, nil
 	}
 
-	return nil, errors.New("unsupported operation for Vault Executor")
+	return nil, unsupportedOperation(step.Operation, "Vault Executor")
 }
 
 func (v *VaultExecutor) ValidateOperation(step models.Step) error {
@@ -49,9 +48,8 @@ func (v *VaultExecutor) ValidateOperation(step models.Step) error {
 	return nil
 }
 
-func (v *VaultExecutor) GetCredentialsFromVault(url, path, secretpath, secretid, roleid string) (map[string]interface{}, error) {
+func (v *VaultExecutor) GetCredentialsFromVault(ctx context.Context, url, path, secretpath, secretid, roleid string) (map[string]interface{}, error) {
 
-	ctx := context.Background()
 	// prepare a client
 
 	tls := vault.TLSConfiguration{}
@@ -93,9 +91,8 @@ func (v *VaultExecutor) GetCredentialsFromVault(url, path, secretpath, secretid,
 
 	fmt.Printf("This is the token %s", client.SetToken(resp.Auth.ClientToken))
 
-	path = path // string | Location of the secret.
 	resp1, err := client.Secrets.KvV2Read(
-		context.Background(),
+		ctx,
 		secretpath,
 		vault.WithMountPath(path),
 	)
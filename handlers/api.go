@@ -2,25 +2,40 @@ package handlers
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
 	"go.temporal.io/sdk/client"
 	"net/http"
 )
 
-func RegisterRoutes(e *echo.Echo, getClient func() client.Client) {
+// API serves the REST endpoints from the repositories it is constructed with
+type API struct {
+	submissions db.SubmissionRepository
+	deployments db.DeploymentRepository
+	states      db.StateStore
+}
+
+func NewAPI(submissions db.SubmissionRepository, deployments db.DeploymentRepository, states db.StateStore) *API {
+	return &API{submissions: submissions, deployments: deployments, states: states}
+}
+
+func RegisterRoutes(e *echo.Echo, api *API, getClient func() client.Client) {
 	e.POST("/v1/provision", func(c echo.Context) error {
 		client := getClient()
 		if client == nil {
 			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
 		}
-		return SubmitWorkflowHandler(c, client)
+		return api.SubmitWorkflowHandler(c, client)
 	})
 
+	// Dry run of /v1/provision, does not need Temporal
+	e.POST("/v1/validate", ValidateWorkflowHandler)
+
 	e.GET("/v1/status/:submission_id", func(c echo.Context) error {
 		client := getClient()
 		if client == nil {
 			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
 		}
-		return GetSubmissionIDStatus(c, client)
+		return api.GetSubmissionIDStatus(c, client)
 	})
 
 	e.GET("/v1/newstatus/:workflow_id", func(c echo.Context) error {
@@ -35,6 +50,34 @@ func RegisterRoutes(e *echo.Echo, getClient func() client.Client) {
 		if client == nil {
 			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
 		}
-		return NewSendSignalHandler(c, client)
+		return api.NewSendSignalHandler(c, client)
+	})
+	e.GET("/v1/deployments", api.ListDeploymentsHandler)
+	e.GET("/v1/deployments/:id", api.GetDeploymentHandler)
+	e.GET("/v1/deployments/:id/history", api.GetDeploymentHistoryHandler)
+	e.GET("/v1/submissions", api.ListSubmissionsHandler)
+	e.GET("/v1/submissions/:id/steps/:step_id/attempts", api.GetStepAttemptsHandler)
+	e.GET("/v1/submissions/:id/steps/:step_id/logs", api.GetStepLogsHandler)
+	e.GET("/v1/submissions/:id/events", api.SubmissionEventsHandler)
+	e.POST("/v1/submissions/:id/cancel", func(c echo.Context) error {
+		client := getClient()
+		if client == nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
+		}
+		return api.CancelSubmissionHandler(c, client)
+	})
+	e.POST("/v1/submissions/:id/terminate", func(c echo.Context) error {
+		client := getClient()
+		if client == nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
+		}
+		return api.TerminateSubmissionHandler(c, client)
+	})
+	e.POST("/v1/submissions/:id/approvals/:step_id", func(c echo.Context) error {
+		client := getClient()
+		if client == nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Temporal client not available"})
+		}
+		return api.SubmitApprovalHandler(c, client)
 	})
 }
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+	"go.temporal.io/sdk/client"
+)
+
+// ApprovalRequest is the body of POST /v1/submissions/:id/approvals/:step_id
+type ApprovalRequest struct {
+	Decision string `json:"decision"`
+	Approver string `json:"approver"`
+	Comment  string `json:"comment"`
+}
+
+// SubmitApprovalHandler sends an approve/reject decision to an approval step of a running submission
+func (a *API) SubmitApprovalHandler(c echo.Context, temporalClient client.Client) error {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid submission ID"})
+	}
+	stepID := c.Param("step_id")
+
+	var payload ApprovalRequest
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid approval payload"})
+	}
+	if payload.Decision != workflows.DecisionApprove && payload.Decision != workflows.DecisionReject {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "decision must be approve or reject"})
+	}
+	if payload.Approver == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing approver"})
+	}
+
+	submission, err := a.submissions.GetSubmission(c.Request().Context(), parsedID.String())
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Submission not found"})
+	}
+	var step *db.SubmissionStep
+	for i := range submission.Steps {
+		if submission.Steps[i].StepID == stepID {
+			step = &submission.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Step not found in submission"})
+	}
+	if step.Type != workflows.StepTypeApproval {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Step " + stepID + " is not an approval step"})
+	}
+
+	signal := models.ApprovalSignal{
+		StepID:   stepID,
+		Decision: payload.Decision,
+		Approver: payload.Approver,
+		Comment:  payload.Comment,
+	}
+	err = temporalClient.SignalWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID, workflows.ApprovalSignalName, signal)
+	if err != nil {
+		log.Printf("Failed to signal workflow: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to send approval",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status":        "Approval Submitted",
+		"submission_id": parsedID.String(),
+		"step":          stepID,
+		"decision":      payload.Decision,
+		"approver":      payload.Approver,
+	})
+}
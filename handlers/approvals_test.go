@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+)
+
+func postApproval(t *testing.T, api *API, submissionID, stepID, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("id", "step_id")
+	c.SetParamValues(submissionID, stepID)
+	// Every request here is refused before the workflow would be signalled
+	require.NoError(t, api.SubmitApprovalHandler(c, nil))
+	return rec
+}
+
+func TestSubmitApprovalHandlerRejectsInvalidRequests(t *testing.T) {
+	api, repository := memoryAPI(t)
+	submission := db.Submission{
+		Status: db.SubmissionRunning,
+		Steps: []db.SubmissionStep{
+			{StepID: "vpc", Status: db.StepRunning},
+			{StepID: "sign_off", Type: workflows.StepTypeApproval, Status: db.StepPending},
+		},
+	}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+	id := submission.ID.String()
+	approve := `{"decision": "approve", "approver": "alice"}`
+
+	tests := []struct {
+		name   string
+		id     string
+		stepID string
+		body   string
+		want   int
+	}{
+		{"not an approval step", id, "vpc", approve, http.StatusBadRequest},
+		{"unknown step", id, "subnet", approve, http.StatusNotFound},
+		{"unknown submission", "00000000-0000-0000-0000-000000000001", "sign_off", approve, http.StatusNotFound},
+		{"invalid submission ID", "nope", "sign_off", approve, http.StatusBadRequest},
+		{"invalid decision", id, "sign_off", `{"decision": "maybe", "approver": "alice"}`, http.StatusBadRequest},
+		{"missing approver", id, "sign_off", `{"decision": "reject"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postApproval(t, api, tt.id, tt.stepID, tt.body)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
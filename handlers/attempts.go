@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// GetStepAttemptsHandler returns every run of a step in a submission, oldest first
+func (a *API) GetStepAttemptsHandler(c echo.Context) error {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid submission ID"})
+	}
+	stepID := c.Param("step_id")
+
+	step, err := a.submissions.GetStep(c.Request().Context(), parsedID.String(), stepID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Step not found in submission"})
+	}
+
+	attempts, err := a.submissions.ListAttempts(c.Request().Context(), parsedID.String(), stepID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch step attempts"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"submission_id": parsedID.String(),
+		"step_id":       stepID,
+		"status":        step.Status,
+		"attempts":      attempts,
+	})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+func TestGetStepAttemptsHandler(t *testing.T) {
+	api, repository := memoryAPI(t)
+	ctx := context.Background()
+	submission := db.Submission{
+		Status: db.SubmissionRunning,
+		Steps:  []db.SubmissionStep{{StepID: "vpc", Status: db.StepFailed}},
+	}
+	require.NoError(t, repository.CreateSubmission(ctx, &submission))
+	id := submission.ID.String()
+	for attempt := 1; attempt <= 2; attempt++ {
+		require.NoError(t, repository.CreateAttempt(ctx, &db.SubmissionStepAttempt{
+			SubmissionID: id,
+			StepID:       "vpc",
+			Attempt:      attempt,
+			Status:       db.StepFailed,
+			StartedAt:    time.Now(),
+		}))
+	}
+
+	rec := serve(t, api.GetStepAttemptsHandler, "/", "id", id, "step_id", "vpc")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Status   string                     `json:"status"`
+		Attempts []db.SubmissionStepAttempt `json:"attempts"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, db.StepFailed, body.Status)
+	require.Len(t, body.Attempts, 2)
+	assert.Equal(t, 1, body.Attempts[0].Attempt)
+	assert.Equal(t, 2, body.Attempts[1].Attempt)
+}
+
+func TestGetStepAttemptsHandlerNotFound(t *testing.T) {
+	api, repository := memoryAPI(t)
+	submission := db.Submission{Steps: []db.SubmissionStep{{StepID: "vpc", Status: db.StepPending}}}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+
+	for name, params := range map[string][]string{
+		"unknown step":       {"id", submission.ID.String(), "step_id", "subnet"},
+		"unknown submission": {"id", uuid.NewString(), "step_id", "vpc"},
+	} {
+		rec := serve(t, api.GetStepAttemptsHandler, "/", params...)
+		assert.Equal(t, http.StatusNotFound, rec.Code, name)
+	}
+
+	rec := serve(t, api.GetStepAttemptsHandler, "/", "id", "not-a-uuid", "step_id", "vpc")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+	"go.temporal.io/sdk/client"
+)
+
+// CancelRequest is the optional body of POST /v1/submissions/:id/cancel and /terminate
+type CancelRequest struct {
+	RequestedBy string `json:"requested_by"`
+	Reason      string `json:"reason"`
+}
+
+// CancelSubmissionHandler asks a running submission to stop. The workflow starts no new steps,
+// interrupts the running ones and marks the rest CANCELLED. With ?cleanup=true a create also
+// destroys what it already created.
+func (a *API) CancelSubmissionHandler(c echo.Context, temporalClient client.Client) error {
+	submission, payload, status, err := a.runningSubmission(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	cleanup := false
+	if value := c.QueryParam("cleanup"); value != "" {
+		if cleanup, err = strconv.ParseBool(value); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "cleanup must be true or false"})
+		}
+	}
+	if cleanup && submission.Action != "create" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cleanup is only supported for create submissions"})
+	}
+
+	// The workflow reads the signal when it sees the cancellation, so it has to go first
+	signal := models.CancelSignal{Cleanup: cleanup, RequestedBy: payload.RequestedBy, Reason: payload.Reason}
+	err = temporalClient.SignalWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID, workflows.CancelSignalName, signal)
+	if err != nil {
+		log.Printf("Failed to signal workflow: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to send cancellation"})
+	}
+	if err := temporalClient.CancelWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID); err != nil {
+		log.Printf("Failed to cancel workflow: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel submission"})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":        "Cancellation Requested",
+		"submission_id": submission.ID.String(),
+		"cleanup":       cleanup,
+		"requested_by":  payload.RequestedBy,
+	})
+}
+
+// TerminateSubmissionHandler force-stops a submission whose workflow no longer reacts to a
+// cancellation. Nothing runs in the workflow afterwards, so the steps are marked CANCELLED here
+// and whatever was created is left as it is.
+func (a *API) TerminateSubmissionHandler(c echo.Context, temporalClient client.Client) error {
+	submission, payload, status, err := a.runningSubmission(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	reason := "terminated"
+	if payload.RequestedBy != "" {
+		reason += " by " + payload.RequestedBy
+	}
+	if payload.Reason != "" {
+		reason += ": " + payload.Reason
+	}
+	if err := temporalClient.TerminateWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID, reason); err != nil {
+		log.Printf("Failed to terminate workflow: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to terminate submission"})
+	}
+
+	// The workflow is gone, finish the bookkeeping even if the client hangs up
+	ctx := context.Background()
+	cancelled, err := a.submissions.CancelSteps(ctx, submission.ID.String(), "submission was "+reason)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Submission terminated but its steps could not be updated"})
+	}
+	for _, stepID := range cancelled {
+		a.appendEvent(ctx, &db.SubmissionEvent{SubmissionID: submission.ID.String(), StepID: stepID, Type: db.EventStepStatus, Status: db.StepCancelled, Message: "submission was " + reason})
+	}
+	a.appendEvent(ctx, &db.SubmissionEvent{SubmissionID: submission.ID.String(), Type: db.EventSubmissionStatus, Status: db.SubmissionTerminated, Message: reason})
+	if err := a.submissions.UpdateSubmissionStatus(ctx, submission.ID.String(), db.SubmissionTerminated); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Submission terminated but its status could not be updated"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":          "Terminated",
+		"submission_id":   submission.ID.String(),
+		"cancelled_steps": len(cancelled),
+		"requested_by":    payload.RequestedBy,
+	})
+}
+
+// runningSubmission loads the submission named in the path along with the optional request body
+func (a *API) runningSubmission(c echo.Context) (*db.Submission, CancelRequest, int, error) {
+	var payload CancelRequest
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, payload, http.StatusBadRequest, errors.New("Invalid submission ID")
+	}
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(&payload); err != nil {
+			return nil, payload, http.StatusBadRequest, errors.New("Invalid cancel payload")
+		}
+	}
+
+	submission, err := a.submissions.GetSubmission(c.Request().Context(), parsedID.String())
+	if err != nil {
+		return nil, payload, http.StatusNotFound, errors.New("Submission not found")
+	}
+	if submission.Status != db.SubmissionRunning {
+		return nil, payload, http.StatusConflict, fmt.Errorf("Submission is %s, only running submissions can be stopped", submission.Status)
+	}
+	return submission, payload, http.StatusOK, nil
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+)
+
+// ListDeploymentsHandler returns the deployments, optionally filtered by account, project and status
+func (a *API) ListDeploymentsHandler(c echo.Context) error {
+	deployments, err := a.deployments.ListDeployments(c.Request().Context(), db.DeploymentFilter{
+		Account: c.QueryParam("account"),
+		Project: c.QueryParam("project"),
+		Status:  c.QueryParam("status"),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch deployments"})
+	}
+	if deployments == nil {
+		deployments = []db.Deployment{}
+	}
+	return c.JSON(http.StatusOK, deployments)
+}
+
+// GetDeploymentHandler returns a deployment with the current outputs of its steps
+func (a *API) GetDeploymentHandler(c echo.Context) error {
+	deployment, status, err := a.findDeployment(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	outputs := map[string]map[string]any{}
+	state, err := a.states.Load(c.Request().Context(), deployment.Key())
+	switch {
+	case errors.Is(err, db.ErrStateNotFound):
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load deployment state"})
+	default:
+		if outputs, err = state.Outputs(); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"deployment": deployment,
+		"outputs":    outputs,
+	})
+}
+
+// GetDeploymentHistoryHandler returns the submissions that ran against a deployment and every
+// version of its state, oldest first
+func (a *API) GetDeploymentHistoryHandler(c echo.Context) error {
+	deployment, status, err := a.findDeployment(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	submissions, err := a.submissions.ListSubmissions(c.Request().Context(), db.SubmissionFilter{
+		Account:      deployment.Account,
+		Project:      deployment.Project,
+		DeploymentID: deployment.DeploymentID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch submissions"})
+	}
+	versions, err := a.states.History(c.Request().Context(), deployment.Key())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch state history"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"deployment_id":  deployment.ID,
+		"submissions":    submissions,
+		"state_versions": versions,
+	})
+}
+
+func (a *API) findDeployment(c echo.Context) (*db.Deployment, int, error) {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("Invalid deployment ID")
+	}
+	deployment, err := a.deployments.GetDeployment(c.Request().Context(), parsedID.String())
+	if errors.Is(err, db.ErrDeploymentNotFound) {
+		return nil, http.StatusNotFound, errors.New("Deployment not found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to fetch deployment")
+	}
+	// The definition is internal, it is only read to fill in a delete
+	deployment.Definition = nil
+	return deployment, http.StatusOK, nil
+}
+
+// rejectExistingDeployment refuses a create for a deployment that already has active state, the
+// workflow would fail it anyway
+func (a *API) rejectExistingDeployment(c echo.Context, input workflows.WorkflowInput) (int, error) {
+	if input.Action != "create" {
+		return http.StatusOK, nil
+	}
+	key := db.DeploymentKey{Account: input.Account, Project: input.Project, DeploymentID: input.DeploymentId}
+	state, err := a.states.Load(c.Request().Context(), key)
+	switch {
+	case errors.Is(err, db.ErrStateNotFound):
+	case err != nil:
+		return http.StatusInternalServerError, errors.New("Failed to load deployment state")
+	case state.Status == db.StateActive:
+		return http.StatusConflict, fmt.Errorf("deployment %s already exists, submit action: update to change it", input.DeploymentId)
+	}
+	return http.StatusOK, nil
+}
+
+// fillFromDeployment completes a delete submitted without steps from the definition the
+// deployment was last created or updated with
+func (a *API) fillFromDeployment(c echo.Context, input *workflows.WorkflowInput) (int, error) {
+	if input.Action != "delete" || len(input.Steps) > 0 {
+		return http.StatusOK, nil
+	}
+
+	key := db.DeploymentKey{Account: input.Account, Project: input.Project, DeploymentID: input.DeploymentId}
+	deployment, err := a.deployments.FindDeployment(c.Request().Context(), key)
+	if errors.Is(err, db.ErrDeploymentNotFound) {
+		return http.StatusNotFound, fmt.Errorf("deployment %s not found, a delete without steps needs an existing deployment", input.DeploymentId)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to fetch deployment")
+	}
+	if deployment.Status == db.DeploymentDestroyed {
+		return http.StatusConflict, fmt.Errorf("deployment %s is already destroyed", input.DeploymentId)
+	}
+	if len(deployment.Definition) == 0 {
+		return http.StatusConflict, fmt.Errorf("deployment %s was never created successfully, submit the steps to delete", input.DeploymentId)
+	}
+
+	var definition workflows.WorkflowInput
+	if err := json.Unmarshal(deployment.Definition, &definition); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("invalid definition for deployment %s: %w", input.DeploymentId, err)
+	}
+	input.Steps = definition.Steps
+	if input.WorkflowName == "" {
+		input.WorkflowName = definition.WorkflowName
+	}
+	// A backend submitted with the create stays with the deployment, the customer default is
+	// resolved on the worker
+	if input.Backend == nil {
+		input.Backend = definition.Backend
+	}
+	if input.WorkspaceCleanup == "" {
+		input.WorkspaceCleanup = definition.WorkspaceCleanup
+	}
+	if input.MaxParallelism == 0 {
+		input.MaxParallelism = definition.MaxParallelism
+	}
+	// The submission's variables take precedence, so redacted ones can be submitted again
+	variables := make(map[string]any, len(definition.Variables)+len(input.Variables))
+	for name, value := range definition.Variables {
+		variables[name] = value
+	}
+	for name, value := range input.Variables {
+		variables[name] = value
+	}
+	input.Variables = variables
+
+	redacted := redactedFields("variables", input.Variables)
+	for _, step := range input.Steps {
+		redacted = append(redacted, redactedFields(step.ID+".variables", step.Variables)...)
+	}
+	if input.Backend != nil {
+		redacted = append(redacted, redactedFields("backend.config", input.Backend.Config)...)
+	}
+	if len(redacted) > 0 {
+		return http.StatusConflict, fmt.Errorf("deployment %s was saved with redacted %s, submit them with the delete", input.DeploymentId, strings.Join(redacted, ", "))
+	}
+	return http.StatusOK, nil
+}
+
+// redactedFields lists the values that were redacted when the deployment's definition was saved
+func redactedFields(field string, value any) []string {
+	switch v := value.(type) {
+	case string:
+		if v == activities.Redacted {
+			return []string{field}
+		}
+	case map[string]any:
+		var fields []string
+		for key, item := range v {
+			fields = append(fields, redactedFields(field+"."+key, item)...)
+		}
+		sort.Strings(fields)
+		return fields
+	case []any:
+		var fields []string
+		for i, item := range v {
+			fields = append(fields, redactedFields(fmt.Sprintf("%s[%d]", field, i), item)...)
+		}
+		return fields
+	}
+	return nil
+}
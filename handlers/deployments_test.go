@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+)
+
+// recordDeployment adds a deployment of acme/network with the definition to the API's repository
+func recordDeployment(t *testing.T, api *API, deploymentID, status string, definition *workflows.WorkflowInput) *db.Deployment {
+	t.Helper()
+	deployment := db.Deployment{Account: "acme", Project: "network", DeploymentID: deploymentID, WorkflowName: "network", Status: status}
+	if definition != nil {
+		data, err := json.Marshal(definition)
+		require.NoError(t, err)
+		deployment.Definition = data
+	}
+	require.NoError(t, api.deployments.RecordDeployment(context.Background(), &deployment))
+	return &deployment
+}
+
+func TestListDeploymentsHandler(t *testing.T) {
+	api, _ := memoryAPI(t)
+	recordDeployment(t, api, "prod", db.DeploymentActive, &workflows.WorkflowInput{WorkflowName: "network"})
+	recordDeployment(t, api, "dev", db.DeploymentDestroyed, nil)
+
+	rec := serve(t, api.ListDeploymentsHandler, "/v1/deployments?account=acme&status=ACTIVE")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var deployments []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deployments))
+	require.Len(t, deployments, 1)
+	assert.Equal(t, "prod", deployments[0]["deployment_id"])
+	assert.NotContains(t, deployments[0], "definition")
+
+	rec = serve(t, api.ListDeploymentsHandler, "/v1/deployments?account=globex")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
+
+func TestGetDeploymentHandler(t *testing.T) {
+	api, _ := memoryAPI(t)
+	deployment := recordDeployment(t, api, "prod", db.DeploymentActive, &workflows.WorkflowInput{WorkflowName: "network"})
+	_, err := api.states.Save(context.Background(), deployment.Key(), map[string]map[string]any{"vpc": {"id": "vpc-1"}}, 0, "submission-1")
+	require.NoError(t, err)
+
+	rec := serve(t, api.GetDeploymentHandler, "/", "id", deployment.ID.String())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Deployment map[string]any            `json:"deployment"`
+		Outputs    map[string]map[string]any `json:"outputs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "prod", body.Deployment["deployment_id"])
+	assert.NotContains(t, body.Deployment, "definition", "the definition is never returned by the API")
+	assert.Equal(t, map[string]map[string]any{"vpc": {"id": "vpc-1"}}, body.Outputs)
+
+	rec = serve(t, api.GetDeploymentHandler, "/", "id", "00000000-0000-0000-0000-000000000001")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(t, api.GetDeploymentHandler, "/", "id", "nope")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestFillFromDeployment(t *testing.T) {
+	api, _ := memoryAPI(t)
+	recordDeployment(t, api, "prod", db.DeploymentActive, &workflows.WorkflowInput{
+		WorkflowName:   "network",
+		MaxParallelism: 2,
+		Variables:      map[string]any{"region": "eu-west-1"},
+		Steps:          []models.Step{{ID: "vpc", Executor: "terraform"}},
+	})
+	recordDeployment(t, api, "dev", db.DeploymentDestroyed, &workflows.WorkflowInput{Steps: []models.Step{{ID: "vpc"}}})
+	recordDeployment(t, api, "staging", db.DeploymentFailed, nil)
+	recordDeployment(t, api, "secret", db.DeploymentActive, &workflows.WorkflowInput{
+		Variables: map[string]any{"db_password": "***"},
+		Steps:     []models.Step{{ID: "vpc"}},
+	})
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/v1/provision", nil), httptest.NewRecorder())
+
+	input := workflows.WorkflowInput{Account: "acme", Project: "network", DeploymentId: "prod", Action: "delete"}
+	status, err := api.fillFromDeployment(c, &input)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []models.Step{{ID: "vpc", Executor: "terraform"}}, input.Steps)
+	assert.Equal(t, "network", input.WorkflowName)
+	assert.Equal(t, 2, input.MaxParallelism)
+	assert.Equal(t, map[string]any{"region": "eu-west-1"}, input.Variables)
+
+	tests := []struct {
+		deploymentID string
+		want         int
+	}{
+		{"missing", http.StatusNotFound},
+		{"dev", http.StatusConflict},
+		{"staging", http.StatusConflict},
+		{"secret", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.deploymentID, func(t *testing.T) {
+			input := workflows.WorkflowInput{Account: "acme", Project: "network", DeploymentId: tt.deploymentID, Action: "delete"}
+			status, err := api.fillFromDeployment(c, &input)
+			assert.Error(t, err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// Timing of GET /v1/submissions/:id/events
+const (
+	eventsPollInterval = time.Second
+	eventsKeepAlive    = 15 * time.Second
+	eventsBatchSize    = 100
+)
+
+// SubmissionEventsHandler streams the step and submission events of a submission as server-sent
+// events with the event's seq as the SSE ID. A client that reconnects with Last-Event-ID, or
+// ?last_event_id, gets the events after that one first. The stream ends once the submission finished and every event was sent.
+func (a *API) SubmissionEventsHandler(c echo.Context) error {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid submission ID"})
+	}
+	submissionID := parsedID.String()
+
+	lastSeq := int64(0)
+	value := c.Request().Header.Get("Last-Event-ID")
+	if value == "" {
+		value = c.QueryParam("last_event_id")
+	}
+	if value != "" {
+		if lastSeq, err = strconv.ParseInt(value, 10, 64); err != nil || lastSeq < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid last event ID"})
+		}
+	}
+
+	ctx := c.Request().Context()
+	submission, err := a.submissions.GetSubmission(ctx, submissionID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Submission not found"})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(eventsPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	status := submission.Status
+
+	for {
+		// The status is read before the events, the final submission event is saved before the
+		// status changes so it is in this batch at the latest
+		finished := status != db.SubmissionRunning
+
+		events, err := a.submissions.ListEvents(ctx, submissionID, lastSeq, eventsBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Failed to read events of submission %s: %v", submissionID, err)
+			return nil
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to marshal event %d of submission %s: %v", event.Seq, submissionID, err)
+				return nil
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+				return nil
+			}
+			lastSeq = event.Seq
+		}
+		if len(events) > 0 {
+			res.Flush()
+			lastWrite = time.Now()
+		}
+
+		switch {
+		case len(events) == eventsBatchSize:
+			// More are waiting, read them without waiting for the next tick
+			continue
+		case finished:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if time.Since(lastWrite) >= eventsKeepAlive {
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+			lastWrite = time.Now()
+		}
+
+		current, err := a.submissions.GetSubmission(ctx, submissionID)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read status of submission %s: %v", submissionID, err)
+			}
+			return nil
+		}
+		status = current.Status
+	}
+}
+
+// appendEvent adds an event to the submission's stream for a change the handler already saved,
+// a failure only loses the event so it is logged
+func (a *API) appendEvent(ctx context.Context, event *db.SubmissionEvent) {
+	if err := a.submissions.AppendEvent(ctx, event); err != nil {
+		log.Printf("Failed to record %s event of submission %s: %v", event.Type, event.SubmissionID, err)
+	}
+}
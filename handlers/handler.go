@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -35,6 +36,9 @@ type WorkflowLogger struct {
 
 const SignalName = "step_control_signal"
 
+// Top level fields every DSL document has to provide
+var requiredWorkflowFields = []string{"Account", "DeploymentId", "Submitter", "Action", "Project", "WorkflowName"}
+
 func GetWorkflowActivityHistoryHandler(c echo.Context, temporalClient client.Client) error {
 	workflowID := c.Param("workflow_id")
 	if workflowID == "" {
@@ -77,7 +81,7 @@ func GetWorkflowActivityHistoryHandler(c echo.Context, temporalClient client.Cli
 	return c.JSON(http.StatusOK, activityEvents)
 }
 
-func GetSubmissionIDStatus(c echo.Context, temporalClient client.Client) error {
+func (a *API) GetSubmissionIDStatus(c echo.Context, temporalClient client.Client) error {
 	log.Printf("GetSubmissionIDStatus")
 	submission_id := c.Param("submission_id")
 	// Parse UUID for validation
@@ -86,29 +90,22 @@ func GetSubmissionIDStatus(c echo.Context, temporalClient client.Client) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid submission ID"})
 	}
 
-	var submission db.Submission
-	if err := db.GormDB.Preload("Steps").First(&submission, "id = ?", parsedID).Error; err != nil {
+	submission, err := a.submissions.GetSubmission(c.Request().Context(), parsedID.String())
+	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Submission not found"})
 	}
 
-
-	// New Query
-	// Results are stored in the db as a string so we have to unmarshal them
-	var rawresults []db.WorkflowResult
-	if err := db.GormDB.Raw(`SELECT step_id,status,step_result from SUBMISSION_STEPS where SUBMISSION_ID=?`, parsedID).Scan(&rawresults).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch step results"})
-	}
-
+	// Results are stored in the db as JSON so we have to unmarshal them
 	var parsedResults []db.StepResult
-	for _, r := range rawresults {
+	for _, step := range submission.Steps {
 		var resultMap map[string]interface{}
-		if err := json.Unmarshal([]byte(r.StepResult), &resultMap); err != nil {
+		if err := json.Unmarshal(step.StepResult, &resultMap); err != nil {
 			// fallback to nil or empty object
 			resultMap = map[string]interface{}{"error": "invalid json"}
 		}
 		parsedResults = append(parsedResults, db.StepResult{
-			StepID:     r.StepID,
-			Status: r.Status,
+			StepID:     step.StepID,
+			Status:     step.Status,
 			StepResult: resultMap,
 		})
 	}
@@ -224,7 +221,7 @@ func GetWorkflowStatusHandler(c echo.Context, temporalClient client.Client) erro
 	})
 }
 
-func NewSendSignalHandler(c echo.Context, temporalClient client.Client) error {
+func (a *API) NewSendSignalHandler(c echo.Context, temporalClient client.Client) error {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetLevel(logrus.DebugLevel)
@@ -256,12 +253,12 @@ func NewSendSignalHandler(c echo.Context, temporalClient client.Client) error {
 
 	// Based on the submission id , we should query the db and get the corresponding workflow id and runid and use that for our retry
 
-	var submission db.Submission
-	if err := db.GormDB.Preload("Steps").First(&submission, "id = ?", submissionID).Error; err != nil {
+	submission, err := a.submissions.GetSubmission(c.Request().Context(), submissionID)
+	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Submission not found"})
 	}
 
-	err := temporalClient.SignalWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID, SignalName, payload)
+	err = temporalClient.SignalWorkflow(c.Request().Context(), submission.WorkflowID, submission.RunID, SignalName, payload)
 	if err != nil {
 		log.Printf("Failed to signal workflow: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -276,6 +273,7 @@ func NewSendSignalHandler(c echo.Context, temporalClient client.Client) error {
 		"submission_id": submissionID,
 		"step":          payload.StepID,
 		"action":        payload.Action,
+		"sent_by":       payload.SentBy,
 	})
 
 }
@@ -329,50 +327,39 @@ Entry point into the system. This is the wo
 
 */
 
-func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
+func (a *API) SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetLevel(logrus.InfoLevel)
 
-	contentType := c.Request().Header.Get("Content-Type")
-	log.Printf("Content-Type: %v", contentType)
-	body, err := io.ReadAll(c.Request().Body)
+	input, status, err := readWorkflowInput(c)
 	if err != nil {
-		log.Printf("Failed to read body: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot read body"})
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
-
-	var req workflows.WorkflowInput
-	switch contentType {
-	case "application/json":
-		err = json.Unmarshal(body, &req)
-	case "application/x-yaml", "text/yaml", "application/yaml":
-		err = yaml.Unmarshal(body, &req)
-	default:
-		log.Printf("Unsupported Content-Type: %s", contentType)
-		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "unsupported content type"})
-	}
-
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid body"})
-	}
-
-	var input workflows.WorkflowInput
-	err = yaml.Unmarshal(body, &input)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid YAML"})
+	if status, err := a.fillFromDeployment(c, &input); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
 
 	if input.Action == "" {
 		log.Println("Warning: 'Action' field is missing or empty in YAML.")
 	}
-	requiredFields := []string{"Account", "DeploymentId", "Submitter", "Action", "Project", "WorkflowName"}
-	missingFields := checkMissingFields(input, requiredFields)
+	missingFields := checkMissingFields(input, requiredWorkflowFields)
 
 	if len(missingFields) > 0 {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": fmt.Sprintf("missing required fields: %v", missingFields)})
 	}
+
+	// Reject graphs the workflow could never finish before anything is started in Temporal
+	if problems := workflows.ValidateWorkflowInput(input); len(problems) > 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"error":    "invalid workflow definition",
+			"problems": problems,
+		})
+	}
+	if status, err := a.rejectExistingDeployment(c, input); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
 	workflowOptions := client.StartWorkflowOptions{
 		ID:        input.Account + "-" + uuid.NewString(),
 		TaskQueue: "customer-task-queue-" + input.Account,
@@ -388,21 +375,6 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 	input.SecretId = secretid
 	input.RoleID = roleid
 
-	we, err := temporalClient.ExecuteWorkflow(context.WithValue(context.Background(), "logger", &workflowLogger), workflowOptions, workflows.TemporalExecutorWorkflow, input)
-
-	if err != nil {
-		logger.Errorf("Failed to start workflow: %v", err)
-
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-	desc, err := temporalClient.DescribeWorkflowExecution(context.Background(), we.GetID(), we.GetRunID())
-	if err != nil {
-		fmt.Println("Failed to describe workflow", err)
-		return err
-	}
-	logger.Infof("Workflow Status %s \n\n", desc.WorkflowExecutionInfo.Status)
-
-	logger.Infof("Workflow started successfully. WorkflowID: %s RunID: %s\n", we.GetID(), we.GetRunID())
 	submission := db.Submission{
 		ID:           submissionID,
 		WorkflowName: input.WorkflowName,
@@ -411,8 +383,8 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 		Project:      input.Project,
 		Action:       input.Action,
 		DeploymentID: input.DeploymentId,
-		RunID:        we.GetRunID(),
-		WorkflowID:   we.GetID(),
+		WorkflowID:   workflowOptions.ID,
+		Status:       db.SubmissionRunning,
 	}
 
 	var steps []db.SubmissionStep
@@ -420,8 +392,9 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 		jsonVars, _ := json.Marshal(step.Variables)
 		steps = append(steps, db.SubmissionStep{
 			ID:            uuid.New(),
-			SubmissionID:  we.GetID(),
+			SubmissionID:  submissionID.String(),
 			StepID:        step.ID,
+			Type:          step.Type,
 			Provider:      step.Provider,
 			Executor:      step.Executor,
 			Resource:      step.Resource,
@@ -429,7 +402,7 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 			Operation:     step.Operation,
 			DependsOn:     step.DependsOn,
 			Variables:     datatypes.JSON(jsonVars),
-			Status:        "PENDING",
+			Status:        db.StepPending,
 			LastUpdatedAt: time.Now(),
 			StepResult:    datatypes.JSON(""),
 		})
@@ -437,11 +410,33 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 
 	submission.Steps = steps
 
-	// save to DB
-	if err := db.GormDB.WithContext(c.Request().Context()).Create(&submission).Error; err != nil {
+	// save to DB before starting the workflow, its first activities update the steps
+	if err := a.submissions.CreateSubmission(c.Request().Context(), &submission); err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
+	we, err := temporalClient.ExecuteWorkflow(context.WithValue(context.Background(), "logger", &workflowLogger), workflowOptions, workflows.TemporalExecutorWorkflow, input)
+
+	if err != nil {
+		logger.Errorf("Failed to start workflow: %v", err)
+		if err := a.submissions.UpdateSubmissionStatus(context.Background(), submissionID.String(), db.SubmissionFailed); err != nil {
+			logger.Errorf("Failed to mark submission %s failed: %v", submissionID, err)
+		}
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := a.submissions.SetSubmissionRun(context.Background(), submissionID.String(), we.GetRunID()); err != nil {
+		logger.Errorf("Failed to record run of submission %s: %v", submissionID, err)
+	}
+	desc, err := temporalClient.DescribeWorkflowExecution(context.Background(), we.GetID(), we.GetRunID())
+	if err != nil {
+		fmt.Println("Failed to describe workflow", err)
+		return err
+	}
+	logger.Infof("Workflow Status %s \n\n", desc.WorkflowExecutionInfo.Status)
+
+	logger.Infof("Workflow started successfully. WorkflowID: %s RunID: %s\n", we.GetID(), we.GetRunID())
+
 	return c.JSON(http.StatusOK, map[string]string{
 		//"workflow_id":   we.GetID(),
 		//"run_id":        we.GetRunID(),
@@ -531,6 +526,42 @@ func SubmitWorkflowHandler(c echo.Context, temporalClient client.Client) error {
 	})
 }*/
 
+// readWorkflowInput decodes the DSL document in the request body. On failure it returns the
+// HTTP status to respond with.
+func readWorkflowInput(c echo.Context) (workflows.WorkflowInput, int, error) {
+	var input workflows.WorkflowInput
+
+	contentType := c.Request().Header.Get("Content-Type")
+	log.Printf("Content-Type: %v", contentType)
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Printf("Failed to read body: %v", err)
+		return input, http.StatusBadRequest, errors.New("cannot read body")
+	}
+
+	var req workflows.WorkflowInput
+	switch contentType {
+	case "application/json":
+		err = json.Unmarshal(body, &req)
+	case "application/x-yaml", "text/yaml", "application/yaml":
+		err = yaml.Unmarshal(body, &req)
+	default:
+		log.Printf("Unsupported Content-Type: %s", contentType)
+		return input, http.StatusUnsupportedMediaType, errors.New("unsupported content type")
+	}
+
+	if err != nil {
+		return input, http.StatusBadRequest, errors.New("invalid body")
+	}
+
+	// JSON is a subset of YAML so both content types end up going through the YAML tags
+	err = yaml.Unmarshal(body, &input)
+	if err != nil {
+		return input, http.StatusBadRequest, errors.New("invalid YAML")
+	}
+	return input, http.StatusOK, nil
+}
+
 func checkMissingFields(input any, requiredFields []string) []string {
 	var missing []string
 	v := reflect.ValueOf(input)
@@ -543,11 +574,6 @@ func checkMissingFields(input any, requiredFields []string) []string {
 	return missing
 }
 
-func checkValidActionValues(input string) {
-	if input != "create" || input != "delete" {
-
-	}
-}
 func CustomHTTPErrorHandler(err error, c echo.Context) {
 	requestID := uuid.New().String()
 	c.Set("requestID", requestID)
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// Paging and timing of GET /v1/submissions/:id/steps/:step_id/logs
+const (
+	logsPollInterval = time.Second
+	logsBatchSize    = 100
+)
+
+// GetStepLogsHandler returns the output of the commands a step ran, by default for its latest
+// attempt (the first for a step that has not run yet) and ?attempt=n for another one. Without
+// follow it returns the lines of up to 100 chunks with the ID to pass as ?after for the next page.
+// ?follow=true streams the lines as plain text, waiting for a step that has not started yet, and
+// ends once the attempt finished.
+func (a *API) GetStepLogsHandler(c echo.Context) error {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid submission ID"})
+	}
+	submissionID, stepID := parsedID.String(), c.Param("step_id")
+
+	ctx := c.Request().Context()
+	step, err := a.submissions.GetStep(ctx, submissionID, stepID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Step not found in submission"})
+	}
+
+	attempt := max(step.Attempt, 1)
+	if value := c.QueryParam("attempt"); value != "" {
+		if attempt, err = strconv.Atoi(value); err != nil || attempt < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "attempt must be a positive number"})
+		}
+	}
+	afterID := int64(0)
+	if value := c.QueryParam("after"); value != "" {
+		if afterID, err = strconv.ParseInt(value, 10, 64); err != nil || afterID < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "after must be a chunk ID"})
+		}
+	}
+	follow := false
+	if value := c.QueryParam("follow"); value != "" {
+		if follow, err = strconv.ParseBool(value); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "follow must be true or false"})
+		}
+	}
+
+	if follow {
+		return a.followStepLogs(c, step, attempt, afterID)
+	}
+
+	chunks, err := a.submissions.ListLogs(ctx, submissionID, stepID, attempt, afterID, logsBatchSize)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch step logs"})
+	}
+	lines := []db.LogLine{}
+	for _, chunk := range chunks {
+		var chunkLines []db.LogLine
+		if err := json.Unmarshal(chunk.Lines, &chunkLines); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read step logs"})
+		}
+		lines = append(lines, chunkLines...)
+		afterID = chunk.ID
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"submission_id": submissionID,
+		"step_id":       stepID,
+		"attempt":       attempt,
+		"status":        step.Status,
+		"lines":         lines,
+		"after":         afterID,
+		"more":          len(chunks) == logsBatchSize,
+	})
+}
+
+// followStepLogs streams the lines of the attempt as they are saved, stderr lines prefixed with
+// "stderr: ", until the attempt finished and every line was sent. An attempt the step has not
+// reached yet, while it is PENDING, STARTED or RETRYING, is waited for.
+func (a *API) followStepLogs(c echo.Context, step *db.SubmissionStep, attempt int, afterID int64) error {
+	ctx := c.Request().Context()
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(logsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The status is read before the logs, the activity saves its last chunk before the step
+		// moves on so it is in this batch at the latest
+		finished := step.Attempt > attempt || db.StepFinished(step.Status)
+
+		chunks, err := a.submissions.ListLogs(ctx, step.SubmissionID, step.StepID, attempt, afterID, logsBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read logs of step %s in submission %s: %v", step.StepID, step.SubmissionID, err)
+			}
+			return nil
+		}
+		for _, chunk := range chunks {
+			var lines []db.LogLine
+			if err := json.Unmarshal(chunk.Lines, &lines); err != nil {
+				log.Printf("Failed to read log chunk %d of step %s: %v", chunk.ID, step.StepID, err)
+				return nil
+			}
+			for _, line := range lines {
+				prefix := ""
+				if line.Stream == "stderr" {
+					prefix = "stderr: "
+				}
+				if _, err := fmt.Fprintf(res, "%s%s\n", prefix, line.Text); err != nil {
+					return nil
+				}
+			}
+			afterID = chunk.ID
+		}
+		if len(chunks) > 0 {
+			res.Flush()
+		}
+
+		switch {
+		case len(chunks) == logsBatchSize:
+			continue
+		case finished:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := a.submissions.GetStep(ctx, step.SubmissionID, step.StepID)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read status of step %s: %v", step.StepID, err)
+			}
+			return nil
+		}
+		step = current
+	}
+}
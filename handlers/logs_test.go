@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"gorm.io/datatypes"
+)
+
+func appendLogLines(t *testing.T, repository *db.MemorySubmissionRepository, submissionID string, attempt int, lines ...db.LogLine) {
+	t.Helper()
+	data, err := json.Marshal(lines)
+	require.NoError(t, err)
+	require.NoError(t, repository.AppendLogs(context.Background(), &db.SubmissionStepLog{
+		SubmissionID: submissionID,
+		StepID:       "vpc",
+		Attempt:      attempt,
+		Lines:        datatypes.JSON(data),
+	}))
+}
+
+func moveStep(t *testing.T, repository *db.MemorySubmissionRepository, submissionID, status string, attempt int) {
+	t.Helper()
+	step, err := repository.GetStep(context.Background(), submissionID, "vpc")
+	require.NoError(t, err)
+	from := step.Status
+	step.Status, step.Attempt = status, attempt
+	require.NoError(t, repository.UpdateStep(context.Background(), step, from))
+}
+
+func TestGetStepLogsHandlerFollowWaitsForPendingStep(t *testing.T) {
+	api, repository := memoryAPI(t)
+	submission := db.Submission{Steps: []db.SubmissionStep{{StepID: "vpc", Status: db.StepPending}}}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+	id := submission.ID.String()
+
+	done := make(chan string)
+	go func() {
+		rec := serve(t, api.GetStepLogsHandler, "/?follow=true", "id", id, "step_id", "vpc")
+		done <- rec.Body.String()
+	}()
+
+	// The first poll sees the step PENDING, the stream has to stay open until it ran
+	time.Sleep(100 * time.Millisecond)
+	moveStep(t, repository, id, db.StepStarted, 0)
+	moveStep(t, repository, id, db.StepRunning, 1)
+	appendLogLines(t, repository, id, 1, db.LogLine{Stream: "stdout", Text: "Apply complete!"}, db.LogLine{Stream: "stderr", Text: "warning"})
+	moveStep(t, repository, id, db.StepSuccess, 1)
+
+	select {
+	case body := <-done:
+		assert.Equal(t, "Apply complete!\nstderr: warning\n", body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("following the logs did not end after the step finished")
+	}
+}
+
+func TestGetStepLogsHandlerFollowEndsForFinishedAttempt(t *testing.T) {
+	api, repository := memoryAPI(t)
+	submission := db.Submission{Steps: []db.SubmissionStep{{StepID: "vpc", Status: db.StepRunning, Attempt: 2}}}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+	id := submission.ID.String()
+	appendLogLines(t, repository, id, 1, db.LogLine{Stream: "stdout", Text: "first try"})
+
+	// The step is on its second attempt, so the first one is over even though the step still runs
+	rec := serve(t, api.GetStepLogsHandler, "/?follow=true&attempt=1", "id", id, "step_id", "vpc")
+
+	assert.Equal(t, "first try\n", rec.Body.String())
+}
+
+func TestGetStepLogsHandlerPendingStep(t *testing.T) {
+	api, repository := memoryAPI(t)
+	submission := db.Submission{Steps: []db.SubmissionStep{{StepID: "vpc", Status: db.StepPending}}}
+	require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+
+	rec := serve(t, api.GetStepLogsHandler, "/", "id", submission.ID.String(), "step_id", "vpc")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Attempt int          `json:"attempt"`
+		Status  string       `json:"status"`
+		Lines   []db.LogLine `json:"lines"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Attempt)
+	assert.Equal(t, db.StepPending, body.Status)
+	assert.Empty(t, body.Lines)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// Page sizes of GET /v1/submissions
+const (
+	defaultSubmissionsLimit = 50
+	maxSubmissionsLimit     = 200
+)
+
+// SubmissionSummary is a submission in the listing with the number of its steps in each status
+type SubmissionSummary struct {
+	db.Submission
+	StepCounts map[string]int `json:"step_counts"`
+}
+
+// ListSubmissionsHandler returns a page of submissions. account, project, submitter, action,
+// deployment_id and status filter on equality, created_after and created_before (RFC 3339) bound
+// the creation time. sort is -created_at (newest first, the default) or created_at, and the
+// next_cursor of a page passed as cursor returns the page after it.
+func (a *API) ListSubmissionsHandler(c echo.Context) error {
+	filter, err := submissionFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Ask for one more than the page holds to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	submissions, err := a.submissions.ListSubmissions(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch submissions"})
+	}
+	nextCursor := ""
+	if len(submissions) > limit {
+		submissions = submissions[:limit]
+		last := submissions[limit-1]
+		nextCursor = encodeCursor(db.SubmissionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	ids := make([]string, len(submissions))
+	for i, submission := range submissions {
+		ids[i] = submission.ID.String()
+	}
+	counts, err := a.submissions.CountSteps(c.Request().Context(), ids)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to count submission steps"})
+	}
+
+	summaries := make([]SubmissionSummary, len(submissions))
+	for i, submission := range submissions {
+		stepCounts := counts[submission.ID.String()]
+		if stepCounts == nil {
+			stepCounts = map[string]int{}
+		}
+		summaries[i] = SubmissionSummary{Submission: submission, StepCounts: stepCounts}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"submissions": summaries,
+		"next_cursor": nextCursor,
+	})
+}
+
+func submissionFilter(c echo.Context) (db.SubmissionFilter, error) {
+	filter := db.SubmissionFilter{
+		Account:      c.QueryParam("account"),
+		Project:      c.QueryParam("project"),
+		DeploymentID: c.QueryParam("deployment_id"),
+		Submitter:    c.QueryParam("submitter"),
+		Action:       c.QueryParam("action"),
+		Status:       c.QueryParam("status"),
+		Limit:        defaultSubmissionsLimit,
+	}
+
+	var err error
+	if value := c.QueryParam("created_after"); value != "" {
+		if filter.CreatedFrom, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid created_after %q, expected RFC 3339", value)
+		}
+	}
+	if value := c.QueryParam("created_before"); value != "" {
+		if filter.CreatedTo, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid created_before %q, expected RFC 3339", value)
+		}
+	}
+
+	switch sort := c.QueryParam("sort"); sort {
+	case "", "-created_at":
+		filter.Descending = true
+	case "created_at":
+	default:
+		return filter, fmt.Errorf("invalid sort %q, expected created_at or -created_at", sort)
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSubmissionsLimit {
+			return filter, fmt.Errorf("invalid limit %q, expected 1 to %d", value, maxSubmissionsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
+
+// A cursor is the creation time and ID of the last submission of a page, it stays valid while
+// submissions are added
+func encodeCursor(cursor db.SubmissionCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.CreatedAt.Format(time.RFC3339Nano) + "|" + cursor.ID.String()))
+}
+
+func decodeCursor(value string) (db.SubmissionCursor, error) {
+	invalid := errors.New("invalid cursor")
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return db.SubmissionCursor{}, invalid
+	}
+	createdAt, id, found := strings.Cut(string(data), "|")
+	if !found {
+		return db.SubmissionCursor{}, invalid
+	}
+	var cursor db.SubmissionCursor
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return db.SubmissionCursor{}, invalid
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return db.SubmissionCursor{}, invalid
+	}
+	return cursor, nil
+}
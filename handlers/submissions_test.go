@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// memoryAPI returns an API over an empty in-memory submission repository, which it also returns,
+// in-memory deployments and states in a temporary directory
+func memoryAPI(t *testing.T) (*API, *db.MemorySubmissionRepository) {
+	t.Helper()
+	repository := db.NewMemorySubmissionRepository()
+	return NewAPI(repository, db.NewMemoryDeploymentRepository(), db.NewFileStateStore(t.TempDir())), repository
+}
+
+// serve runs the handler for a GET of target with the path parameters given as name, value pairs
+func serve(t *testing.T, handler echo.HandlerFunc, target string, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	require.NoError(t, handler(c))
+	return rec
+}
+
+type submissionsPage struct {
+	Submissions []SubmissionSummary `json:"submissions"`
+	NextCursor  string              `json:"next_cursor"`
+}
+
+// createSubmissions adds submissions created a minute apart, the last two at the same time so
+// their IDs decide the order. It returns the IDs oldest first.
+func createSubmissions(t *testing.T, repository *db.MemorySubmissionRepository) []uuid.UUID {
+	t.Helper()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute), start.Add(3 * time.Minute)}
+	ids := []uuid.UUID{
+		uuid.MustParse("00000000-0000-0000-0000-000000000005"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000004"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+	}
+	for i, id := range ids {
+		submission := db.Submission{
+			ID:        id,
+			Account:   "acme",
+			Action:    "create",
+			Status:    db.SubmissionRunning,
+			CreatedAt: createdAt[i],
+			Steps: []db.SubmissionStep{
+				{StepID: "vpc", Status: db.StepSuccess},
+				{StepID: "subnet", Status: db.StepPending},
+			},
+		}
+		require.NoError(t, repository.CreateSubmission(context.Background(), &submission))
+	}
+	return ids
+}
+
+// listAll follows next_cursor from the first page to the last
+func listAll(t *testing.T, api *API, query string) ([]uuid.UUID, int) {
+	t.Helper()
+	var ids []uuid.UUID
+	pages := 0
+	cursor := ""
+	for {
+		target := "/v1/submissions?" + query
+		if cursor != "" {
+			target += "&cursor=" + cursor
+		}
+		rec := serve(t, api.ListSubmissionsHandler, target)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page submissionsPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		pages++
+		for _, submission := range page.Submissions {
+			ids = append(ids, submission.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func reversed(ids []uuid.UUID) []uuid.UUID {
+	result := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		result[len(ids)-1-i] = id
+	}
+	return result
+}
+
+func TestListSubmissionsHandlerPagesNewestFirst(t *testing.T) {
+	api, repository := memoryAPI(t)
+	ids := createSubmissions(t, repository)
+
+	listed, pages := listAll(t, api, "limit=2")
+
+	assert.Equal(t, reversed(ids), listed)
+	assert.Equal(t, 3, pages)
+}
+
+func TestListSubmissionsHandlerPagesOldestFirst(t *testing.T) {
+	api, repository := memoryAPI(t)
+	ids := createSubmissions(t, repository)
+
+	listed, pages := listAll(t, api, "sort=created_at&limit=2")
+
+	assert.Equal(t, ids, listed)
+	assert.Equal(t, 3, pages)
+}
+
+func TestListSubmissionsHandlerLastFullPageHasNoCursor(t *testing.T) {
+	api, repository := memoryAPI(t)
+	createSubmissions(t, repository)
+
+	listed, pages := listAll(t, api, "limit=5")
+
+	assert.Len(t, listed, 5)
+	assert.Equal(t, 1, pages)
+}
+
+func TestListSubmissionsHandlerCountsSteps(t *testing.T) {
+	api, repository := memoryAPI(t)
+	createSubmissions(t, repository)
+
+	rec := serve(t, api.ListSubmissionsHandler, "/v1/submissions?limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page submissionsPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+	require.Len(t, page.Submissions, 1)
+	assert.Equal(t, map[string]int{db.StepSuccess: 1, db.StepPending: 1}, page.Submissions[0].StepCounts)
+}
+
+func TestListSubmissionsHandlerFiltersCreationTime(t *testing.T) {
+	api, repository := memoryAPI(t)
+	ids := createSubmissions(t, repository)
+
+	listed, _ := listAll(t, api, "sort=created_at&created_after=2026-10-01T12:01:00Z&created_before=2026-10-01T12:03:00Z")
+
+	assert.Equal(t, ids[1:3], listed)
+}
+
+func TestListSubmissionsHandlerRejectsInvalidQueries(t *testing.T) {
+	api, _ := memoryAPI(t)
+
+	for _, query := range []string{"sort=name", "limit=0", "limit=1000", "cursor=nope", "created_after=yesterday"} {
+		rec := serve(t, api.ListSubmissionsHandler, "/v1/submissions?"+query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/surajsub/temporal-rest-dsl/workflows"
+)
+
+// ValidateWorkflowHandler lints a DSL document without submitting it. It accepts the same body as
+// /v1/provision and never talks to Temporal or Postgres.
+func ValidateWorkflowHandler(c echo.Context) error {
+	input, status, err := readWorkflowInput(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	analysis := workflows.AnalyzeWorkflowInput(input)
+	if missingFields := checkMissingFields(input, requiredWorkflowFields); len(missingFields) > 0 {
+		analysis.Valid = false
+		analysis.Problems = append([]workflows.ValidationProblem{{
+			Field:   "workflow",
+			Message: fmt.Sprintf("missing required fields: %v", missingFields),
+		}}, analysis.Problems...)
+	}
+
+	if !analysis.Valid {
+		return c.JSON(http.StatusBadRequest, analysis)
+	}
+	return c.JSON(http.StatusOK, analysis)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -10,8 +11,11 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/surajsub/temporal-rest-dsl/activities"
 	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/executors"
 	"github.com/surajsub/temporal-rest-dsl/handlers"
+	"github.com/surajsub/temporal-rest-dsl/models"
 	"github.com/surajsub/temporal-rest-dsl/workers"
 
 	"go.temporal.io/sdk/client"
@@ -20,10 +24,16 @@ import (
 )
 
 func main() {
-	handlers.StartTemporalClient()
 	stopQueue := flag.String("stop", "", "Task queue to stop (optional)")
 	flag.Parse()
 
+	// "migrate" manages the database schema and exits, it needs nothing but the database
+	if flag.Arg(0) == "migrate" {
+		runMigrate(flag.Args()[1:])
+		return
+	}
+	handlers.StartTemporalClient()
+
 	secretID, secretIDExists := os.LookupEnv("SECRET_ID") // Read "SECRET_ID" and check if it exists
 	if !secretIDExists {
 
@@ -74,6 +84,30 @@ func main() {
 	if err := db.InitDB(dbUser,dbPassword, dbName); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	// Set DB_MIGRATE_ON_START=false to run "migrate up" as a separate deployment step instead
+	if os.Getenv("DB_MIGRATE_ON_START") != "false" {
+		applied, err := db.MigrateUp(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
+	submissions, err := db.NewPostgresSubmissionRepository(db.GormDB)
+	if err != nil {
+		log.Fatalf("Failed to initialize submission repository: %v", err)
+	}
+	deployments, err := db.NewPostgresDeploymentRepository(db.GormDB)
+	if err != nil {
+		log.Fatalf("Failed to initialize deployment repository: %v", err)
+	}
+	states, err := db.NewStateStore(db.GormDB)
+	if err != nil {
+		log.Fatalf("Failed to initialize state store: %v", err)
+	}
+	// The pg state backend defaults to the application's database
+	executors.PostgresConnString = db.ConnString()
 
 	// Connect to Temporal
 
@@ -85,7 +119,7 @@ func main() {
 	defer c.Close()
 
 	// Initialize WorkerManager
-	manager := workers.NewWorkerManager(c, secretID, roleID)
+	manager := workers.NewWorkerManager(c, secretID, roleID, activities.NewActivities(submissions, deployments, states))
 
 	// Handle worker stop request
 	if *stopQueue != "" {
@@ -96,6 +130,11 @@ func main() {
 
 	// Start workers for each customer
 	for _, customer := range config.Customers {
+		if customer.Backend != nil {
+			if err := executors.RegisterCustomerBackend(customer.Name, customer.Backend); err != nil {
+				log.Fatalf("Invalid backend for customer %s: %v", customer.Name, err)
+			}
+		}
 		manager.StartWorker(customer.Name, customer.TaskQueue)
 	}
 
@@ -106,7 +145,7 @@ func main() {
 		e.Use(handlers.RequestIDMiddleware)
 		e.Use(middleware.Logger())
 		e.Use(middleware.Recover())
-		handlers.RegisterRoutes(e, func() client.Client {
+		handlers.RegisterRoutes(e, handlers.NewAPI(submissions, deployments, states), func() client.Client {
 			return handlers.GetClient()
 		})
 
@@ -126,8 +165,9 @@ func main() {
 
 type CustomerConfig struct {
 	Customers []struct {
-		Name      string `yaml:"name"`
-		TaskQueue string `yaml:"task_queue"`
+		Name      string          `yaml:"name"`
+		TaskQueue string          `yaml:"task_queue"`
+		Backend   *models.Backend `yaml:"backend,omitempty"` // Default state backend for the customer's submissions
 	} `yaml:"customers"`
 }
 
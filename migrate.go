@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/surajsub/temporal-rest-dsl/db"
+)
+
+// runMigrate handles "migrate up", "migrate down [n]" and "migrate status"
+func runMigrate(args []string) {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	for _, env := range []string{"POSTGRES_DB_USER", "POSTGRES_DB_PASSWORD", "POSTGRES_DB_NAME"} {
+		if _, ok := os.LookupEnv(env); !ok {
+			fmt.Printf("Error: %s environment variable is not set\n", env)
+			os.Exit(1)
+		}
+	}
+	if err := db.InitDB(os.Getenv("POSTGRES_DB_USER"), os.Getenv("POSTGRES_DB_PASSWORD"), os.Getenv("POSTGRES_DB_NAME")); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations to revert: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(ctx, steps)
+		if err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
+		}
+		for _, migration := range reverted {
+			log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+		}
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up, down [n] or status", command)
+	}
+}
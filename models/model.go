@@ -1,8 +1,13 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type Step struct {
 	ID              string
-	DependsOn       []string
+	DependsOn       []string `yaml:"depends_on,omitempty"`
 	Provider        string
 	Resource        string
 	Executor        string
@@ -11,6 +16,9 @@ type Step struct {
 	Variables       map[string]any `yaml:"variables" yaml:"variables"`
 	TaskDescription string         `yaml:"-"` // Optional, for logging/UI purposes only
 	Customer        string         `yaml:"-"` // Don't need to make it mandatory for now
+	SubmissionID    string         `yaml:"-"` // Set from the submission, keys the step's sandbox
+	DeploymentID    string         `yaml:"-"` // Set from the submission, keys the step's state
+	Backend         *Backend       `yaml:"-"` // Set from the submission or the customer's default
 	Provisioner     string         `yaml:"provisioner,omitempty"`
 	Submitter       string         `yaml:"submitter,omitempty"`
 	Project         string         `yaml:"project,omitempty"`
@@ -21,6 +29,131 @@ type Step struct {
 	DeploymentName  string         `yaml:"deploymentName,omitempty" json:"deploymentName,omitempty"`
 	SecretId        string         `yaml:"secret_id,omitempty" json:"secret_id,omitempty"`
 	RoleID          string         `yaml:"role_id,omitempty" json:"role_id,omitempty"`
+	Type            string         `yaml:"type,omitempty" json:"type,omitempty"` // Empty for executor steps, "approval" for manual gates
+	Approval        *Approval      `yaml:"approval,omitempty" json:"approval,omitempty"`
+	// Activity settings for the step, the workflow defaults apply when unset
+	Timeout          string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`                     // Go duration, start to close
+	HeartbeatTimeout string       `yaml:"heartbeat_timeout,omitempty" json:"heartbeat_timeout,omitempty"` // Go duration
+	Retry            *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// When is a condition over submission fields and earlier outputs, the step is skipped when it is false
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+	// IfDependencySkipped is "skip" (default) to skip the step along with a skipped dependency, or "run"
+	IfDependencySkipped string `yaml:"if_dependency_skipped,omitempty" json:"if_dependency_skipped,omitempty"`
+	// WorkspaceCleanup is when the step's sandbox is removed: always (default), on_success or never
+	WorkspaceCleanup string `yaml:"workspace_cleanup,omitempty" json:"workspace_cleanup,omitempty"`
+	// ForEach fans the step out into one indexed sub-step per item
+	ForEach *ForEach `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	// Attempt, Trigger and TriggeredBy describe the run of the step for its attempt history. They
+	// are set by the workflow: the initial run, a retry signalled by an operator or a rollback.
+	Attempt     int    `yaml:"-" json:"attempt,omitempty"`
+	Trigger     string `yaml:"-" json:"trigger,omitempty"`
+	TriggeredBy string `yaml:"-" json:"triggered_by,omitempty"`
+	// Each is the item a for_each sub-step runs for, ${each.value}, ${each.key} and ${each.index}
+	Each map[string]any `yaml:"-" json:"each,omitempty"`
+}
+
+// UnmarshalYAML converts nested maps in the variables so they keep their structure when the step
+// is passed to Temporal as JSON and written to a tfvars file
+func (s *Step) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain Step
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	for key, value := range s.Variables {
+		s.Variables[key] = NormalizeYAML(value)
+	}
+	return nil
+}
+
+// What started a run of a step, kept in its attempt history
+const (
+	TriggerInitial  = "initial"
+	TriggerRetry    = "retry"
+	TriggerRollback = "rollback"
+)
+
+// Backend selects where Terraform/OpenTofu keep the state of a deployment's steps. Config holds
+// the backend's own settings (bucket, endpoint, conn_str, address...), the state key is derived
+// from the deployment.
+type Backend struct {
+	Type   string         `yaml:"type" json:"type"`
+	Config map[string]any `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// UnmarshalYAML converts nested maps in the config so the backend can be passed to Temporal as JSON
+func (b *Backend) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain Backend
+	if err := unmarshal((*plain)(b)); err != nil {
+		return err
+	}
+	for key, value := range b.Config {
+		b.Config[key] = NormalizeYAML(value)
+	}
+	return nil
+}
+
+// ForEach holds a step's for_each value: a list, a map, a count or a "${step.output}" reference
+// to a list or map produced by an earlier step
+type ForEach struct {
+	Value any
+}
+
+// UnmarshalYAML converts the maps yaml.v2 produces to map[string]any so the step can be passed to
+// Temporal as JSON
+func (f *ForEach) UnmarshalYAML(unmarshal func(any) error) error {
+	var value any
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	f.Value = NormalizeYAML(value)
+	return nil
+}
+
+func (f ForEach) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Value)
+}
+
+func (f *ForEach) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &f.Value)
+}
+
+// NormalizeYAML converts the map[any]any yaml.v2 produces for nested maps to map[string]any
+func NormalizeYAML(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = NormalizeYAML(item)
+		}
+		return m
+	case map[string]any:
+		for key, item := range v {
+			v[key] = NormalizeYAML(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = NormalizeYAML(item)
+		}
+		return v
+	}
+	return value
+}
+
+// RetryPolicy overrides the workflow's default activity retry policy for a step
+type RetryPolicy struct {
+	MaxAttempts     int      `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`         // 1 disables retries
+	InitialInterval string   `yaml:"initial_interval,omitempty" json:"initial_interval,omitempty"` // Go duration
+	MaximumInterval string   `yaml:"maximum_interval,omitempty" json:"maximum_interval,omitempty"` // Go duration
+	Backoff         float64  `yaml:"backoff,omitempty" json:"backoff,omitempty"`                   // Coefficient, at least 1
+	NonRetryable    []string `yaml:"non_retryable,omitempty" json:"non_retryable,omitempty"`       // Error types that fail the step straight away
+}
+
+// Approval configures a manual approval gate step
+type Approval struct {
+	Approvers []string `yaml:"approvers,omitempty" json:"approvers,omitempty"` // Optional allow-list, anyone may decide when empty
+	Timeout   string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`     // Go duration, waits forever when empty
+	Default   string   `yaml:"default,omitempty" json:"default,omitempty"`     // Decision applied on timeout, approve or reject (default)
 }
 
 type Credentials struct {
@@ -31,8 +164,27 @@ type Credentials struct {
 	Service  string
 }
 
+// ApprovalSignal carries the decision for an approval step
+type ApprovalSignal struct {
+	StepID   string `json:"step_id"`
+	Decision string `json:"decision"`
+	Approver string `json:"approver"`
+	Comment  string `json:"comment"`
+}
+
 type RetrySignal struct {
 	StepID string                 `json:"step_id"`
 	Action string                 `json:"action"`
 	Inputs map[string]interface{} `json:"inputs"`
+	// SentBy is whoever the caller says sent the signal. The API has no authentication, so it is
+	// recorded as the attempt's triggered_by for the audit trail but not verified.
+	SentBy string `json:"sent_by,omitempty"`
+}
+
+// CancelSignal is sent right before a submission's workflow is cancelled and tells it how to wind down
+type CancelSignal struct {
+	// Cleanup destroys what the submission already created
+	Cleanup     bool   `json:"cleanup"`
+	RequestedBy string `json:"requested_by,omitempty"`
+	Reason      string `json:"reason,omitempty"`
 }
@@ -16,6 +16,8 @@ type WorkerManager struct {
 	// Future integration with vault
 	secretID string
 	roleID   string
+	// activities are registered with every worker
+	activities *activities.Activities
 
 	workers map[string]worker.Worker
 
@@ -24,14 +26,15 @@ type WorkerManager struct {
 	workerIDs   map[string]string
 }
 
-func NewWorkerManager(c client.Client, secretid, roleid string) *WorkerManager {
+func NewWorkerManager(c client.Client, secretid, roleid string, acts *activities.Activities) *WorkerManager {
 	return &WorkerManager{
 
-		client:    c,
-		secretID:  secretid,
-		roleID:    roleid,
-		workers:   make(map[string]worker.Worker),
-		workerIDs: make(map[string]string),
+		client:     c,
+		secretID:   secretid,
+		roleID:     roleid,
+		activities: acts,
+		workers:    make(map[string]worker.Worker),
+		workerIDs:  make(map[string]string),
 	}
 }
 
@@ -51,11 +54,9 @@ func (m *WorkerManager) StartWorker(customername, queueName string) {
 
 	w := worker.New(m.client, queueName, worker.Options{})
 	w.RegisterWorkflow(workflows.TemporalExecutorWorkflow) // Register your workflows
-	w.RegisterActivity(activities.RunActivity) // Register your activities
-	w.RegisterActivity(activities.DBActivity)
-
-	w.RegisterActivity(activities.SaveStateToStorage) // Save it to local storage for every deployment to replay the delete flow.
-	w.RegisterActivity(activities.LoadStateFromStorage)
+	// Every activity, among them the deployment state ones so updates and deletes can replay
+	// against it on any worker
+	w.RegisterActivity(m.activities)
 
 	go func() {
 		if err := w.Run(worker.InterruptCh()); err != nil {
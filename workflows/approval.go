@@ -0,0 +1,123 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// StepTypeApproval marks a step as a manual gate handled by the workflow itself
+	StepTypeApproval = "approval"
+	// ApprovalSignalName is the signal the approvals endpoint sends decisions on
+	ApprovalSignalName = "approval_signal"
+
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// dispatchApprovals routes approval decisions to the step they are for. A decision that arrives
+// before the workflow reaches the step is kept until the step starts waiting.
+func (r *stepRunner) dispatchApprovals(ctx workflow.Context, approvalChan workflow.ReceiveChannel) {
+	for {
+		var decision models.ApprovalSignal
+		approvalChan.Receive(ctx, &decision)
+		r.logger.Info("Received approval decision", "stepID", decision.StepID, "decision", decision.Decision, "approver", decision.Approver)
+
+		if ch, ok := r.approvals[decision.StepID]; ok {
+			if !ch.SendAsync(decision) {
+				r.logger.Warn("Approval step already has a pending decision, dropping", "stepID", decision.StepID)
+			}
+			continue
+		}
+		r.earlyApprovals[decision.StepID] = decision
+	}
+}
+
+// awaitApproval blocks until an allowed approver decides on the step or the timeout expires, in
+// which case the configured default decision applies. A rejection is returned as an error so it
+// goes through the same failure handling as a failed executor step.
+func (r *stepRunner) awaitApproval(ctx workflow.Context, step models.Step) (map[string]any, error) {
+	config := models.Approval{}
+	if step.Approval != nil {
+		config = *step.Approval
+	}
+
+	if err := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepWaitingApproval, Result: map[string]any{"approvers": config.Approvers}}); err != nil {
+		return nil, err
+	}
+
+	ch := workflow.NewBufferedChannel(ctx, 1)
+	r.approvals[step.ID] = ch
+	defer delete(r.approvals, step.ID)
+	if early, ok := r.earlyApprovals[step.ID]; ok {
+		delete(r.earlyApprovals, step.ID)
+		ch.SendAsync(early)
+	}
+
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+	timedOut := false
+	selector := workflow.NewSelector(ctx)
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid approval timeout %q: %w", config.Timeout, err)
+		}
+		selector.AddFuture(workflow.NewTimer(timerCtx, timeout), func(f workflow.Future) {
+			// The timer is cancelled along with the workflow, that is not a timeout
+			timedOut = f.Get(timerCtx, nil) == nil
+		})
+	}
+
+	var decision models.ApprovalSignal
+	selector.AddReceive(ch, func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, &decision)
+	})
+	selector.AddReceive(ctx.Done(), func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+	})
+
+	for {
+		selector.Select(ctx)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if timedOut {
+			decision = models.ApprovalSignal{
+				StepID:   step.ID,
+				Decision: config.Default,
+				Approver: "system",
+				Comment:  fmt.Sprintf("no decision within %s", config.Timeout),
+			}
+			if decision.Decision == "" {
+				decision.Decision = DecisionReject
+			}
+			break
+		}
+		if len(config.Approvers) > 0 && !contains(config.Approvers, decision.Approver) {
+			r.logger.Warn("Ignoring decision from approver that is not allowed", "stepID", step.ID, "approver", decision.Approver)
+			continue
+		}
+		if decision.Decision != DecisionApprove && decision.Decision != DecisionReject {
+			r.logger.Warn("Ignoring unknown approval decision", "stepID", step.ID, "decision", decision.Decision)
+			continue
+		}
+		break
+	}
+
+	result := map[string]any{
+		"decision":   decision.Decision,
+		"approver":   decision.Approver,
+		"comment":    decision.Comment,
+		"decided_at": workflow.Now(ctx).UTC().Format(time.RFC3339),
+	}
+	if decision.Decision == DecisionReject {
+		return result, fmt.Errorf("step %s was rejected by %s: %s", step.ID, decision.Approver, decision.Comment)
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// CancelSignalName is the signal the cancel endpoint sends right before it cancels the workflow
+const CancelSignalName = "cancel_signal"
+
+// watchCancellation waits for the workflow to be cancelled, picks up the CancelSignal sent with
+// the cancellation and releases every step parked on a failure so the scheduler can drain.
+// Running executor activities are cancelled by Temporal itself.
+func (r *stepRunner) watchCancellation(ctx workflow.Context, cancelChan workflow.ReceiveChannel) {
+	ctx.Done().Receive(ctx, nil)
+
+	// The signal is recorded before the cancellation, so it is already buffered if it was sent
+	cancelChan.ReceiveAsync(&r.cancelRequest)
+	r.logger.Warn("Submission cancelled", "submissionID", r.input.SubmissionID, "requestedBy", r.cancelRequest.RequestedBy, "cleanup", r.cancelRequest.Cleanup)
+
+	// Sorted so the order the parked coroutines wake up in is deterministic
+	parked := make([]string, 0, len(r.awaiting))
+	for id := range r.awaiting {
+		parked = append(parked, id)
+	}
+	sort.Strings(parked)
+	for _, id := range parked {
+		r.awaiting[id].SendAsync(RetrySignal{StepID: id, Action: "cancel"})
+	}
+}
+
+// interrupted reports whether err is the workflow's cancellation reaching a step
+func interrupted(ctx workflow.Context, err error) bool {
+	return ctx.Err() != nil && temporal.IsCanceledError(err)
+}
+
+// cancel winds the submission down once the scheduler has drained: the steps that did not finish
+// are marked CANCELLED and, when cleanup was requested for a create, what the submission created
+// is destroyed the same way a rollback does. It always returns the cancellation so Temporal
+// records the workflow as cancelled.
+func (r *stepRunner) cancel(ctx workflow.Context, completed []models.Step) error {
+	// The bookkeeping has to run even though the workflow's context is done
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+
+	reason := "submission was cancelled"
+	if r.cancelRequest.RequestedBy != "" {
+		reason = fmt.Sprintf("submission was cancelled by %s", r.cancelRequest.RequestedBy)
+	}
+	if r.cancelRequest.Reason != "" {
+		reason += ": " + r.cancelRequest.Reason
+	}
+	if err := workflow.ExecuteActivity(ctx, acts.CancelStepsActivity, r.input.SubmissionID, reason).Get(ctx, nil); err != nil {
+		r.logger.Error("Failed to cancel remaining steps", "submissionID", r.input.SubmissionID, "error", err)
+	}
+
+	if r.cancelRequest.Cleanup && r.input.Action == "create" {
+		// rollback records ROLLED_BACK or ROLLBACK_FAILED as the submission's status
+		if err := r.rollback(ctx, completed); err != nil {
+			r.logger.Warn("Cleanup after cancellation finished", "submissionID", r.input.SubmissionID, "result", err)
+		}
+		return temporal.NewCanceledError(reason)
+	}
+
+	if err := workflow.ExecuteActivity(ctx, acts.SubmissionStatusActivity, r.input.SubmissionID, db.SubmissionCancelled).Get(ctx, nil); err != nil {
+		r.logger.Error("Failed to record cancelled submission", "submissionID", r.input.SubmissionID, "error", err)
+	}
+	return temporal.NewCanceledError(reason)
+}
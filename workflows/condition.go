@@ -0,0 +1,527 @@
+package workflows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// A step's `when:` condition is a small boolean expression over submission fields and the
+// outputs of earlier steps:
+//
+//	when: ${submission.account} == 'prod' && ${create_vpc.enable_nat} != false
+//	when: ${submission.project} in ['web', 'api'] || !(${get_cost_estimate.total} > 100)
+//
+// Operands are ${submission.<field>} or ${step.output} references, quoted strings, numbers,
+// true, false, null and [lists]. Operators are ==, !=, <, <=, >, >=, in, !, && and ||.
+// Evaluation only reads the values it is given, so it is deterministic and safe to run in
+// workflow code. An output that does not exist evaluates to null, and ordering comparisons
+// between values that are not numbers are false.
+
+const (
+	// SubmissionNamespace is the reference prefix for submission fields in conditions
+	SubmissionNamespace = "submission"
+
+	// Values for if_dependency_skipped
+	DependencySkippedSkip = "skip"
+	DependencySkippedRun  = "run"
+)
+
+// conditionRef is a ${namespace.key} reference used by a condition
+type conditionRef struct {
+	Expression string
+	Namespace  string
+	Key        string
+}
+
+type condition struct {
+	root condNode
+	refs []conditionRef
+}
+
+// conditionEnv resolves references while a condition is evaluated
+type conditionEnv struct {
+	submission map[string]any
+	results    map[string]map[string]any
+}
+
+type condNode func(env conditionEnv) (any, error)
+
+// parseCondition parses a when: expression. Syntax errors are reported with the offset they
+// were found at.
+func parseCondition(expr string) (*condition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens, cond: &condition{}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	p.cond.root = root
+	return p.cond, nil
+}
+
+// evaluate returns whether the condition holds for the given submission and step results
+func (c *condition) evaluate(env conditionEnv) (bool, error) {
+	value, err := c.root(env)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+// submissionFields are the values ${submission.<field>} can refer to
+func submissionFields(input WorkflowInput) map[string]any {
+	return map[string]any{
+		"account":       input.Account,
+		"project":       input.Project,
+		"submitter":     input.Submitter,
+		"action":        input.Action,
+		"submission_id": input.SubmissionID,
+		"deployment_id": input.DeploymentId,
+		"workflow_name": input.WorkflowName,
+	}
+}
+
+const (
+	tokEOF = iota
+	tokRef
+	tokString
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type condToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// tokenizeCondition also splits the expressions of templates, which use . and | as well
+func tokenizeCondition(expr string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(expr[i:], "${"):
+			end := strings.IndexByte(expr[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated reference at offset %d", i)
+			}
+			tokens = append(tokens, condToken{kind: tokRef, text: expr[i : i+end+1], pos: i})
+			i += end + 1
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, condToken{kind: tokString, text: expr[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			start := i
+			i++
+			for i < len(expr) && (expr[i] >= '0' && expr[i] <= '9' || expr[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokNumber, text: expr[start:i], pos: start})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(expr) && (expr[i] == '_' || expr[i] >= 'a' && expr[i] <= 'z' || expr[i] >= 'A' && expr[i] <= 'Z' || expr[i] >= '0' && expr[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokIdent, text: expr[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", ".", "|"} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, condToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, condToken{kind: tokEOF, text: "end of expression", pos: len(expr)}), nil
+}
+
+type conditionParser struct {
+	tokens []condToken
+	pos    int
+	cond   *condition
+}
+
+func (p *conditionParser) peek() condToken {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() condToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *conditionParser) accept(kind int, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) expect(text string) error {
+	if tok := p.next(); tok.kind != tokOp || tok.text != text {
+		return fmt.Errorf("expected %q at offset %d, found %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *conditionParser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env conditionEnv) (any, error) {
+			lv, err := l(env)
+			if err != nil || truthy(lv) {
+				return truthy(lv), err
+			}
+			rv, err := right(env)
+			return truthy(rv), err
+		}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (condNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env conditionEnv) (any, error) {
+			lv, err := l(env)
+			if err != nil || !truthy(lv) {
+				return false, err
+			}
+			rv, err := right(env)
+			return truthy(rv), err
+		}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (condNode, error) {
+	if p.accept(tokOp, "!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env conditionEnv) (any, error) {
+			v, err := operand(env)
+			return !truthy(v), err
+		}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (condNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	var op string
+	switch {
+	case tok.kind == tokOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		op = tok.text
+	case tok.kind == tokIdent && tok.text == "in":
+		op = tok.text
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(env conditionEnv) (any, error) {
+		lv, err := left(env)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := right(env)
+		if err != nil {
+			return nil, err
+		}
+		return compareValues(op, lv, rv)
+	}, nil
+}
+
+func (p *conditionParser) parseOperand() (condNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokRef:
+		return p.reference(tok)
+	case tokString:
+		return literal(tok.text), nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return literal(n), nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literal(true), nil
+		case "false":
+			return literal(false), nil
+		case "null":
+			return literal(nil), nil
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d, quote string values", tok.text, tok.pos)
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			return p.list()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func (p *conditionParser) list() (condNode, error) {
+	var items []condNode
+	if !p.accept(tokOp, "]") {
+		for {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if p.accept(tokOp, "]") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return func(env conditionEnv) (any, error) {
+		values := make([]any, 0, len(items))
+		for _, item := range items {
+			v, err := item(env)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}, nil
+}
+
+func (p *conditionParser) reference(tok condToken) (condNode, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(tok.text, "${"), "}"), ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid reference %s at offset %d, expected ${step.output} or ${submission.field}", tok.text, tok.pos)
+	}
+	ref := conditionRef{Expression: tok.text, Namespace: parts[0], Key: parts[1]}
+	p.cond.refs = append(p.cond.refs, ref)
+
+	if ref.Namespace == SubmissionNamespace {
+		return func(env conditionEnv) (any, error) {
+			value, ok := env.submission[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("unknown submission field %q", ref.Key)
+			}
+			return value, nil
+		}, nil
+	}
+	return func(env conditionEnv) (any, error) {
+		return env.results[ref.Namespace][ref.Key], nil
+	}, nil
+}
+
+func literal(value any) condNode {
+	return func(conditionEnv) (any, error) {
+		return value, nil
+	}
+}
+
+func compareValues(op string, left, right any) (any, error) {
+	switch op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "in":
+		items, _ := right.([]any)
+		for _, item := range items {
+			if valuesEqual(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		// Ordering only applies to numbers, anything else (including a missing output) is false
+		return false, nil
+	}
+	switch op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	default:
+		return l >= r, nil
+	}
+}
+
+// valuesEqual compares numerically when both sides are numbers and as text otherwise, so that
+// Terraform outputs, which often come back as strings, compare equal to literals.
+func valuesEqual(left, right any) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return l == r
+		}
+	}
+	return fmt.Sprint(left) == fmt.Sprint(right)
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "", "false", "0", "no":
+			return false
+		}
+		return true
+	case []any:
+		return len(v) > 0
+	}
+	if n, ok := toNumber(value); ok {
+		return n != 0
+	}
+	return true
+}
+
+// validateCondition checks that the when: condition parses and only refers to submission fields
+// and steps the step depends on, directly or transitively.
+func validateCondition(step models.Step, steps map[string]models.Step) []ValidationProblem {
+	var problems []ValidationProblem
+	if step.IfDependencySkipped != "" && step.IfDependencySkipped != DependencySkippedSkip && step.IfDependencySkipped != DependencySkippedRun {
+		problems = append(problems, ValidationProblem{
+			StepID:  step.ID,
+			Field:   "if_dependency_skipped",
+			Message: fmt.Sprintf("unsupported value %q, expected %q or %q", step.IfDependencySkipped, DependencySkippedSkip, DependencySkippedRun),
+		})
+	}
+	if step.When == "" {
+		return problems
+	}
+
+	cond, err := parseCondition(step.When)
+	if err != nil {
+		return append(problems, ValidationProblem{StepID: step.ID, Field: "when", Message: err.Error()})
+	}
+	fields := submissionFields(WorkflowInput{})
+	ancestors := stepAncestors(step.ID, steps)
+	for _, ref := range cond.refs {
+		stepID := baseStepID(ref.Namespace)
+		switch {
+		case ref.Namespace == SubmissionNamespace:
+			if _, ok := fields[ref.Key]; !ok {
+				problems = append(problems, ValidationProblem{StepID: step.ID, Field: "when", Message: fmt.Sprintf("%s references unknown submission field %q", ref.Expression, ref.Key)})
+			}
+		case stepID == step.ID:
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: "when", Message: fmt.Sprintf("%s references the step's own output", ref.Expression)})
+		case !hasStep(steps, stepID):
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: "when", Message: fmt.Sprintf("%s references unknown step %q", ref.Expression, stepID)})
+		case !ancestors[stepID]:
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: "when", Message: fmt.Sprintf("%s references step %q which is not listed in depends_on", ref.Expression, stepID)})
+		}
+	}
+	return problems
+}
+
+// conditionReferences lists the step outputs the when: condition reads, for the dry-run analysis
+func conditionReferences(step models.Step) []VariableReference {
+	if step.When == "" {
+		return nil
+	}
+	cond, err := parseCondition(step.When)
+	if err != nil {
+		return nil
+	}
+	var refs []VariableReference
+	for _, ref := range cond.refs {
+		if ref.Namespace == SubmissionNamespace {
+			continue
+		}
+		refs = append(refs, VariableReference{Variable: "when", Expression: ref.Expression, StepID: ref.Namespace, Output: ref.Key})
+	}
+	return refs
+}
@@ -0,0 +1,182 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+func testConditionEnv() conditionEnv {
+	return conditionEnv{
+		submission: submissionFields(WorkflowInput{Account: "prod", Project: "web", Action: "create"}),
+		results: map[string]map[string]any{
+			"create_vpc": {
+				"enable_nat": false,
+				"count":      float64(3),
+				"total":      "150",
+				"name":       "main",
+				"zones":      []any{"a", "b"},
+			},
+		},
+	}
+}
+
+func evaluateCondition(t *testing.T, expr string) (bool, error) {
+	t.Helper()
+	cond, err := parseCondition(expr)
+	require.NoError(t, err, expr)
+	return cond.evaluate(testConditionEnv())
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{"submission field", "${submission.account} == 'prod'", true},
+		{"not equal", "${submission.account} != 'prod'", false},
+		{"step output", "${create_vpc.name} == \"main\"", true},
+		{"bool output", "${create_vpc.enable_nat} != false", false},
+		{"in list", "${submission.project} in ['web', 'api']", true},
+		{"not in list", "${submission.project} in ['db']", false},
+		{"in empty list", "${submission.project} in []", false},
+		{"in a non-list", "${submission.project} in 'web'", false},
+		{"bare truthy value", "${create_vpc.count}", true},
+		{"bare falsy value", "${create_vpc.enable_nat}", false},
+		{"literal", "true", true},
+
+		// && binds tighter than ||, ! tighter than both
+		{"and before or", "true || false && false", true},
+		{"and before or, reversed", "false && false || true", true},
+		{"parentheses", "(true || false) && false", false},
+		{"not before and", "!false && false", false},
+		{"not of parentheses", "!(false || true)", false},
+		{"double not", "!!true", true},
+		{"not of a comparison", "!${create_vpc.count} > 5", true},
+		{"chained and", "true && true && false", false},
+		{"chained or", "false || false || true", true},
+		{"mixed", "${submission.account} == 'prod' && ${create_vpc.count} >= 3 || ${submission.project} == 'db'", true},
+
+		// Numbers compare numerically on both sides, everything else as text
+		{"number equals numeric string", "${create_vpc.total} == 150", true},
+		{"number equals float", "${create_vpc.count} == 3.0", true},
+		{"numeric strings", "'1.50' == '1.5'", true},
+		{"string against number", "${create_vpc.name} == 3", false},
+		{"bool against string", "${create_vpc.enable_nat} == 'false'", true},
+		{"greater than numeric string", "${create_vpc.total} > 100", true},
+		{"less than or equal", "${create_vpc.count} <= 3", true},
+		{"less than", "${create_vpc.count} < 3", false},
+		{"greater than or equal", "${create_vpc.count} >= 4", false},
+		{"negative number", "-1 < ${create_vpc.count}", true},
+		{"ordering of strings", "'b' > 'a'", false},
+		{"ordering of a bool", "${create_vpc.enable_nat} < 1", false},
+		{"number in list of strings", "3 in ['1', '3']", true},
+		{"null equals null", "null == null", true},
+		{"null against empty string", "null == ''", false},
+
+		// A missing output is null
+		{"missing output is null", "${create_vpc.arn} == null", true},
+		{"missing step is null", "${create_db.endpoint} == null", true},
+		{"missing output is falsy", "${create_vpc.arn}", false},
+		{"missing output not equal", "${create_vpc.arn} != 'x'", true},
+		{"missing output ordering", "${create_vpc.arn} > 1", false},
+		{"missing output in list", "${create_vpc.arn} in ['a']", false},
+		{"short circuit skips unknown field", "true || ${submission.owner} == 'x'", true},
+		{"and short circuit skips unknown field", "false && ${submission.owner} == 'x'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateCondition(t, tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConditionUnknownSubmissionField(t *testing.T) {
+	_, err := evaluateCondition(t, "${submission.owner} == 'x'")
+
+	assert.EqualError(t, err, `unknown submission field "owner"`)
+}
+
+func TestTruthy(t *testing.T) {
+	for value, want := range map[any]bool{
+		nil: false, true: true, false: false,
+		"": false, " false ": false, "0": false, "No": false, "yes": true, "prod": true,
+		float64(0): false, float64(2): true, 0: false, int64(1): true,
+	} {
+		assert.Equal(t, want, truthy(value), "%#v", value)
+	}
+	assert.False(t, truthy([]any{}))
+	assert.True(t, truthy([]any{"a"}))
+	assert.True(t, truthy(map[string]any{}))
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"empty", "", `unexpected "end of expression" at offset 0`},
+		{"unterminated reference", "${submission.account == 'prod'", "unterminated reference at offset 0"},
+		{"unterminated string", "${submission.account} == 'prod", "unterminated string at offset 25"},
+		{"unexpected character", "${submission.account} = 'prod'", `unexpected character '=' at offset 22`},
+		{"unquoted string", "${submission.account} == prod", `unexpected "prod" at offset 25, quote string values`},
+		{"missing operand", "${submission.account} ==", `unexpected "end of expression" at offset 24`},
+		{"dangling and", "true &&", `unexpected "end of expression" at offset 7`},
+		{"missing close paren", "(true || false", `expected ")" at offset 14, found "end of expression"`},
+		{"trailing token", "true false", `unexpected "false" at offset 5`},
+		{"chained comparison", "1 < 2 < 3", `unexpected "<" at offset 6`},
+		{"reference without key", "${create_vpc} == 1", "invalid reference ${create_vpc} at offset 0, expected ${step.output} or ${submission.field}"},
+		{"reference too deep", "${create_vpc.network.zone} == 1", "invalid reference ${create_vpc.network.zone} at offset 0, expected ${step.output} or ${submission.field}"},
+		{"invalid number", "1.2.3 == 1", `invalid number "1.2.3" at offset 0`},
+		{"unclosed list", "'a' in ['a', 'b'", `expected "," at offset 16, found "end of expression"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCondition(tt.expr)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	steps := map[string]models.Step{
+		"create_vpc":    {ID: "create_vpc"},
+		"create_subnet": {ID: "create_subnet", DependsOn: []string{"create_vpc"}},
+		"create_db":     {ID: "create_db"},
+	}
+	tests := []struct {
+		name string
+		step models.Step
+		want []string
+	}{
+		{"valid", models.Step{ID: "app", DependsOn: []string{"create_subnet"}, When: "${create_vpc.enable_nat} && ${submission.account} == 'prod'"}, nil},
+		{"no condition", models.Step{ID: "app"}, nil},
+		{"parse error", models.Step{ID: "app", When: "true &&"}, []string{`unexpected "end of expression" at offset 7`}},
+		{"unknown submission field", models.Step{ID: "app", When: "${submission.owner} == 'x'"}, []string{`${submission.owner} references unknown submission field "owner"`}},
+		{"own output", models.Step{ID: "create_db", When: "${create_db.endpoint}"}, []string{"${create_db.endpoint} references the step's own output"}},
+		{"unknown step", models.Step{ID: "app", When: "${create_cache.endpoint}"}, []string{`${create_cache.endpoint} references unknown step "create_cache"`}},
+		{"not a dependency", models.Step{ID: "app", DependsOn: []string{"create_subnet"}, When: "${create_db.endpoint}"}, []string{`${create_db.endpoint} references step "create_db" which is not listed in depends_on`}},
+		{"if_dependency_skipped", models.Step{ID: "app", IfDependencySkipped: "always"}, []string{`unsupported value "always", expected "skip" or "run"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := map[string]models.Step{}
+			for id, step := range steps {
+				all[id] = step
+			}
+			all[tt.step.ID] = tt.step
+			var messages []string
+			for _, problem := range validateCondition(tt.step, all) {
+				assert.Equal(t, tt.step.ID, problem.StepID)
+				messages = append(messages, problem.Message)
+			}
+			assert.Equal(t, tt.want, messages)
+		})
+	}
+}
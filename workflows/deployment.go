@@ -0,0 +1,70 @@
+package workflows
+
+import (
+	"encoding/json"
+
+	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordDeployment updates the deployment's lifecycle status once the submission is done. A
+// successful create or update also saves the document so the deployment can later be deleted by
+// its ID alone. Only a failed create marks the deployment FAILED, a failed update or delete leaves
+// its resources as they were or rolls them back, so it keeps its status and only records the error.
+func recordDeployment(ctx workflow.Context, input WorkflowInput, err error) {
+	logger := workflow.GetLogger(ctx)
+
+	deployment := db.Deployment{
+		Account:          input.Account,
+		Project:          input.Project,
+		DeploymentID:     input.DeploymentId,
+		WorkflowName:     input.WorkflowName,
+		LastSubmissionID: input.SubmissionID,
+	}
+	switch {
+	case err != nil:
+		deployment.LastError = err.Error()
+		if input.Action == "create" {
+			deployment.Status = db.DeploymentFailed
+		}
+	case input.Action == "delete":
+		deployment.Status = db.DeploymentDestroyed
+	default:
+		deployment.Status = db.DeploymentActive
+		data, marshalErr := json.Marshal(deploymentDefinition(input))
+		if marshalErr != nil {
+			logger.Error("Failed to marshal deployment definition", "error", marshalErr)
+		}
+		deployment.Definition = data
+	}
+
+	// Record the outcome even when the workflow is on its way out because it was cancelled
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	if err := workflow.ExecuteActivity(ctx, acts.RecordDeploymentActivity, deployment).Get(ctx, nil); err != nil {
+		logger.Error("Failed to record deployment", "deploymentID", input.DeploymentId, "error", err)
+	}
+}
+
+// deploymentDefinition is the document saved with the deployment. Credentials are injected per
+// submission and never stored, variables and backend settings with secret looking names are
+// redacted, so a delete that runs against the definition has to submit them again.
+func deploymentDefinition(input WorkflowInput) WorkflowInput {
+	definition := input
+	definition.SubmissionID, definition.SecretId, definition.RoleID = "", "", ""
+	if input.Variables != nil {
+		definition.Variables = activities.RedactVariables(input.Variables)
+	}
+	definition.Steps = make([]models.Step, len(input.Steps))
+	for i, step := range input.Steps {
+		if step.Variables != nil {
+			step.Variables = activities.RedactVariables(step.Variables)
+		}
+		definition.Steps[i] = step
+	}
+	if input.Backend != nil {
+		definition.Backend = &models.Backend{Type: input.Backend.Type, Config: activities.RedactVariables(input.Backend.Config)}
+	}
+	return definition
+}
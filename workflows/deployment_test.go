@@ -0,0 +1,81 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordedDeployment runs recordDeployment in a workflow and returns what it records
+func recordedDeployment(t *testing.T, input WorkflowInput, err error) db.Deployment {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var recorded db.Deployment
+	env.OnActivity(acts.RecordDeploymentActivity, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, deployment db.Deployment) error {
+			recorded = deployment
+			return nil
+		}).Once()
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		recordDeployment(ctx, input, err)
+		return nil
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	return recorded
+}
+
+func TestRecordDeployment(t *testing.T) {
+	failure := errors.New("step subnet failed")
+	tests := []struct {
+		name       string
+		action     string
+		err        error
+		wantStatus string
+		wantError  string
+		definition bool
+	}{
+		{name: "created", action: "create", wantStatus: db.DeploymentActive, definition: true},
+		{name: "updated", action: "update", wantStatus: db.DeploymentActive, definition: true},
+		{name: "deleted", action: "delete", wantStatus: db.DeploymentDestroyed},
+		// A create that fails never made the deployment active
+		{name: "failed create", action: "create", err: failure, wantStatus: db.DeploymentFailed, wantError: failure.Error()},
+		// A failed update or delete keeps the deployment's status, the error is recorded next to it
+		{name: "failed update", action: "update", err: failure, wantError: failure.Error()},
+		{name: "failed delete", action: "delete", err: failure, wantError: failure.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := WorkflowInput{
+				Account:      "acme",
+				Project:      "network",
+				DeploymentId: "prod",
+				WorkflowName: "network",
+				Action:       tt.action,
+				SubmissionID: "submission-1",
+				Steps:        []models.Step{{ID: "vpc", Executor: "terraform"}},
+			}
+
+			deployment := recordedDeployment(t, input, tt.err)
+
+			assert.Equal(t, "prod", deployment.DeploymentID)
+			assert.Equal(t, "submission-1", deployment.LastSubmissionID)
+			assert.Equal(t, tt.wantStatus, deployment.Status)
+			assert.Equal(t, tt.wantError, deployment.LastError)
+			assert.Equal(t, tt.definition, len(deployment.Definition) > 0)
+		})
+	}
+}
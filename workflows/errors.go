@@ -0,0 +1,12 @@
+package workflows
+
+// Error types the workflow fails with. They are non-retryable application errors so clients can
+// tell them apart by type from a step that failed.
+const (
+	// ErrDeploymentExists fails a create for a deployment that already has active state
+	ErrDeploymentExists = "DeploymentExists"
+	// ErrRolledBack fails a submission whose completed steps were rolled back
+	ErrRolledBack = "RolledBack"
+	// ErrRollbackFailed fails a submission whose rollback left some steps behind
+	ErrRollbackFailed = "RollbackFailed"
+)
@@ -0,0 +1,255 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/workflow"
+)
+
+// EachNamespace is the reference prefix for the current item in a for_each step's variables:
+// ${each.value}, ${each.key} and ${each.index}
+const EachNamespace = "each"
+
+// eachFields are the values ${each.<field>} can refer to
+var eachFields = []string{"value", "key", "index"}
+
+var stepIndexRegex = regexp.MustCompile(`^([a-zA-Z0-9_]+)\[([0-9]+)\]$`)
+
+// forEachItem is one element a for_each step fans out over. For lists the key is the index.
+type forEachItem struct {
+	Index int
+	Key   string
+	Value any
+}
+
+// subStepID is the ID of the sub-step for the item at index, ${create_subnet[0].subnet_id}
+// reads its outputs
+func subStepID(stepID string, index int) string {
+	return fmt.Sprintf("%s[%d]", stepID, index)
+}
+
+// baseStepID strips the index from a sub-step ID
+func baseStepID(id string) string {
+	if match := stepIndexRegex.FindStringSubmatch(id); match != nil {
+		return match[1]
+	}
+	return id
+}
+
+// forEachItems resolves the for_each value into items. Maps are ordered by key so the indexes
+// are stable. resolved is false when the value depends on an output that is known after apply.
+func forEachItems(forEach *models.ForEach, env *interpolationEnv) ([]forEachItem, bool, error) {
+	value := forEach.Value
+	if expr, isString := value.(string); isString {
+		t, err := forEachTemplate(expr)
+		if err != nil {
+			return nil, false, err
+		}
+		output, err := t.parts[0].expr(env)
+		var unresolved *unresolvedError
+		if errors.As(err, &unresolved) && unresolved.unknown {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("for_each %s: %w", strings.TrimSpace(expr), err)
+		}
+		value = output
+	}
+
+	var items []forEachItem
+	switch v := value.(type) {
+	case []any:
+		for i, item := range v {
+			items = append(items, forEachItem{Index: i, Key: fmt.Sprint(i), Value: item})
+		}
+	case []string:
+		for i, item := range v {
+			items = append(items, forEachItem{Index: i, Key: fmt.Sprint(i), Value: item})
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			items = append(items, forEachItem{Index: i, Key: key, Value: v[key]})
+		}
+	default:
+		count, isNumber := toNumber(value)
+		if !isNumber || count < 0 || count != float64(int(count)) {
+			return nil, false, fmt.Errorf("for_each resolved to %v, expected a list, a map or a count", value)
+		}
+		for i := 0; i < int(count); i++ {
+			items = append(items, forEachItem{Index: i, Key: fmt.Sprint(i), Value: i})
+		}
+	}
+	return items, true, nil
+}
+
+// forEachTemplate parses a for_each value given as a string, it has to be a single ${...}
+func forEachTemplate(expr string) (*template, error) {
+	t, err := parseTemplate(strings.TrimSpace(expr))
+	if err != nil {
+		return nil, fmt.Errorf("for_each %q: %w", expr, err)
+	}
+	if !t.single() {
+		return nil, fmt.Errorf("for_each %q must be a list, a map, a count or a single ${...} expression", expr)
+	}
+	return t, nil
+}
+
+// expandStep builds the sub-step for an item. Its variables are interpolated when it runs, with
+// the item as ${each.value}, ${each.key} and ${each.index}.
+func expandStep(step models.Step, item forEachItem) models.Step {
+	sub := step
+	sub.ID = subStepID(step.ID, item.Index)
+	sub.ForEach = nil
+	// The condition was evaluated for the step as a whole
+	sub.When = ""
+	sub.Each = map[string]any{"value": item.Value, "key": item.Key, "index": item.Index}
+	return sub
+}
+
+// aggregateResults collects every output of the sub-steps into a list ordered by index, so
+// ${create_subnet.subnet_id} is the list of all subnet IDs. Sub-steps without the output
+// contribute nil to keep the indexes aligned.
+func aggregateResults(items []stepOutcome) map[string]any {
+	keys := map[string]bool{}
+	for _, item := range items {
+		for key := range item.Result {
+			keys[key] = true
+		}
+	}
+	aggregate := make(map[string]any, len(keys))
+	for key := range keys {
+		values := make([]any, len(items))
+		for i, item := range items {
+			values[i] = item.Result[key]
+		}
+		aggregate[key] = values
+	}
+	return aggregate
+}
+
+// executeForEach fans a step out into one sub-step per item. Each sub-step gets its own DB row
+// and result and goes through executeStep, so failures are handled per item. Sub-steps have
+// their own IDs, so each also runs in its own sandbox with its own state.
+func (r *stepRunner) executeForEach(ctx workflow.Context, step models.Step) stepOutcome {
+	submissionID := r.input.SubmissionID
+
+	items, resolved, err := forEachItems(step.ForEach, newInterpolationEnv(r.input, r.results, nil))
+	if err != nil {
+		result := map[string]any{"error": err.Error()}
+		if dbErr := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepFailed, Result: result, Error: err.Error()}); dbErr != nil {
+			r.logger.Error("Failed to record for_each failure", "stepID", step.ID, "error", dbErr)
+		}
+		return stepOutcome{Step: step, Err: fmt.Errorf("step %s: %w", step.ID, err)}
+	}
+	if !resolved {
+		// Plan-only run, the list comes from a step that was only planned
+		result := map[string]any{"status": "skipped", "reason": fmt.Sprintf("for_each %v is known after apply", step.ForEach.Value)}
+		if err := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepSkipped, Result: result}); err != nil {
+			return stepOutcome{Step: step, Err: err}
+		}
+		return stepOutcome{Step: step, Result: result, Ignored: true}
+	}
+
+	if err := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepStarted, Result: map[string]any{"items": len(items)}}); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+
+	subSteps := make([]models.Step, 0, len(items))
+	for _, item := range items {
+		sub := expandStep(step, item)
+		if err := workflow.ExecuteActivity(ctx, acts.InsertStepActivity, sub, submissionID).Get(ctx, nil); err != nil {
+			return stepOutcome{Step: step, Err: fmt.Errorf("db insert step %s: %w", sub.ID, err)}
+		}
+		subSteps = append(subSteps, sub)
+	}
+
+	outcomes := make(map[string]stepOutcome, len(subSteps))
+	rollBack := false
+	scheduler := &stepScheduler{
+		// The sub-steps are independent of each other, the step's own dependencies are already met
+		waitsOn:        map[string][]string{},
+		maxParallelism: r.input.MaxParallelism,
+		run:            r.executeStep,
+		handle: func(outcome stepOutcome) (bool, error) {
+			if interrupted(ctx, outcome.Err) {
+				return false, nil
+			}
+			if outcome.Err != nil {
+				return false, outcome.Err
+			}
+			outcomes[outcome.Step.ID] = outcome
+			if outcome.RollBack {
+				rollBack = true
+			}
+			return !rollBack && ctx.Err() == nil, nil
+		},
+	}
+	if _, err := scheduler.Run(ctx, subSteps); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+
+	ordered := make([]stepOutcome, 0, len(subSteps))
+	for _, sub := range subSteps {
+		if outcome, ok := outcomes[sub.ID]; ok {
+			ordered = append(ordered, outcome)
+		}
+	}
+	if rollBack {
+		return stepOutcome{Step: step, Items: ordered, RollBack: true}
+	}
+	if ctx.Err() != nil {
+		return stepOutcome{Step: step, Items: ordered, Err: ctx.Err()}
+	}
+
+	result := aggregateResults(ordered)
+	if err := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepSuccess, Result: result}); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+	return stepOutcome{Step: step, Result: result, Items: ordered}
+}
+
+// validateForEach checks the for_each value. References have to point at a step the step
+// depends on, the items themselves are only known at runtime.
+func validateForEach(step models.Step, input WorkflowInput, steps map[string]models.Step) []ValidationProblem {
+	if step.ForEach == nil {
+		return nil
+	}
+	if step.Type == StepTypeApproval {
+		return []ValidationProblem{{StepID: step.ID, Field: "for_each", Message: "approval steps can not use for_each"}}
+	}
+
+	expr, isString := step.ForEach.Value.(string)
+	if !isString {
+		if _, _, err := forEachItems(step.ForEach, nil); err != nil {
+			return []ValidationProblem{{StepID: step.ID, Field: "for_each", Message: err.Error()}}
+		}
+		return nil
+	}
+
+	t, err := forEachTemplate(expr)
+	if err != nil {
+		return []ValidationProblem{{StepID: step.ID, Field: "for_each", Message: err.Error()}}
+	}
+	var problems []ValidationProblem
+	ancestors := stepAncestors(step.ID, steps)
+	for _, ref := range t.refs {
+		if ref.Namespace == EachNamespace {
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: "for_each", Message: fmt.Sprintf("%s can not be used in for_each itself", ref.Expression)})
+			continue
+		}
+		problems = append(problems, validateReference(step, "for_each", ref, input, steps, ancestors)...)
+	}
+	return problems
+}
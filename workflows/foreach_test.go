@@ -0,0 +1,160 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// forEachEnv resolves for_each values against the outputs of earlier steps
+func forEachEnv(action string, results map[string]map[string]any) *interpolationEnv {
+	return newInterpolationEnv(WorkflowInput{Action: action, Variables: map[string]any{"zones": []any{"a", "b"}}}, results, nil)
+}
+
+func TestForEachItems(t *testing.T) {
+	results := map[string]map[string]any{
+		"create_vpc": {
+			"subnet_cidrs": []any{"10.0.1.0/24", "10.0.2.0/24"},
+			"zones":        map[string]any{"b": "eu-1b", "a": "eu-1a"},
+		},
+	}
+	tests := []struct {
+		name  string
+		value any
+		want  []forEachItem
+	}{
+		{
+			name:  "list",
+			value: []any{"web", "db"},
+			want:  []forEachItem{{Index: 0, Key: "0", Value: "web"}, {Index: 1, Key: "1", Value: "db"}},
+		},
+		{
+			name:  "map ordered by key",
+			value: map[string]any{"prod": 3, "dev": 1},
+			want:  []forEachItem{{Index: 0, Key: "dev", Value: 1}, {Index: 1, Key: "prod", Value: 3}},
+		},
+		{
+			name:  "count",
+			value: float64(3),
+			want:  []forEachItem{{Index: 0, Key: "0", Value: 0}, {Index: 1, Key: "1", Value: 1}, {Index: 2, Key: "2", Value: 2}},
+		},
+		{
+			name:  "reference to a list output",
+			value: "${create_vpc.subnet_cidrs}",
+			want:  []forEachItem{{Index: 0, Key: "0", Value: "10.0.1.0/24"}, {Index: 1, Key: "1", Value: "10.0.2.0/24"}},
+		},
+		{
+			name:  "reference to a map output",
+			value: "${create_vpc.zones}",
+			want:  []forEachItem{{Index: 0, Key: "a", Value: "eu-1a"}, {Index: 1, Key: "b", Value: "eu-1b"}},
+		},
+		{
+			name:  "expression",
+			value: "${var.zones}",
+			want:  []forEachItem{{Index: 0, Key: "0", Value: "a"}, {Index: 1, Key: "1", Value: "b"}},
+		},
+		{
+			name:  "empty list",
+			value: []any{},
+		},
+		{
+			name:  "zero count",
+			value: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, resolved, err := forEachItems(&models.ForEach{Value: tt.value}, forEachEnv("create", results))
+			require.NoError(t, err)
+			assert.True(t, resolved)
+			assert.Equal(t, tt.want, items)
+		})
+	}
+}
+
+func TestForEachItemsKnownAfterApply(t *testing.T) {
+	// In a plan the step the output comes from was only planned
+	items, resolved, err := forEachItems(&models.ForEach{Value: "${create_vpc.subnet_cidrs}"}, forEachEnv("plan", nil))
+	require.NoError(t, err)
+	assert.False(t, resolved)
+	assert.Empty(t, items)
+}
+
+func TestForEachItemsErrors(t *testing.T) {
+	results := map[string]map[string]any{"create_vpc": {"vpc_id": "vpc-123"}}
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"text", "web, db", `for_each "web, db" must be a list, a map, a count or a single ${...} expression`},
+		{"reference in text", "subnets-${create_vpc.vpc_id}", "must be a list, a map, a count or a single ${...} expression"},
+		{"missing output", "${create_vpc.subnet_cidrs}", `for_each ${create_vpc.subnet_cidrs}: unresolved reference ${create_vpc.subnet_cidrs}: step create_vpc has no output "subnet_cidrs"`},
+		{"reference to a string", "${create_vpc.vpc_id}", "for_each resolved to vpc-123, expected a list, a map or a count"},
+		{"negative count", -1, "for_each resolved to -1, expected a list, a map or a count"},
+		{"fractional count", 1.5, "for_each resolved to 1.5, expected a list, a map or a count"},
+		{"boolean", true, "for_each resolved to true, expected a list, a map or a count"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := forEachItems(&models.ForEach{Value: tt.value}, forEachEnv("create", results))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestExpandStep(t *testing.T) {
+	step := models.Step{
+		ID:        "create_subnet",
+		DependsOn: []string{"create_vpc"},
+		ForEach:   &models.ForEach{Value: map[string]any{"a": "10.0.1.0/24"}},
+		When:      "${create_vpc.vpc_id} != ''",
+		Variables: map[string]any{"cidr": "${each.value}", "name": "subnet-${each.key}-${each.index}"},
+	}
+
+	sub := expandStep(step, forEachItem{Index: 0, Key: "a", Value: "10.0.1.0/24"})
+
+	assert.Equal(t, "create_subnet[0]", sub.ID)
+	assert.Nil(t, sub.ForEach)
+	assert.Empty(t, sub.When)
+	assert.Equal(t, []string{"create_vpc"}, sub.DependsOn)
+	assert.Equal(t, map[string]any{"value": "10.0.1.0/24", "key": "a", "index": 0}, sub.Each)
+
+	// The variables are interpolated when the sub-step runs, a whole value keeps the item's type
+	variables, err := interpolateVariables(map[string]any{
+		"cidr":  "${each.value}",
+		"name":  "subnet-${each.key}-${each.index}",
+		"index": "${each.index}",
+		"tags":  map[string]any{"zone": "${each.key}"},
+	}, newInterpolationEnv(WorkflowInput{}, nil, sub.Each))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"cidr":  "10.0.1.0/24",
+		"name":  "subnet-a-0",
+		"index": 0,
+		"tags":  map[string]any{"zone": "a"},
+	}, variables)
+}
+
+func TestAggregateResults(t *testing.T) {
+	items := []stepOutcome{
+		{Step: models.Step{ID: "create_subnet[0]"}, Result: map[string]any{"subnet_id": "subnet-a", "zone": "eu-1a"}},
+		{Step: models.Step{ID: "create_subnet[1]"}, Result: map[string]any{"subnet_id": "subnet-b"}},
+		{Step: models.Step{ID: "create_subnet[2]"}, Result: map[string]any{"subnet_id": "subnet-c", "zone": "eu-1c"}},
+	}
+
+	assert.Equal(t, map[string]any{
+		"subnet_id": []any{"subnet-a", "subnet-b", "subnet-c"},
+		// Sub-steps without the output keep the indexes aligned
+		"zone": []any{"eu-1a", nil, "eu-1c"},
+	}, aggregateResults(items))
+	assert.Empty(t, aggregateResults(nil))
+}
+
+func TestSubStepIDs(t *testing.T) {
+	assert.Equal(t, "create_subnet[12]", subStepID("create_subnet", 12))
+	assert.Equal(t, "create_subnet", baseStepID("create_subnet[12]"))
+	assert.Equal(t, "create_subnet", baseStepID("create_subnet"))
+}
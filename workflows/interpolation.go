@@ -0,0 +1,782 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Step variables and string for_each values are templates, everything between ${ and } is an
+// expression:
+//
+//	vpc_id:  ${create_vpc.vpc_id}
+//	subnet:  ${create_vpc.subnet_ids[0]}
+//	zone:    ${create_vpc.network.zone}
+//	name:    web-${submission.project}-${var.env | default "dev"}
+//	cidr:    ${cidrsubnet(var.vpc_cidr, 8, each.index)}
+//	subnets: ${join(",", create_vpc.subnet_ids)}
+//
+// References are paths of names and [indexes] into the outputs of earlier steps
+// (step.output...), the submission's variables (var.<name>), its fields (submission.<field>)
+// and, in for_each sub-steps, the current item (each.value, each.key and each.index). Quoted
+// strings, numbers, true, false, null and [lists] can be used as arguments. `x | f a` is f(a, x),
+// the piped value is passed as the last argument. The functions are default, join, split, lower,
+// format, length and cidrsubnet. They only read their arguments, so evaluating a template is
+// deterministic and safe to run in workflow code.
+//
+// A value that is nothing but one ${...} keeps the type of the result, lists stay lists and
+// numbers stay numbers. Inside a longer string results are rendered as text, lists and maps as
+// JSON. A reference without a value is an error unless default handles it. $${ is a literal ${.
+
+// VarNamespace is the reference prefix for the submission's variables, ${var.<name>}
+const VarNamespace = "var"
+
+// reservedNamespaces can not be used as step IDs, references to them would be ambiguous
+var reservedNamespaces = []string{VarNamespace, SubmissionNamespace, EachNamespace}
+
+// interpolationEnv holds what the references in a template resolve against
+type interpolationEnv struct {
+	submission map[string]any
+	vars       map[string]any
+	// each is the item of a for_each sub-step, nil for other steps
+	each    map[string]any
+	results map[string]map[string]any
+	// planOnly treats missing step outputs as known after apply, the steps were only planned
+	planOnly bool
+}
+
+func newInterpolationEnv(input WorkflowInput, results map[string]map[string]any, each map[string]any) *interpolationEnv {
+	return &interpolationEnv{
+		submission: submissionFields(input),
+		vars:       input.Variables,
+		each:       each,
+		results:    results,
+		planOnly:   input.Action == "plan",
+	}
+}
+
+// unresolvedError is returned for a reference without a value. default falls back on it, in a
+// plan-only run an unknown step output is rendered as known after apply instead.
+type unresolvedError struct {
+	ref     string
+	reason  string
+	unknown bool
+}
+
+func (e *unresolvedError) Error() string {
+	return fmt.Sprintf("unresolved reference %s: %s", e.ref, e.reason)
+}
+
+// exprRef is a reference found while parsing a template. Namespace is the step ID (with the
+// index of a sub-step), var, submission or each, and Key the name that follows it.
+type exprRef struct {
+	Expression string
+	Namespace  string
+	Key        string
+	Path       string
+	// Optional is set for references passed to default
+	Optional bool
+}
+
+type exprNode func(env *interpolationEnv) (any, error)
+
+// template is a parsed string value
+type template struct {
+	parts []templatePart
+	refs  []exprRef
+}
+
+// templatePart is literal text, or an expression when expr is set
+type templatePart struct {
+	text string
+	expr exprNode
+}
+
+// parseTemplate splits s into text and ${...} expressions and parses them
+func parseTemplate(s string) (*template, error) {
+	t := &template{}
+	if !strings.Contains(s, "${") {
+		t.parts = append(t.parts, templatePart{text: s})
+		return t, nil
+	}
+
+	var text strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			text.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ${ at offset %d", i)
+			}
+			source := s[i+2 : end]
+			node, refs, err := parseExpression(source)
+			if err != nil {
+				return nil, fmt.Errorf("${%s}: %w", source, err)
+			}
+			if text.Len() > 0 {
+				t.parts = append(t.parts, templatePart{text: text.String()})
+				text.Reset()
+			}
+			t.parts = append(t.parts, templatePart{text: source, expr: node})
+			t.refs = append(t.refs, refs...)
+			i = end + 1
+		default:
+			text.WriteByte(s[i])
+			i++
+		}
+	}
+	if text.Len() > 0 || len(t.parts) == 0 {
+		t.parts = append(t.parts, templatePart{text: text.String()})
+	}
+	return t, nil
+}
+
+// closingBrace returns the offset of the } that ends the expression starting at start, braces
+// in quoted strings don't count
+func closingBrace(s string, start int) int {
+	var quote byte
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// single reports whether the template is nothing but one expression, whose result keeps its type
+func (t *template) single() bool {
+	return len(t.parts) == 1 && t.parts[0].expr != nil
+}
+
+func (t *template) evaluate(env *interpolationEnv) (any, error) {
+	if t.single() {
+		return t.parts[0].evaluate(env)
+	}
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.expr == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		value, err := part.evaluate(env)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(renderValue(value))
+	}
+	return b.String(), nil
+}
+
+func (p templatePart) evaluate(env *interpolationEnv) (any, error) {
+	value, err := p.expr(env)
+	var unresolved *unresolvedError
+	switch {
+	case errors.As(err, &unresolved) && unresolved.unknown:
+		return fmt.Sprintf("(known after apply: %s)", strings.TrimSpace(p.text)), nil
+	case errors.As(err, &unresolved):
+		// Already names the reference
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("${%s}: %w", p.text, err)
+	}
+	return value, nil
+}
+
+// interpolateVariables evaluates the templates in a step's variables. Keys are visited in order
+// so the same variable is reported when several fail.
+func interpolateVariables(variables map[string]any, env *interpolationEnv) (map[string]any, error) {
+	resolved := make(map[string]any, len(variables))
+	for _, key := range sortedKeys(variables) {
+		value, err := interpolate(variables[key], env)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", key, err)
+		}
+		resolved[key] = value
+	}
+	return resolved, nil
+}
+
+// interpolate evaluates the templates in value, which can be a string or a list or map of them
+func interpolate(value any, env *interpolationEnv) (any, error) {
+	switch v := value.(type) {
+	case string:
+		t, err := parseTemplate(v)
+		if err != nil {
+			return nil, err
+		}
+		return t.evaluate(env)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := interpolate(item, env)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			items[i] = resolved
+		}
+		return items, nil
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for _, key := range sortedKeys(v) {
+			resolved, err := interpolate(v[key], env)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			m[key] = resolved
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderValue is the text of a value substituted into a longer string
+func renderValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case []any, []string, map[string]any:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// exprParser parses the expression of a ${...}. It shares the tokens of when: conditions.
+type exprParser struct {
+	conditionParser
+	refs []exprRef
+}
+
+func parseExpression(source string) (exprNode, []exprRef, error) {
+	tokens, err := tokenizeCondition(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &exprParser{conditionParser: conditionParser{tokens: tokens}}
+	if p.peek().kind == tokEOF {
+		return nil, nil, errors.New("empty expression")
+	}
+	node, err := p.parsePipeline()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return node, p.refs, nil
+}
+
+func (p *exprParser) parsePipeline() (exprNode, error) {
+	start := len(p.refs)
+	node, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "|") {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, fmt.Errorf("expected a function after | at offset %d, found %q", name.pos, name.text)
+		}
+		piped := len(p.refs)
+		var args []exprNode
+		for p.startsOperand() {
+			arg, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		if name.text == "default" {
+			p.markOptional(start, piped)
+		}
+		if node, err = p.call(name, append(args, node)); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) startsOperand() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case tokString, tokNumber, tokIdent:
+		return true
+	case tokOp:
+		return tok.text == "(" || tok.text == "["
+	}
+	return false
+}
+
+func (p *exprParser) markOptional(from, to int) {
+	for i := from; i < to; i++ {
+		p.refs[i].Optional = true
+	}
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return constant(tok.text), nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return constant(n), nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return constant(true), nil
+		case "false":
+			return constant(false), nil
+		case "null":
+			return constant(nil), nil
+		}
+		if p.accept(tokOp, "(") {
+			return p.callArgs(tok)
+		}
+		return p.reference(tok)
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			return p.list()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func (p *exprParser) list() (exprNode, error) {
+	var items []exprNode
+	if !p.accept(tokOp, "]") {
+		for {
+			item, err := p.parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if p.accept(tokOp, "]") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return func(env *interpolationEnv) (any, error) {
+		values := make([]any, 0, len(items))
+		for _, item := range items {
+			v, err := item(env)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}, nil
+}
+
+// callArgs parses the arguments of name(...), the opening parenthesis is already consumed
+func (p *exprParser) callArgs(name condToken) (exprNode, error) {
+	var args []exprNode
+	last := len(p.refs)
+	if !p.accept(tokOp, ")") {
+		for {
+			last = len(p.refs)
+			arg, err := p.parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(tokOp, ")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if name.text == "default" {
+		p.markOptional(last, len(p.refs))
+	}
+	return p.call(name, args)
+}
+
+func (p *exprParser) call(name condToken, args []exprNode) (exprNode, error) {
+	if name.text == "default" {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("default takes 2 arguments, got %d at offset %d", len(args), name.pos)
+		}
+		fallback, value := args[0], args[1]
+		return func(env *interpolationEnv) (any, error) {
+			v, err := value(env)
+			var unresolved *unresolvedError
+			if errors.As(err, &unresolved) && !unresolved.unknown || err == nil && (v == nil || v == "") {
+				return fallback(env)
+			}
+			return v, err
+		}, nil
+	}
+
+	fn, ok := interpolationFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	if len(args) < fn.args || !fn.variadic && len(args) > fn.args {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d at offset %d", name.text, fn.args, len(args), name.pos)
+	}
+	return func(env *interpolationEnv) (any, error) {
+		values := make([]any, len(args))
+		for i, arg := range args {
+			v, err := arg(env)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		result, err := fn.call(values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name.text, err)
+		}
+		return result, nil
+	}, nil
+}
+
+// pathSegment is a .name or an [index] of a reference
+type pathSegment struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return "." + s.name
+}
+
+func (p *exprParser) reference(root condToken) (exprNode, error) {
+	var path []pathSegment
+	for {
+		if p.accept(tokOp, ".") {
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected a name after . at offset %d, found %q", tok.pos, tok.text)
+			}
+			path = append(path, pathSegment{name: tok.text})
+			continue
+		}
+		if p.accept(tokOp, "[") {
+			tok := p.next()
+			index, err := strconv.Atoi(tok.text)
+			if tok.kind != tokNumber || err != nil || index < 0 {
+				return nil, fmt.Errorf("expected an index at offset %d, found %q", tok.pos, tok.text)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathSegment{index: index, isIndex: true})
+			continue
+		}
+		break
+	}
+
+	ref := exprRef{Namespace: root.text}
+	switch root.text {
+	case VarNamespace, SubmissionNamespace, EachNamespace:
+	default:
+		// ${create_subnet[0].subnet_id} reads the output of a for_each sub-step
+		if len(path) > 0 && path[0].isIndex {
+			ref.Namespace = subStepID(root.text, path[0].index)
+			path = path[1:]
+		}
+	}
+	if len(path) == 0 || path[0].isIndex {
+		return nil, fmt.Errorf("incomplete reference %s at offset %d, expected step.output, var.name, submission.field or each.value", root.text, root.pos)
+	}
+	ref.Key = path[0].name
+	rest := path[1:]
+	var b strings.Builder
+	for _, segment := range rest {
+		b.WriteString(segment.String())
+	}
+	ref.Path = b.String()
+	ref.Expression = fmt.Sprintf("${%s.%s%s}", ref.Namespace, ref.Key, ref.Path)
+	p.refs = append(p.refs, ref)
+
+	return func(env *interpolationEnv) (any, error) {
+		value, err := env.lookup(ref)
+		if err != nil {
+			return nil, err
+		}
+		at := ref.Namespace + "." + ref.Key
+		for _, segment := range rest {
+			if value, err = follow(value, segment, at); err != nil {
+				if unresolved, ok := err.(*unresolvedError); ok {
+					unresolved.ref = ref.Expression
+				}
+				return nil, err
+			}
+			at += segment.String()
+		}
+		return value, nil
+	}, nil
+}
+
+// lookup returns the value ref.Namespace and ref.Key point at
+func (env *interpolationEnv) lookup(ref exprRef) (any, error) {
+	missing := func(reason string, args ...any) error {
+		return &unresolvedError{ref: ref.Expression, reason: fmt.Sprintf(reason, args...)}
+	}
+	switch ref.Namespace {
+	case SubmissionNamespace:
+		value, ok := env.submission[ref.Key]
+		if !ok {
+			return nil, missing("unknown submission field %q", ref.Key)
+		}
+		return value, nil
+	case VarNamespace:
+		value, ok := env.vars[ref.Key]
+		if !ok {
+			return nil, missing("variable %q is not set", ref.Key)
+		}
+		return value, nil
+	case EachNamespace:
+		if env.each == nil {
+			return nil, missing("each is only set in for_each steps")
+		}
+		value, ok := env.each[ref.Key]
+		if !ok {
+			return nil, missing("unknown field %q, expected value, key or index", ref.Key)
+		}
+		return value, nil
+	}
+
+	outputs, ok := env.results[ref.Namespace]
+	if !ok {
+		return nil, &unresolvedError{ref: ref.Expression, reason: fmt.Sprintf("step %s has no outputs", ref.Namespace), unknown: env.planOnly}
+	}
+	value, ok := outputs[ref.Key]
+	if !ok {
+		return nil, &unresolvedError{ref: ref.Expression, reason: fmt.Sprintf("step %s has no output %q", ref.Namespace, ref.Key), unknown: env.planOnly}
+	}
+	return value, nil
+}
+
+// follow follows one segment of a reference into value, at is the part of the reference so far
+func follow(value any, segment pathSegment, at string) (any, error) {
+	if segment.isIndex {
+		items, ok := asList(value)
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not a list", at, describe(value))
+		}
+		if segment.index >= len(items) {
+			return nil, &unresolvedError{reason: fmt.Sprintf("%s has %d items", at, len(items))}
+		}
+		return items[segment.index], nil
+	}
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is %s, not a map", at, describe(value))
+	}
+	item, ok := m[segment.name]
+	if !ok {
+		return nil, &unresolvedError{reason: fmt.Sprintf("%s has no key %q", at, segment.name)}
+	}
+	return item, nil
+}
+
+func constant(value any) exprNode {
+	return func(*interpolationEnv) (any, error) {
+		return value, nil
+	}
+}
+
+func asList(value any) ([]any, bool) {
+	switch v := value.(type) {
+	case []any:
+		return v, true
+	case []string:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+func describe(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	case []any, []string:
+		return "a list"
+	case map[string]any:
+		return "a map"
+	}
+	if _, ok := toNumber(value); ok {
+		return "a number"
+	}
+	return fmt.Sprintf("a %T", value)
+}
+
+// wholeNumber converts a number argument that has to be an integer
+func wholeNumber(value any) (int64, bool) {
+	if _, isString := value.(string); isString {
+		return 0, false
+	}
+	n, ok := toNumber(value)
+	if !ok || n != float64(int64(n)) {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// interpolationFunc is a function templates can call. args is the number of arguments, the
+// minimum when the function is variadic.
+type interpolationFunc struct {
+	args     int
+	variadic bool
+	call     func(args []any) (any, error)
+}
+
+var interpolationFuncs = map[string]interpolationFunc{
+	// join(separator, list)
+	"join": {args: 2, call: func(args []any) (any, error) {
+		items, ok := asList(args[1])
+		if !ok {
+			return nil, fmt.Errorf("expected a list, got %s", describe(args[1]))
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = renderValue(item)
+		}
+		return strings.Join(parts, renderValue(args[0])), nil
+	}},
+	// split(separator, string)
+	"split": {args: 2, call: func(args []any) (any, error) {
+		s := renderValue(args[1])
+		if s == "" {
+			return []any{}, nil
+		}
+		parts := strings.Split(s, renderValue(args[0]))
+		items := make([]any, len(parts))
+		for i, part := range parts {
+			items[i] = part
+		}
+		return items, nil
+	}},
+	// lower(string)
+	"lower": {args: 1, call: func(args []any) (any, error) {
+		return strings.ToLower(renderValue(args[0])), nil
+	}},
+	// format(spec, values...) formats like Printf, whole numbers can be used with %d. A verb that
+	// doesn't fit its value is an error rather than %!d(string=x) in the result.
+	"format": {args: 1, variadic: true, call: func(args []any) (any, error) {
+		spec, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a format string, got %s", describe(args[0]))
+		}
+		values := make([]any, len(args)-1)
+		for i, arg := range args[1:] {
+			values[i] = arg
+			if n, ok := arg.(float64); ok && n == float64(int64(n)) {
+				values[i] = int64(n)
+			}
+		}
+		result := fmt.Sprintf(spec, values...)
+		// Printf reports a verb that doesn't fit its value, or a missing or extra value, as %!
+		if strings.Contains(result, "%!") && !strings.Contains(spec, "%%!") {
+			return nil, fmt.Errorf("%q does not match its %d values: %s", spec, len(values), result)
+		}
+		return result, nil
+	}},
+	// length(list, map or string)
+	"length": {args: 1, call: func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			return len(v), nil
+		case map[string]any:
+			return len(v), nil
+		}
+		if items, ok := asList(args[0]); ok {
+			return len(items), nil
+		}
+		return nil, fmt.Errorf("expected a list, a map or a string, got %s", describe(args[0]))
+	}},
+	// cidrsubnet(prefix, newbits, netnum) like Terraform's
+	"cidrsubnet": {args: 3, call: cidrSubnet},
+}
+
+// cidrSubnet extends the prefix by newbits and returns the netnum-th network of that size
+func cidrSubnet(args []any) (any, error) {
+	prefix, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a CIDR prefix, got %s", describe(args[0]))
+	}
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	newBits, ok := wholeNumber(args[1])
+	if !ok {
+		return nil, fmt.Errorf("expected a whole number of new bits, got %v", args[1])
+	}
+	netNum, ok := wholeNumber(args[2])
+	if !ok {
+		return nil, fmt.Errorf("expected a whole network number, got %v", args[2])
+	}
+	ones, bits := network.Mask.Size()
+	if newBits < 0 || int64(ones)+newBits > int64(bits) {
+		return nil, fmt.Errorf("%d new bits do not fit in %s", newBits, prefix)
+	}
+	if netNum < 0 || newBits < 63 && netNum >= int64(1)<<newBits {
+		return nil, fmt.Errorf("network number %d does not fit in %d bits", netNum, newBits)
+	}
+
+	ip := new(big.Int).SetBytes(network.IP)
+	ip.Or(ip, new(big.Int).Lsh(big.NewInt(netNum), uint(int64(bits-ones)-newBits)))
+	addr := make(net.IP, len(network.IP))
+	ip.FillBytes(addr)
+	subnet := net.IPNet{IP: addr, Mask: net.CIDRMask(ones+int(newBits), bits)}
+	return subnet.String(), nil
+}
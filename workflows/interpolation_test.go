@@ -0,0 +1,265 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEnv is what the templates in the tests resolve against
+func testEnv() *interpolationEnv {
+	return newInterpolationEnv(WorkflowInput{
+		Account: "acme",
+		Project: "Network",
+		Action:  "create",
+		Variables: map[string]any{
+			"env":       "prod",
+			"empty":     "",
+			"vpc_cidr":  "10.0.0.0/16",
+			"v6_cidr":   "2001:db8::/32",
+			"zones":     []any{"a", "b", "c"},
+			"instances": float64(3),
+			"tags":      map[string]any{"team": "infra", "cost": "ops"},
+		},
+	}, map[string]map[string]any{
+		"create_vpc": {
+			"vpc_id":     "vpc-123",
+			"subnet_ids": []any{"subnet-1", "subnet-2"},
+			"network":    map[string]any{"zone": "eu-1"},
+		},
+		"create_subnet[1]": {"subnet_id": "subnet-b"},
+	}, nil)
+}
+
+func evaluateTemplate(t *testing.T, env *interpolationEnv, source string) (any, error) {
+	t.Helper()
+	tmpl, err := parseTemplate(source)
+	require.NoError(t, err, source)
+	return tmpl.evaluate(env)
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     any
+	}{
+		{"plain text", "web", "web"},
+		{"step output", "${create_vpc.vpc_id}", "vpc-123"},
+		{"list index", "${create_vpc.subnet_ids[1]}", "subnet-2"},
+		{"nested key", "${create_vpc.network.zone}", "eu-1"},
+		{"sub-step output", "${create_subnet[1].subnet_id}", "subnet-b"},
+		{"variable", "${var.env}", "prod"},
+		{"submission field", "${submission.account}", "acme"},
+		{"single expression keeps its type", "${var.zones}", []any{"a", "b", "c"}},
+		{"number keeps its type", "${var.instances}", float64(3)},
+		{"embedded in text", "web-${submission.account}-${var.env}", "web-acme-prod"},
+		{"list rendered as JSON", "zones=${var.zones}", `zones=["a","b","c"]`},
+		{"escaped", "$${var.env}", "${var.env}"},
+		{"default of missing variable", `${var.region | default "eu"}`, "eu"},
+		{"default of empty variable", `${default("dev", var.empty)}`, "dev"},
+		{"default of set variable", `${var.env | default "dev"}`, "prod"},
+		{"default of missing index", `${create_vpc.subnet_ids[5] | default "none"}`, "none"},
+
+		{"join", `${join(",", create_vpc.subnet_ids)}`, "subnet-1,subnet-2"},
+		{"join piped", `${var.zones | join "-"}`, "a-b-c"},
+		{"split", `${split(",", "a,b")}`, []any{"a", "b"}},
+		{"split empty", `${split(",", "")}`, []any{}},
+		{"lower", `${lower(submission.project)}`, "network"},
+		{"format", `${format("%s-%d", var.env, var.instances)}`, "prod-3"},
+		{"format literal percent", `${format("%d%%", 50)}`, "50%"},
+		{"length of list", `${length(var.zones)}`, 3},
+		{"length of map", `${length(var.tags)}`, 2},
+		{"length of string", `${length(var.env)}`, 4},
+		{"nested calls", `${length(split("-", lower("A-B")))}`, 2},
+
+		{"cidrsubnet IPv4", `${cidrsubnet(var.vpc_cidr, 8, 2)}`, "10.0.2.0/24"},
+		{"cidrsubnet IPv4 unaligned bits", `${cidrsubnet("10.0.0.0/16", 4, 15)}`, "10.0.240.0/20"},
+		{"cidrsubnet IPv4 no new bits", `${cidrsubnet("10.0.0.0/16", 0, 0)}`, "10.0.0.0/16"},
+		{"cidrsubnet IPv6", `${cidrsubnet(var.v6_cidr, 16, 1)}`, "2001:db8:1::/48"},
+		{"cidrsubnet IPv6 /64", `${cidrsubnet("2001:db8::/56", 8, 255)}`, "2001:db8:0:ff::/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateTemplate(t, testEnv(), tt.template)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInterpolateUnresolvedReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"unknown variable", "${var.region}", `unresolved reference ${var.region}: variable "region" is not set`},
+		{"unknown step", "${create_db.endpoint}", "unresolved reference ${create_db.endpoint}: step create_db has no outputs"},
+		{"unknown output", "${create_vpc.arn}", `unresolved reference ${create_vpc.arn}: step create_vpc has no output "arn"`},
+		{"unknown key", "${create_vpc.network.region}", `unresolved reference ${create_vpc.network.region}: create_vpc.network has no key "region"`},
+		{"index out of range", "${create_vpc.subnet_ids[2]}", "unresolved reference ${create_vpc.subnet_ids[2]}: create_vpc.subnet_ids has 2 items"},
+		{"unknown sub-step", "${create_subnet[0].subnet_id}", "unresolved reference ${create_subnet[0].subnet_id}: step create_subnet[0] has no outputs"},
+		{"unknown submission field", "${submission.owner}", `unresolved reference ${submission.owner}: unknown submission field "owner"`},
+		{"each outside for_each", "${each.value}", "unresolved reference ${each.value}: each is only set in for_each steps"},
+		{"inside a function", `${join(",", var.subnets)}`, `unresolved reference ${var.subnets}: variable "subnets" is not set`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := evaluateTemplate(t, testEnv(), tt.template)
+			var unresolved *unresolvedError
+			require.True(t, errors.As(err, &unresolved), "got %v", err)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestInterpolatePlanOnlyUnknownOutputs(t *testing.T) {
+	env := testEnv()
+	env.planOnly = true
+
+	got, err := evaluateTemplate(t, env, "id=${create_db.endpoint}")
+	require.NoError(t, err)
+	assert.Equal(t, "id=(known after apply: create_db.endpoint)", got)
+
+	// Only step outputs are unknown in a plan, variables still have to be set
+	_, err = evaluateTemplate(t, env, "${var.region}")
+	assert.Error(t, err)
+}
+
+func TestInterpolateFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"join of a string", `${join(",", var.env)}`, "join: expected a list, got a string"},
+		{"format mismatched verb", `${format("%d", var.env)}`, `format: "%d" does not match its 1 values: %!d(string=prod)`},
+		{"format missing value", `${format("%s-%s", var.env)}`, `format: "%s-%s" does not match its 1 values: prod-%!s(MISSING)`},
+		{"format extra value", `${format("%s", var.env, "x")}`, `format: "%s" does not match its 2 values: prod%!(EXTRA string=x)`},
+		{"format of a number", `${format(1)}`, "format: expected a format string, got a number"},
+		{"length of a number", `${length(var.instances)}`, "length: expected a list, a map or a string, got a number"},
+		{"index into a string", "${var.env[0]}", "var.env is a string, not a list"},
+		{"key of a list", "${var.zones.first}", "var.zones is a list, not a map"},
+		{"cidrsubnet invalid prefix", `${cidrsubnet("10.0.0.0", 8, 0)}`, "cidrsubnet: invalid CIDR address: 10.0.0.0"},
+		{"cidrsubnet too many bits", `${cidrsubnet(var.vpc_cidr, 17, 0)}`, "cidrsubnet: 17 new bits do not fit in 10.0.0.0/16"},
+		{"cidrsubnet IPv6 too many bits", `${cidrsubnet(var.v6_cidr, 97, 0)}`, "cidrsubnet: 97 new bits do not fit in 2001:db8::/32"},
+		{"cidrsubnet netnum too large", `${cidrsubnet(var.vpc_cidr, 8, 256)}`, "cidrsubnet: network number 256 does not fit in 8 bits"},
+		{"cidrsubnet negative netnum", `${cidrsubnet(var.vpc_cidr, 8, -1)}`, "cidrsubnet: network number -1 does not fit in 8 bits"},
+		{"cidrsubnet fractional bits", `${cidrsubnet(var.vpc_cidr, 1.5, 0)}`, "cidrsubnet: expected a whole number of new bits, got 1.5"},
+		{"cidrsubnet string bits", `${cidrsubnet(var.vpc_cidr, "8", 0)}`, "cidrsubnet: expected a whole number of new bits, got 8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := evaluateTemplate(t, testEnv(), tt.template)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestInterpolateVariablesKeepsTypes(t *testing.T) {
+	results := map[string]map[string]any{
+		"create_vpc": {
+			"vpc_id":     "vpc-123",
+			"subnet_ids": []any{"subnet-1", "subnet-2"},
+			"count":      float64(3),
+			"tags":       map[string]any{"team": "infra"},
+			"enabled":    true,
+		},
+	}
+
+	resolved, err := interpolateVariables(map[string]any{
+		"vpc_id":     "${create_vpc.vpc_id}",
+		"subnet_ids": "${create_vpc.subnet_ids}",
+		"count":      "${create_vpc.count}",
+		"tags":       "${create_vpc.tags}",
+		"enabled":    "${create_vpc.enabled}",
+		"name":       "web-${create_vpc.vpc_id}-${create_vpc.count}",
+		"subnets":    "subnets=${create_vpc.subnet_ids}",
+		"labels":     "labels=${create_vpc.tags}",
+		"nested":     map[string]any{"ids": []any{"${create_vpc.subnet_ids}", "${create_vpc.vpc_id}"}},
+		"literal":    float64(8),
+	}, newInterpolationEnv(WorkflowInput{}, results, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		// A value that is nothing but a reference keeps the output's type
+		"vpc_id":     "vpc-123",
+		"subnet_ids": []any{"subnet-1", "subnet-2"},
+		"count":      float64(3),
+		"tags":       map[string]any{"team": "infra"},
+		"enabled":    true,
+		// Embedded in text, lists and maps are rendered as JSON
+		"name":    "web-vpc-123-3",
+		"subnets": `subnets=["subnet-1","subnet-2"]`,
+		"labels":  `labels={"team":"infra"}`,
+		"nested":  map[string]any{"ids": []any{[]any{"subnet-1", "subnet-2"}, "vpc-123"}},
+		"literal": float64(8),
+	}, resolved)
+}
+
+func TestInterpolateVariablesUnresolved(t *testing.T) {
+	_, err := interpolateVariables(map[string]any{
+		"name": "db",
+		"tags": map[string]any{"endpoint": "db-${create_db.endpoint}"},
+	}, newInterpolationEnv(WorkflowInput{}, nil, nil))
+	assert.EqualError(t, err, "variable tags: endpoint: unresolved reference ${create_db.endpoint}: step create_db has no outputs")
+}
+
+func TestInterpolateVariablesKnownAfterApply(t *testing.T) {
+	vars, err := interpolateVariables(map[string]any{
+		"vpc_id": "${create_vpc.vpc_id}",
+		"name":   "web-${create_vpc.name}",
+		"zones":  []any{"${create_vpc.zone}"},
+		"count":  float64(2),
+	}, newInterpolationEnv(WorkflowInput{Action: "plan"}, nil, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"vpc_id": "(known after apply: create_vpc.vpc_id)",
+		"name":   "web-(known after apply: create_vpc.name)",
+		"zones":  []any{"(known after apply: create_vpc.zone)"},
+		"count":  float64(2),
+	}, vars)
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"unterminated", "${var.env", "unterminated ${ at offset 0"},
+		{"empty", "${}", "empty expression"},
+		{"unknown function", "${upper(var.env)}", `unknown function "upper"`},
+		{"too few arguments", `${join(",")}`, "join takes 2 arguments, got 1"},
+		{"too many arguments", `${lower("a", "b")}`, "lower takes 1 arguments, got 2"},
+		{"default arguments", `${default("a")}`, "default takes 2 arguments, got 1"},
+		{"incomplete reference", "${var}", "incomplete reference var"},
+		{"index of a namespace", "${var[0]}", "incomplete reference var"},
+		{"trailing tokens", "${var.env var.env}", `unexpected "var"`},
+		{"pipe without a function", `${var.env | "x"}`, "expected a function after |"},
+		{"negative index", "${var.zones[-1]}", "expected an index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTemplate(tt.template)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestParseTemplateReferences(t *testing.T) {
+	tmpl, err := parseTemplate(`${create_subnet[2].ids[0]}-${var.env | default "dev"}-${each.value}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []exprRef{
+		{Expression: "${create_subnet[2].ids[0]}", Namespace: "create_subnet[2]", Key: "ids", Path: "[0]"},
+		{Expression: "${var.env}", Namespace: VarNamespace, Key: "env", Optional: true},
+		{Expression: "${each.value}", Namespace: EachNamespace, Key: "value"},
+	}, tmpl.refs)
+}
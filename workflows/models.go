@@ -15,6 +15,30 @@ type WorkflowInput struct {
 	Steps        []models.Step
 	SecretId     string `yaml:"secret_id,omitempty" json:"secret_id,omitempty"`
 	RoleID       string `yaml:"role_id,omitempty" json:"role_id,omitempty"`
+	// MaxParallelism caps how many independent steps run at once. Zero means no limit.
+	MaxParallelism int `yaml:"max_parallelism,omitempty" json:"max_parallelism,omitempty"`
+	// OnFailure is either "wait" (default, a failed step waits for a signal) or "rollback"
+	OnFailure string `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+	// Backend is where the Terraform/OpenTofu steps keep their state. When unset the worker uses the
+	// customer's default from customers.yaml, or local files.
+	Backend *models.Backend `yaml:"backend,omitempty" json:"backend,omitempty"`
+	// WorkspaceCleanup is the default sandbox cleanup policy for the steps: always, on_success or never
+	WorkspaceCleanup string `yaml:"workspace_cleanup,omitempty" json:"workspace_cleanup,omitempty"`
+	// Variables are the submission's own values, ${var.<name>} in the steps
+	Variables map[string]any `yaml:"variables,omitempty" json:"variables,omitempty"`
+}
+
+// UnmarshalYAML converts nested maps in the variables so they keep their structure when the
+// input is passed to Temporal as JSON
+func (w *WorkflowInput) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain WorkflowInput
+	if err := unmarshal((*plain)(w)); err != nil {
+		return err
+	}
+	for key, value := range w.Variables {
+		w.Variables[key] = models.NormalizeYAML(value)
+	}
+	return nil
 }
 
 type UpdateInputSignal struct {
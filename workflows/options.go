@@ -0,0 +1,118 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// stepActivityOptions returns ctx with the step's timeout and retry settings layered over the
+// workflow defaults. Only the executor activity uses it, the bookkeeping activities keep the defaults.
+func stepActivityOptions(ctx workflow.Context, step models.Step) (workflow.Context, error) {
+	options := workflow.GetActivityOptions(ctx)
+	// A cancelled submission waits for the executor to stop, so no step is marked CANCELLED while
+	// its command is still changing resources
+	options.WaitForCancellation = true
+
+	if step.Timeout != "" {
+		timeout, err := parseStepDuration(step.Timeout)
+		if err != nil {
+			return ctx, fmt.Errorf("step %s timeout: %w", step.ID, err)
+		}
+		options.StartToCloseTimeout = timeout
+	}
+	if step.HeartbeatTimeout != "" {
+		timeout, err := parseStepDuration(step.HeartbeatTimeout)
+		if err != nil {
+			return ctx, fmt.Errorf("step %s heartbeat_timeout: %w", step.ID, err)
+		}
+		options.HeartbeatTimeout = timeout
+	}
+
+	if step.Retry != nil {
+		policy := temporal.RetryPolicy{}
+		if options.RetryPolicy != nil {
+			policy = *options.RetryPolicy
+		}
+		if step.Retry.MaxAttempts > 0 {
+			policy.MaximumAttempts = int32(step.Retry.MaxAttempts)
+		}
+		if step.Retry.Backoff != 0 {
+			policy.BackoffCoefficient = step.Retry.Backoff
+		}
+		if step.Retry.InitialInterval != "" {
+			interval, err := parseStepDuration(step.Retry.InitialInterval)
+			if err != nil {
+				return ctx, fmt.Errorf("step %s retry.initial_interval: %w", step.ID, err)
+			}
+			policy.InitialInterval = interval
+		}
+		if step.Retry.MaximumInterval != "" {
+			interval, err := parseStepDuration(step.Retry.MaximumInterval)
+			if err != nil {
+				return ctx, fmt.Errorf("step %s retry.maximum_interval: %w", step.ID, err)
+			}
+			policy.MaximumInterval = interval
+		} else if policy.MaximumInterval < policy.InitialInterval {
+			// The inherited cap is below the step's interval, let Temporal derive one from it
+			policy.MaximumInterval = 0
+		}
+		if len(step.Retry.NonRetryable) > 0 {
+			policy.NonRetryableErrorTypes = step.Retry.NonRetryable
+		}
+		options.RetryPolicy = &policy
+	}
+
+	return workflow.WithActivityOptions(ctx, options), nil
+}
+
+// validateActivityOptions checks the per-step timeout and retry settings
+func validateActivityOptions(step models.Step) []ValidationProblem {
+	var problems []ValidationProblem
+	type durationField struct{ field, value string }
+	durations := []durationField{
+		{"timeout", step.Timeout},
+		{"heartbeat_timeout", step.HeartbeatTimeout},
+	}
+	if step.Retry != nil {
+		durations = append(durations,
+			durationField{"retry.initial_interval", step.Retry.InitialInterval},
+			durationField{"retry.maximum_interval", step.Retry.MaximumInterval},
+		)
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if _, err := parseStepDuration(d.value); err != nil {
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: d.field, Message: err.Error()})
+		}
+	}
+
+	if step.Retry == nil {
+		return problems
+	}
+	if step.Retry.MaxAttempts < 0 {
+		problems = append(problems, ValidationProblem{StepID: step.ID, Field: "retry.max_attempts", Message: "max_attempts can not be negative"})
+	}
+	if step.Retry.Backoff != 0 && step.Retry.Backoff < 1 {
+		problems = append(problems, ValidationProblem{StepID: step.ID, Field: "retry.backoff", Message: fmt.Sprintf("backoff %v must be at least 1", step.Retry.Backoff)})
+	}
+	initial, initialErr := parseStepDuration(step.Retry.InitialInterval)
+	maximum, maximumErr := parseStepDuration(step.Retry.MaximumInterval)
+	if initialErr == nil && maximumErr == nil && maximum < initial {
+		problems = append(problems, ValidationProblem{StepID: step.ID, Field: "retry.maximum_interval", Message: "maximum_interval can not be shorter than initial_interval"})
+	}
+	return problems
+}
+
+func parseStepDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected a positive duration such as 90s or 2h", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,172 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// stepOptions runs stepActivityOptions inside a workflow, over the same defaults the executor
+// workflow sets, and returns the options the executor activity would be started with
+func stepOptions(t *testing.T, step models.Step) (workflow.ActivityOptions, error) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	var options workflow.ActivityOptions
+	var optionsErr error
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 30 * time.Minute,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:    5 * time.Second,
+				BackoffCoefficient: 2.0,
+				MaximumInterval:    time.Minute,
+				MaximumAttempts:    5,
+			},
+		})
+		stepCtx, err := stepActivityOptions(ctx, step)
+		options, optionsErr = workflow.GetActivityOptions(stepCtx), err
+		return nil
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	return options, optionsErr
+}
+
+func TestStepActivityOptionsDefaults(t *testing.T) {
+	options, err := stepOptions(t, models.Step{ID: "vpc"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 30*time.Minute, options.StartToCloseTimeout)
+	assert.Zero(t, options.HeartbeatTimeout)
+	assert.Equal(t, &temporal.RetryPolicy{
+		InitialInterval:    5 * time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    time.Minute,
+		MaximumAttempts:    5,
+	}, options.RetryPolicy)
+}
+
+func TestStepActivityOptionsOverrides(t *testing.T) {
+	options, err := stepOptions(t, models.Step{
+		ID:               "vpc",
+		Timeout:          "2h",
+		HeartbeatTimeout: "90s",
+		Retry: &models.RetryPolicy{
+			MaxAttempts:     1,
+			InitialInterval: "10s",
+			MaximumInterval: "5m",
+			Backoff:         1.5,
+			NonRetryable:    []string{"InvalidVariables"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2*time.Hour, options.StartToCloseTimeout)
+	assert.Equal(t, 90*time.Second, options.HeartbeatTimeout)
+	assert.Equal(t, &temporal.RetryPolicy{
+		InitialInterval:        10 * time.Second,
+		BackoffCoefficient:     1.5,
+		MaximumInterval:        5 * time.Minute,
+		MaximumAttempts:        1,
+		NonRetryableErrorTypes: []string{"InvalidVariables"},
+	}, options.RetryPolicy)
+}
+
+func TestStepActivityOptionsKeepsUnsetRetryFields(t *testing.T) {
+	options, err := stepOptions(t, models.Step{ID: "vpc", Retry: &models.RetryPolicy{MaxAttempts: 3}})
+	require.NoError(t, err)
+
+	assert.Equal(t, &temporal.RetryPolicy{
+		InitialInterval:    5 * time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    time.Minute,
+		MaximumAttempts:    3,
+	}, options.RetryPolicy)
+}
+
+func TestStepActivityOptionsDropsInheritedMaximumBelowInterval(t *testing.T) {
+	options, err := stepOptions(t, models.Step{ID: "vpc", Retry: &models.RetryPolicy{InitialInterval: "2m"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2*time.Minute, options.RetryPolicy.InitialInterval)
+	// Temporal derives the cap from the interval
+	assert.Zero(t, options.RetryPolicy.MaximumInterval)
+}
+
+func TestStepActivityOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		step models.Step
+		want string
+	}{
+		{"timeout", models.Step{ID: "vpc", Timeout: "soon"}, `step vpc timeout: invalid duration "soon"`},
+		{"heartbeat timeout", models.Step{ID: "vpc", HeartbeatTimeout: "0s"}, `step vpc heartbeat_timeout: invalid duration "0s"`},
+		{"initial interval", models.Step{ID: "vpc", Retry: &models.RetryPolicy{InitialInterval: "-1s"}}, `step vpc retry.initial_interval: invalid duration "-1s"`},
+		{"maximum interval", models.Step{ID: "vpc", Retry: &models.RetryPolicy{MaximumInterval: "1"}}, `step vpc retry.maximum_interval: invalid duration "1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stepOptions(t, tt.step)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidateActivityOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		step models.Step
+		want []ValidationProblem
+	}{
+		{
+			name: "no overrides",
+			step: models.Step{ID: "vpc"},
+		},
+		{
+			name: "valid overrides",
+			step: models.Step{ID: "vpc", Timeout: "2h", HeartbeatTimeout: "1m", Retry: &models.RetryPolicy{
+				MaxAttempts: 1, InitialInterval: "10s", MaximumInterval: "10s", Backoff: 1,
+			}},
+		},
+		{
+			name: "bad durations",
+			step: models.Step{ID: "vpc", Timeout: "2 hours", HeartbeatTimeout: "0s", Retry: &models.RetryPolicy{
+				InitialInterval: "-5s", MaximumInterval: "x",
+			}},
+			want: []ValidationProblem{
+				{StepID: "vpc", Field: "timeout", Message: `invalid duration "2 hours", expected a positive duration such as 90s or 2h`},
+				{StepID: "vpc", Field: "heartbeat_timeout", Message: `invalid duration "0s", expected a positive duration such as 90s or 2h`},
+				{StepID: "vpc", Field: "retry.initial_interval", Message: `invalid duration "-5s", expected a positive duration such as 90s or 2h`},
+				{StepID: "vpc", Field: "retry.maximum_interval", Message: `invalid duration "x", expected a positive duration such as 90s or 2h`},
+			},
+		},
+		{
+			name: "negative max attempts",
+			step: models.Step{ID: "vpc", Retry: &models.RetryPolicy{MaxAttempts: -1}},
+			want: []ValidationProblem{{StepID: "vpc", Field: "retry.max_attempts", Message: "max_attempts can not be negative"}},
+		},
+		{
+			name: "backoff below 1",
+			step: models.Step{ID: "vpc", Retry: &models.RetryPolicy{Backoff: 0.5}},
+			want: []ValidationProblem{{StepID: "vpc", Field: "retry.backoff", Message: "backoff 0.5 must be at least 1"}},
+		},
+		{
+			name: "maximum interval below initial interval",
+			step: models.Step{ID: "vpc", Retry: &models.RetryPolicy{InitialInterval: "1m", MaximumInterval: "30s"}},
+			want: []ValidationProblem{{StepID: "vpc", Field: "retry.maximum_interval", Message: "maximum_interval can not be shorter than initial_interval"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validateActivityOptions(tt.step))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/executors"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Values accepted for the workflow level on_failure policy
+const (
+	// OnFailureWait parks a failed step until it is signalled (the default)
+	OnFailureWait = "wait"
+	// OnFailureRollback tears down everything the submission created as soon as a step fails
+	OnFailureRollback = "rollback"
+)
+
+// startRollback stops the submission and releases every step that is parked waiting on a
+// signal so the scheduler can drain before the rollback starts.
+func (r *stepRunner) startRollback(stepID string) {
+	if r.rollingBack {
+		return
+	}
+	r.logger.Warn("Rolling back submission", "submissionID", r.input.SubmissionID, "failedStep", stepID)
+	r.rollingBack = true
+
+	// Sorted so the order the parked coroutines wake up in is deterministic
+	parked := make([]string, 0, len(r.awaiting))
+	for id := range r.awaiting {
+		parked = append(parked, id)
+	}
+	sort.Strings(parked)
+	for _, id := range parked {
+		r.awaiting[id].SendAsync(RetrySignal{StepID: id, Action: "rollback"})
+	}
+}
+
+// rollback runs the delete path of every completed step in reverse dependency order, using the
+// variables each step was created with. Steps whose executor has nothing to tear down (cost
+// estimates, GitHub issues, ...) are skipped.
+func (r *stepRunner) rollback(ctx workflow.Context, completed []models.Step) error {
+	// Once started the rollback runs to the end, cancelling the submission half way would leave
+	// resources behind that nothing tracks
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+
+	var steps []models.Step
+	for _, step := range completed {
+		if !executors.CanDestroy(step.Executor) {
+			r.logger.Info("Nothing to roll back", "stepID", step.ID, "executor", step.Executor)
+			continue
+		}
+		step.Action = "delete"
+		steps = append(steps, step)
+	}
+
+	var failed []string
+	scheduler := &stepScheduler{
+		waitsOn:        rollbackDependencies(r.input.Steps, steps),
+		maxParallelism: r.input.MaxParallelism,
+		run:            r.rollbackStep,
+		handle: func(outcome stepOutcome) (bool, error) {
+			if outcome.Err != nil {
+				// Keep going, whatever can still be removed should be
+				failed = append(failed, outcome.Step.ID)
+			}
+			return true, nil
+		},
+	}
+	if _, err := scheduler.Run(ctx, steps); err != nil {
+		return err
+	}
+
+	status := db.SubmissionRolledBack
+	if len(failed) > 0 {
+		status = db.SubmissionRollbackFailed
+	}
+	if err := workflow.ExecuteActivity(ctx, acts.SubmissionStatusActivity, r.input.SubmissionID, status).Get(ctx, nil); err != nil {
+		return fmt.Errorf("db %s submission %s: %w", status, r.input.SubmissionID, err)
+	}
+	if len(failed) > 0 {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("rollback failed for steps %v", failed), ErrRollbackFailed, nil)
+	}
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf("submission %s was rolled back", r.input.SubmissionID), ErrRolledBack, nil)
+}
+
+// rollbackDependencies inverts the submission's dependency graph like a delete does, restricted
+// to the steps being rolled back. A step waits on every rolled back step that depends on it
+// through any path in graph, so one that was skipped or had nothing to tear down in between still
+// orders the two. A for_each step is only rolled back through its sub-steps, so a dependency on it
+// is a dependency on every one of them and what was built on top of it is removed before any of
+// them.
+func rollbackDependencies(graph []models.Step, steps []models.Step) map[string][]string {
+	// The graph is walked by base ID, sub-steps share the edges of the step they were fanned out of
+	dependents := make(map[string][]string)
+	for _, list := range [][]models.Step{graph, steps} {
+		for _, step := range list {
+			for _, dep := range step.DependsOn {
+				dependents[baseStepID(dep)] = append(dependents[baseStepID(dep)], baseStepID(step.ID))
+			}
+		}
+	}
+	rolledBack := make(map[string][]string)
+	for _, step := range steps {
+		base := baseStepID(step.ID)
+		rolledBack[base] = append(rolledBack[base], step.ID)
+	}
+
+	waitsOn := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		start := baseStepID(step.ID)
+		seen := map[string]bool{start: true}
+		queue := []string{start}
+		var waits []string
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, dependent := range dependents[id] {
+				if seen[dependent] {
+					continue
+				}
+				seen[dependent] = true
+				queue = append(queue, dependent)
+				waits = append(waits, rolledBack[dependent]...)
+			}
+		}
+		// Sorted so the order steps are started in is deterministic
+		sort.Strings(waits)
+		waitsOn[step.ID] = waits
+	}
+	return waitsOn
+}
+
+// rollbackError reports whether err is the outcome of a rollback, which has already recorded the
+// submission's status
+func rollbackError(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && (appErr.Type() == ErrRolledBack || appErr.Type() == ErrRollbackFailed)
+}
+
+func (r *stepRunner) rollbackStep(ctx workflow.Context, step models.Step) stepOutcome {
+	stepCtx := workflow.WithValue(ctx, "step", step.ID)
+	r.logger.Info("Rolling back step", "stepID", step.ID)
+
+	update := activities.StepStatusUpdate{Status: db.StepRolledBack}
+	step.Trigger = models.TriggerRollback
+	result, execErr := r.runStep(stepCtx, step)
+	if execErr != nil {
+		r.logger.Error("Rollback of step failed", "stepID", step.ID, "error", execErr)
+		update.Status = db.StepRollbackFailed
+		update.Error = execErr.Error()
+		result = map[string]any{"error": execErr.Error()}
+	}
+	update.Result = result
+
+	if err := r.setStatus(stepCtx, step, update); err != nil {
+		r.logger.Error("Failed to record rollback status", "stepID", step.ID, "error", err)
+	}
+	return stepOutcome{Step: step, Result: result, Err: execErr}
+}
@@ -0,0 +1,120 @@
+package workflows
+
+import (
+	"fmt"
+
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/workflow"
+)
+
+// stepOutcome is what a step goroutine reports back to the scheduler loop.
+type stepOutcome struct {
+	Step   models.Step
+	Result map[string]any
+	Err    error
+	// Ignored is set when an operator skipped past a failure, nothing was provisioned
+	Ignored bool
+	// RollBack is set when the step failed and the submission has to be rolled back
+	RollBack bool
+	// Items are the outcomes of the sub-steps of a for_each step, in index order
+	Items []stepOutcome
+}
+
+// stepScheduler starts steps as soon as the steps they wait on have completed.
+// All callbacks run on workflow coroutines, so they can share state without locking.
+type stepScheduler struct {
+	waitsOn        map[string][]string
+	maxParallelism int
+	// prepare is called right before a step starts, once everything it waits on is done
+	prepare func(step models.Step) models.Step
+	// run executes a step on its own coroutine
+	run func(ctx workflow.Context, step models.Step) stepOutcome
+	// handle is called on the main coroutine for every finished step. Returning false stops
+	// new steps from being scheduled; steps that are already running are still drained.
+	handle func(outcome stepOutcome) (bool, error)
+}
+
+// Run schedules the steps until all have finished or handle asks to stop, in which case the
+// steps that were never started are returned.
+func (s *stepScheduler) Run(ctx workflow.Context, steps []models.Step) ([]models.Step, error) {
+	pendingSteps := steps
+	completedSteps := make(map[string]bool)
+	outcomes := workflow.NewChannel(ctx)
+	running := 0
+	scheduling := true
+
+	for len(pendingSteps) > 0 || running > 0 {
+		if scheduling {
+			nextPending := []models.Step{}
+			for _, step := range pendingSteps {
+				if !dependenciesMet(s.waitsOn[step.ID], completedSteps) || !hasCapacity(running, s.maxParallelism) {
+					nextPending = append(nextPending, step)
+					continue
+				}
+				if s.prepare != nil {
+					step = s.prepare(step)
+				}
+				running++
+				workflow.Go(ctx, func(ctx workflow.Context) {
+					outcomes.Send(ctx, s.run(ctx, step))
+				})
+			}
+			pendingSteps = nextPending
+		}
+
+		if running == 0 {
+			if !scheduling {
+				break
+			}
+			// Nothing is in flight and nothing could be scheduled, so the
+			// remaining steps can never have their dependencies satisfied.
+			return pendingSteps, fmt.Errorf("steps %v have dependencies that can never be satisfied", stepIDs(pendingSteps))
+		}
+
+		var outcome stepOutcome
+		outcomes.Receive(ctx, &outcome)
+		running--
+		completedSteps[outcome.Step.ID] = true
+
+		cont, err := s.handle(outcome)
+		if err != nil {
+			return pendingSteps, err
+		}
+		if !cont {
+			scheduling = false
+		}
+	}
+	return pendingSteps, nil
+}
+
+// stepDependencies returns, per step ID, the steps that have to complete
+// before it may start. For deletes the graph is inverted so a resource is only
+// torn down once everything built on top of it is gone.
+func stepDependencies(steps []models.Step, reverse bool) map[string][]string {
+	deps := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		if _, ok := deps[step.ID]; !ok {
+			deps[step.ID] = nil
+		}
+		for _, dep := range step.DependsOn {
+			if reverse {
+				deps[dep] = append(deps[dep], step.ID)
+			} else {
+				deps[step.ID] = append(deps[step.ID], dep)
+			}
+		}
+	}
+	return deps
+}
+
+func hasCapacity(running, maxParallelism int) bool {
+	return maxParallelism <= 0 || running < maxParallelism
+}
+
+func stepIDs(steps []models.Step) []string {
+	ids := make([]string, 0, len(steps))
+	for _, step := range steps {
+		ids = append(ids, step.ID)
+	}
+	return ids
+}
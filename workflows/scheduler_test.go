@@ -0,0 +1,150 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// scheduledRun is what a stepScheduler did in a test workflow
+type scheduledRun struct {
+	// started is how long after the start of the run each step was started
+	started map[string]time.Duration
+	handled []string
+	pending []string
+	err     error
+}
+
+// runScheduler runs the steps through a scheduler inside a workflow. Every step takes a minute of
+// workflow time, steps named in failing fail. handle stops scheduling on a failure unless
+// failFast, which makes it return the error instead.
+func runScheduler(t *testing.T, steps []models.Step, maxParallelism int, failing map[string]bool, failFast bool) scheduledRun {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	run := scheduledRun{started: map[string]time.Duration{}}
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		start := workflow.Now(ctx)
+		scheduler := &stepScheduler{
+			waitsOn:        stepDependencies(steps, false),
+			maxParallelism: maxParallelism,
+			prepare: func(step models.Step) models.Step {
+				run.started[step.ID] = workflow.Now(ctx).Sub(start)
+				return step
+			},
+			run: func(ctx workflow.Context, step models.Step) stepOutcome {
+				if err := workflow.Sleep(ctx, time.Minute); err != nil {
+					return stepOutcome{Step: step, Err: err}
+				}
+				if failing[step.ID] {
+					return stepOutcome{Step: step, Err: errors.New("step " + step.ID + " failed")}
+				}
+				return stepOutcome{Step: step, Result: map[string]any{"id": step.ID}}
+			},
+			handle: func(outcome stepOutcome) (bool, error) {
+				run.handled = append(run.handled, outcome.Step.ID)
+				if outcome.Err != nil {
+					if failFast {
+						return false, outcome.Err
+					}
+					return false, nil
+				}
+				return true, nil
+			},
+		}
+		pending, err := scheduler.Run(ctx, steps)
+		run.pending, run.err = stepIDs(pending), err
+		return nil
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	return run
+}
+
+func TestStepSchedulerRunsLayersInParallel(t *testing.T) {
+	steps := []models.Step{
+		{ID: "instance", DependsOn: []string{"subnet_a", "subnet_b"}},
+		{ID: "subnet_a", DependsOn: []string{"vpc"}},
+		{ID: "subnet_b", DependsOn: []string{"vpc"}},
+		{ID: "vpc"},
+		{ID: "dns"},
+	}
+
+	run := runScheduler(t, steps, 0, nil, false)
+
+	require.NoError(t, run.err)
+	assert.Equal(t, map[string]time.Duration{
+		"vpc":      0,
+		"dns":      0,
+		"subnet_a": time.Minute,
+		"subnet_b": time.Minute,
+		"instance": 2 * time.Minute,
+	}, run.started)
+	assert.ElementsMatch(t, stepIDs(steps), run.handled)
+	assert.Empty(t, run.pending)
+}
+
+func TestStepSchedulerMaxParallelism(t *testing.T) {
+	steps := []models.Step{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	run := runScheduler(t, steps, 2, nil, false)
+
+	require.NoError(t, run.err)
+	assert.Equal(t, map[string]time.Duration{"a": 0, "b": 0, "c": time.Minute}, run.started)
+}
+
+func TestStepSchedulerErrorStopsTheRun(t *testing.T) {
+	steps := []models.Step{
+		{ID: "vpc"},
+		{ID: "subnet", DependsOn: []string{"vpc"}},
+		{ID: "instance", DependsOn: []string{"subnet"}},
+		{ID: "dns", DependsOn: []string{"vpc"}},
+		{ID: "record", DependsOn: []string{"dns"}},
+	}
+
+	run := runScheduler(t, steps, 1, map[string]bool{"subnet": true}, true)
+
+	require.EqualError(t, run.err, "step subnet failed")
+	// dns was waiting for the free slot subnet held, nothing after the failure starts
+	assert.Equal(t, map[string]time.Duration{"vpc": 0, "subnet": time.Minute}, run.started)
+	assert.Equal(t, []string{"vpc", "subnet"}, run.handled)
+	assert.Equal(t, []string{"instance", "dns", "record"}, run.pending)
+}
+
+func TestStepSchedulerStopDrainsRunningSteps(t *testing.T) {
+	steps := []models.Step{
+		{ID: "vpc"},
+		{ID: "dns"},
+		{ID: "subnet", DependsOn: []string{"vpc"}},
+		{ID: "record", DependsOn: []string{"dns"}},
+	}
+
+	run := runScheduler(t, steps, 0, map[string]bool{"vpc": true}, false)
+
+	require.NoError(t, run.err)
+	// dns was already running when vpc failed, it finishes and is handled, its dependents are not
+	// started
+	assert.Equal(t, map[string]time.Duration{"vpc": 0, "dns": 0}, run.started)
+	assert.ElementsMatch(t, []string{"vpc", "dns"}, run.handled)
+	assert.Equal(t, []string{"subnet", "record"}, run.pending)
+}
+
+func TestStepSchedulerUnsatisfiableDependencies(t *testing.T) {
+	steps := []models.Step{
+		{ID: "vpc"},
+		{ID: "subnet", DependsOn: []string{"network"}},
+	}
+
+	run := runScheduler(t, steps, 0, nil, false)
+
+	require.EqualError(t, run.err, "steps [subnet] have dependencies that can never be satisfied")
+	assert.Equal(t, map[string]time.Duration{"vpc": 0}, run.started)
+	assert.Equal(t, []string{"subnet"}, run.pending)
+}
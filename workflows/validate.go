@@ -0,0 +1,485 @@
+package workflows
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/surajsub/temporal-rest-dsl/executors"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// Actions accepted at the top level of a DSL document
+var supportedActions = []string{"create", "delete", "update", "plan"}
+
+// ValidationProblem is a single issue found while validating a DSL document
+type ValidationProblem struct {
+	StepID  string `json:"step_id,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateWorkflowInput checks the step graph of a submission before it is handed to Temporal.
+// It returns every problem it finds rather than stopping at the first one so the submitter can
+// fix the document in one go. An empty result means the document is safe to execute.
+func ValidateWorkflowInput(input WorkflowInput) []ValidationProblem {
+	var problems []ValidationProblem
+
+	if input.Action != "" && !contains(supportedActions, input.Action) {
+		problems = append(problems, ValidationProblem{
+			Field:   "action",
+			Message: fmt.Sprintf("unsupported action %q, expected one of %v", input.Action, supportedActions),
+		})
+	}
+	if input.OnFailure != "" && input.OnFailure != OnFailureWait && input.OnFailure != OnFailureRollback {
+		problems = append(problems, ValidationProblem{
+			Field:   "on_failure",
+			Message: fmt.Sprintf("unsupported on_failure policy %q, expected %q or %q", input.OnFailure, OnFailureWait, OnFailureRollback),
+		})
+	}
+	if input.OnFailure == OnFailureRollback && input.Action != "" && input.Action != "create" {
+		problems = append(problems, ValidationProblem{
+			Field:   "on_failure",
+			Message: "rollback is only supported for create submissions",
+		})
+	}
+	if !validCleanup(input.WorkspaceCleanup) {
+		problems = append(problems, ValidationProblem{
+			Field:   "workspace_cleanup",
+			Message: fmt.Sprintf("unsupported workspace_cleanup policy %q, expected one of %v", input.WorkspaceCleanup, cleanupPolicies),
+		})
+	}
+	if err := executors.ValidateSubmittedBackend(input.Backend); err != nil {
+		problems = append(problems, ValidationProblem{Field: "backend", Message: err.Error()})
+	}
+	if len(input.Steps) == 0 {
+		problems = append(problems, ValidationProblem{Field: "steps", Message: "at least one step is required"})
+	}
+
+	// Duplicate and missing IDs
+	steps := make(map[string]models.Step, len(input.Steps))
+	for i, step := range input.Steps {
+		if step.ID == "" {
+			problems = append(problems, ValidationProblem{
+				Field:   fmt.Sprintf("steps[%d].id", i),
+				Message: "step id is required",
+			})
+			continue
+		}
+		if contains(reservedNamespaces, step.ID) {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.ID,
+				Field:   "id",
+				Message: fmt.Sprintf("step id %q is reserved for references", step.ID),
+			})
+		}
+		if _, exists := steps[step.ID]; exists {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.ID,
+				Field:   "id",
+				Message: fmt.Sprintf("duplicate step id %q", step.ID),
+			})
+			continue
+		}
+		steps[step.ID] = step
+	}
+
+	for _, step := range input.Steps {
+		if step.ID == "" {
+			continue
+		}
+		problems = append(problems, validateExecutor(step)...)
+		if !validCleanup(step.WorkspaceCleanup) {
+			problems = append(problems, ValidationProblem{
+				StepID:  step.ID,
+				Field:   "workspace_cleanup",
+				Message: fmt.Sprintf("unsupported workspace_cleanup policy %q, expected one of %v", step.WorkspaceCleanup, cleanupPolicies),
+			})
+		}
+		problems = append(problems, validateActivityOptions(step)...)
+		problems = append(problems, validateCondition(step, steps)...)
+		problems = append(problems, validateForEach(step, input, steps)...)
+
+		for _, dep := range step.DependsOn {
+			if _, exists := steps[dep]; !exists {
+				problems = append(problems, ValidationProblem{
+					StepID:  step.ID,
+					Field:   "depends_on",
+					Message: fmt.Sprintf("depends on unknown step %q", dep),
+				})
+			}
+			if dep == step.ID {
+				problems = append(problems, ValidationProblem{
+					StepID:  step.ID,
+					Field:   "depends_on",
+					Message: "step depends on itself",
+				})
+			}
+		}
+	}
+
+	problems = append(problems, findCycles(input.Steps, steps)...)
+
+	for _, step := range input.Steps {
+		if step.ID == "" {
+			continue
+		}
+		ancestors := stepAncestors(step.ID, steps)
+		walkTemplates(step.Variables, func(variable string, t *template, err error) {
+			field := "variables." + variable
+			if err != nil {
+				problems = append(problems, ValidationProblem{StepID: step.ID, Field: field, Message: err.Error()})
+				return
+			}
+			for _, ref := range t.refs {
+				problems = append(problems, validateReference(step, field, ref, input, steps, ancestors)...)
+			}
+		})
+	}
+
+	return problems
+}
+
+// validateReference checks a reference in the step's variables or for_each value. Step outputs
+// have to come from a step the step depends on, directly or transitively.
+func validateReference(step models.Step, field string, ref exprRef, input WorkflowInput, steps map[string]models.Step, ancestors map[string]bool) []ValidationProblem {
+	problem := func(format string, args ...any) []ValidationProblem {
+		return []ValidationProblem{{StepID: step.ID, Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+	switch ref.Namespace {
+	case SubmissionNamespace:
+		if _, ok := submissionFields(WorkflowInput{})[ref.Key]; !ok {
+			return problem("%s references unknown submission field %q", ref.Expression, ref.Key)
+		}
+		return nil
+	case VarNamespace:
+		if _, ok := input.Variables[ref.Key]; !ok && !ref.Optional {
+			return problem("%s references variable %q which is not set, add it to variables or give it a default", ref.Expression, ref.Key)
+		}
+		return nil
+	case EachNamespace:
+		if step.ForEach == nil {
+			return problem("%s can only be used in a for_each step", ref.Expression)
+		}
+		if !contains(eachFields, ref.Key) {
+			return problem("%s is not one of ${each.value}, ${each.key} or ${each.index}", ref.Expression)
+		}
+		return nil
+	}
+
+	stepID := baseStepID(ref.Namespace)
+	switch {
+	case stepID == step.ID:
+		return problem("%s references the step's own output", ref.Expression)
+	case !hasStep(steps, stepID):
+		return problem("%s references unknown step %q", ref.Expression, stepID)
+	case !ancestors[stepID]:
+		return problem("%s references step %q which is not listed in depends_on", ref.Expression, stepID)
+	}
+	return nil
+}
+
+func validateExecutor(step models.Step) []ValidationProblem {
+	switch step.Type {
+	case "":
+	case StepTypeApproval:
+		return validateApproval(step)
+	default:
+		return []ValidationProblem{{StepID: step.ID, Field: "type", Message: fmt.Sprintf("unknown step type %q", step.Type)}}
+	}
+
+	if step.Executor == "" {
+		return []ValidationProblem{{StepID: step.ID, Field: "executor", Message: "executor is required"}}
+	}
+	if !executors.HasExecutor(step.Executor) {
+		return []ValidationProblem{{StepID: step.ID, Field: "executor", Message: fmt.Sprintf("unknown executor %q", step.Executor)}}
+	}
+	// Provisioning executors are driven by the top level action, so the operation is optional for them
+	if step.Operation == "" {
+		return nil
+	}
+	operations := executors.SupportedOperations(step.Executor)
+	if !contains(operations, step.Operation) {
+		return []ValidationProblem{{
+			StepID:  step.ID,
+			Field:   "operation",
+			Message: fmt.Sprintf("operation %q is not supported by executor %s, expected one of %v", step.Operation, step.Executor, operations),
+		}}
+	}
+	return nil
+}
+
+func validateApproval(step models.Step) []ValidationProblem {
+	var problems []ValidationProblem
+	if step.Executor != "" {
+		problems = append(problems, ValidationProblem{StepID: step.ID, Field: "executor", Message: "approval steps are handled by the workflow and take no executor"})
+	}
+	if step.Approval == nil {
+		return problems
+	}
+	if step.Approval.Timeout != "" {
+		if timeout, err := time.ParseDuration(step.Approval.Timeout); err != nil || timeout <= 0 {
+			problems = append(problems, ValidationProblem{StepID: step.ID, Field: "approval.timeout", Message: fmt.Sprintf("invalid timeout %q, expected a positive duration such as 24h", step.Approval.Timeout)})
+		}
+	}
+	if step.Approval.Default != "" && step.Approval.Default != DecisionApprove && step.Approval.Default != DecisionReject {
+		problems = append(problems, ValidationProblem{StepID: step.ID, Field: "approval.default", Message: fmt.Sprintf("default decision must be %q or %q", DecisionApprove, DecisionReject)})
+	}
+	return problems
+}
+
+// findCycles reports each dependency cycle once, starting from the first step of the cycle in
+// submission order.
+func findCycles(order []models.Step, steps map[string]models.Step) []ValidationProblem {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var problems []ValidationProblem
+	color := make(map[string]int, len(steps))
+	var path []string
+
+	var visit func(id string)
+	visit = func(id string) {
+		color[id] = visiting
+		path = append(path, id)
+		for _, dep := range steps[id].DependsOn {
+			if dep == id || !hasStep(steps, dep) {
+				continue
+			}
+			switch color[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				cycle := []string{}
+				for i := len(path) - 1; i >= 0; i-- {
+					cycle = append([]string{path[i]}, cycle...)
+					if path[i] == dep {
+						break
+					}
+				}
+				cycle = append(cycle, dep)
+				problems = append(problems, ValidationProblem{
+					StepID:  dep,
+					Field:   "depends_on",
+					Message: fmt.Sprintf("dependency cycle: %s", strings.Join(cycle, " -> ")),
+				})
+			}
+		}
+		path = path[:len(path)-1]
+		color[id] = visited
+	}
+
+	for _, step := range order {
+		if step.ID != "" && color[step.ID] == unvisited {
+			visit(step.ID)
+		}
+	}
+	return problems
+}
+
+// stepAncestors returns every step that is guaranteed to have completed before id starts
+func stepAncestors(id string, steps map[string]models.Step) map[string]bool {
+	ancestors := make(map[string]bool)
+	queue := append([]string{}, steps[id].DependsOn...)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+		if ancestors[dep] {
+			continue
+		}
+		ancestors[dep] = true
+		queue = append(queue, steps[dep].DependsOn...)
+	}
+	return ancestors
+}
+
+// VariableReference is a single ${step.output} or ${each.*} reference found in a step's variables
+type VariableReference struct {
+	Variable   string `json:"variable"`
+	Expression string `json:"expression"`
+	StepID     string `json:"step_id"`
+	Output     string `json:"output"`
+}
+
+// variableReferences walks the step variables (including nested lists and maps) and returns the
+// step outputs and for_each items their templates read, ordered by variable name so results are
+// stable. Templates that do not parse are left out, validation reports them.
+func variableReferences(variables map[string]any) []VariableReference {
+	var refs []VariableReference
+	walkTemplates(variables, func(variable string, t *template, err error) {
+		if err != nil {
+			return
+		}
+		for _, ref := range t.refs {
+			if ref.Namespace == VarNamespace || ref.Namespace == SubmissionNamespace {
+				continue
+			}
+			refs = append(refs, VariableReference{
+				Variable:   variable,
+				Expression: ref.Expression,
+				StepID:     ref.Namespace,
+				Output:     ref.Key + ref.Path,
+			})
+		}
+	})
+	return refs
+}
+
+// walkTemplates parses every string in the variables, including those in nested lists and maps,
+// and calls fn with the variable it belongs to. Variables and map keys are visited in order.
+func walkTemplates(variables map[string]any, fn func(variable string, t *template, err error)) {
+	var walk func(variable string, value any)
+	walk = func(variable string, value any) {
+		switch v := value.(type) {
+		case string:
+			t, err := parseTemplate(v)
+			fn(variable, t, err)
+		case []any:
+			for _, item := range v {
+				walk(variable, item)
+			}
+		case map[string]any:
+			for _, key := range sortedKeys(v) {
+				walk(variable, v[key])
+			}
+		}
+	}
+	for _, key := range sortedKeys(variables) {
+		walk(key, variables[key])
+	}
+}
+
+func hasStep(steps map[string]models.Step, id string) bool {
+	_, exists := steps[id]
+	return exists
+}
+
+var cleanupPolicies = []string{executors.CleanupAlways, executors.CleanupOnSuccess, executors.CleanupNever}
+
+func validCleanup(policy string) bool {
+	return policy == "" || contains(cleanupPolicies, policy)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkflowAnalysis is the dry-run view of a DSL document returned by the validate endpoint
+type WorkflowAnalysis struct {
+	Valid          bool                           `json:"valid"`
+	Problems       []ValidationProblem            `json:"problems,omitempty"`
+	Warnings       []ValidationProblem            `json:"warnings,omitempty"`
+	ExecutionOrder [][]string                     `json:"execution_order"`
+	References     map[string][]VariableReference `json:"references"`
+}
+
+// AnalyzeWorkflowInput validates the document and resolves the order the workflow would run
+// the steps in without executing anything.
+func AnalyzeWorkflowInput(input WorkflowInput) WorkflowAnalysis {
+	problems := ValidateWorkflowInput(input)
+	analysis := WorkflowAnalysis{
+		Valid:          len(problems) == 0,
+		Problems:       problems,
+		Warnings:       workflowWarnings(input),
+		ExecutionOrder: executionLayers(input.Steps, input.Action == "delete"),
+		References:     make(map[string][]VariableReference),
+	}
+	for _, step := range input.Steps {
+		refs := append(variableReferences(step.Variables), conditionReferences(step)...)
+		if len(refs) > 0 {
+			analysis.References[step.ID] = refs
+		}
+	}
+	return analysis
+}
+
+// executionLayers groups the steps into layers that can run concurrently. Every step in a layer
+// only depends on steps in earlier layers. Steps that are part of a cycle or wait on an unknown
+// step never become ready and are left out.
+func executionLayers(steps []models.Step, reverse bool) [][]string {
+	waitsOn := stepDependencies(steps, reverse)
+	completed := make(map[string]bool, len(steps))
+	pending := stepIDs(steps)
+	layers := [][]string{}
+
+	for len(pending) > 0 {
+		layer := []string{}
+		next := []string{}
+		for _, id := range pending {
+			if dependenciesMet(waitsOn[id], completed) {
+				layer = append(layer, id)
+			} else {
+				next = append(next, id)
+			}
+		}
+		if len(layer) == 0 {
+			break
+		}
+		for _, id := range layer {
+			completed[id] = true
+		}
+		layers = append(layers, layer)
+		pending = next
+	}
+	return layers
+}
+
+// workflowWarnings flags things that won't stop the workflow from being scheduled but are likely
+// to make a step fail once it runs.
+func workflowWarnings(input WorkflowInput) []ValidationProblem {
+	var warnings []ValidationProblem
+
+	if input.MaxParallelism < 0 {
+		warnings = append(warnings, ValidationProblem{
+			Field:   "max_parallelism",
+			Message: "negative max_parallelism is treated as unlimited",
+		})
+	}
+
+	for _, step := range input.Steps {
+		seen := make(map[string]bool, len(step.DependsOn))
+		for _, dep := range step.DependsOn {
+			if seen[dep] {
+				warnings = append(warnings, ValidationProblem{
+					StepID:  step.ID,
+					Field:   "depends_on",
+					Message: fmt.Sprintf("%q is listed more than once", dep),
+				})
+			}
+			seen[dep] = true
+		}
+
+		switch step.Executor {
+		case executors.TERRAFORM, executors.OPENTOFU, executors.INFRACOST:
+			if step.Workspace == "" {
+				warnings = append(warnings, ValidationProblem{
+					StepID:  step.ID,
+					Field:   "workspace",
+					Message: fmt.Sprintf("%s steps need a workspace", step.Executor),
+				})
+			} else if _, err := os.Stat(step.Workspace); err != nil {
+				warnings = append(warnings, ValidationProblem{
+					StepID:  step.ID,
+					Field:   "workspace",
+					Message: fmt.Sprintf("workspace %s is not present on this host", step.Workspace),
+				})
+			}
+		}
+		if step.Executor == executors.INFRACOST && step.Provisioner == "" {
+			warnings = append(warnings, ValidationProblem{
+				StepID:  step.ID,
+				Field:   "provisioner",
+				Message: "infracost steps need a provisioner (terraform or tofu) to build the plan",
+			})
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,241 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/surajsub/temporal-rest-dsl/models"
+)
+
+// terraformStep is a step that passes executor validation
+func terraformStep(id string, dependsOn ...string) models.Step {
+	return models.Step{ID: id, Executor: "terraform", DependsOn: dependsOn}
+}
+
+func withVariables(step models.Step, variables map[string]any) models.Step {
+	step.Variables = variables
+	return step
+}
+
+func TestValidateWorkflowInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input WorkflowInput
+		want  []ValidationProblem
+	}{
+		{
+			name: "valid",
+			input: WorkflowInput{Action: "create", Steps: []models.Step{
+				terraformStep("vpc"),
+				withVariables(terraformStep("subnet", "vpc"), map[string]any{"vpc_id": "${vpc.vpc_id}"}),
+				withVariables(terraformStep("instance", "subnet"), map[string]any{"vpc_id": "${vpc.vpc_id}"}),
+			}},
+		},
+		{
+			name:  "unsupported action",
+			input: WorkflowInput{Action: "apply", Steps: []models.Step{terraformStep("vpc")}},
+			want:  []ValidationProblem{{Field: "action", Message: `unsupported action "apply", expected one of [create delete update plan]`}},
+		},
+		{
+			name:  "no steps",
+			input: WorkflowInput{Action: "create"},
+			want:  []ValidationProblem{{Field: "steps", Message: "at least one step is required"}},
+		},
+		{
+			name:  "missing id",
+			input: WorkflowInput{Steps: []models.Step{terraformStep("vpc"), terraformStep("")}},
+			want:  []ValidationProblem{{Field: "steps[1].id", Message: "step id is required"}},
+		},
+		{
+			name:  "duplicate ids",
+			input: WorkflowInput{Steps: []models.Step{terraformStep("vpc"), terraformStep("subnet"), terraformStep("vpc")}},
+			want:  []ValidationProblem{{StepID: "vpc", Field: "id", Message: `duplicate step id "vpc"`}},
+		},
+		{
+			name:  "unknown dependency",
+			input: WorkflowInput{Steps: []models.Step{terraformStep("vpc"), terraformStep("subnet", "network")}},
+			want:  []ValidationProblem{{StepID: "subnet", Field: "depends_on", Message: `depends on unknown step "network"`}},
+		},
+		{
+			name:  "depends on itself",
+			input: WorkflowInput{Steps: []models.Step{terraformStep("vpc", "vpc")}},
+			want:  []ValidationProblem{{StepID: "vpc", Field: "depends_on", Message: "step depends on itself"}},
+		},
+		{
+			name:  "two step cycle",
+			input: WorkflowInput{Steps: []models.Step{terraformStep("vpc", "subnet"), terraformStep("subnet", "vpc")}},
+			want:  []ValidationProblem{{StepID: "vpc", Field: "depends_on", Message: "dependency cycle: vpc -> subnet -> vpc"}},
+		},
+		{
+			name: "cycle reported from where it closes",
+			input: WorkflowInput{Steps: []models.Step{
+				terraformStep("base"),
+				terraformStep("vpc", "base", "instance"),
+				terraformStep("subnet", "vpc"),
+				terraformStep("instance", "subnet"),
+			}},
+			want: []ValidationProblem{{StepID: "vpc", Field: "depends_on", Message: "dependency cycle: vpc -> instance -> subnet -> vpc"}},
+		},
+		{
+			name: "each cycle reported once",
+			input: WorkflowInput{Steps: []models.Step{
+				terraformStep("a", "b"),
+				terraformStep("b", "a"),
+				terraformStep("c", "d"),
+				terraformStep("d", "c"),
+			}},
+			want: []ValidationProblem{
+				{StepID: "a", Field: "depends_on", Message: "dependency cycle: a -> b -> a"},
+				{StepID: "c", Field: "depends_on", Message: "dependency cycle: c -> d -> c"},
+			},
+		},
+		{
+			name:  "missing executor",
+			input: WorkflowInput{Steps: []models.Step{{ID: "vpc"}}},
+			want:  []ValidationProblem{{StepID: "vpc", Field: "executor", Message: "executor is required"}},
+		},
+		{
+			name:  "unknown executor",
+			input: WorkflowInput{Steps: []models.Step{{ID: "vpc", Executor: "pulumi"}}},
+			want:  []ValidationProblem{{StepID: "vpc", Field: "executor", Message: `unknown executor "pulumi"`}},
+		},
+		{
+			name:  "unsupported operation",
+			input: WorkflowInput{Steps: []models.Step{{ID: "issue", Executor: "git", Operation: "deploy"}}},
+			want: []ValidationProblem{{StepID: "issue", Field: "operation",
+				Message: "operation \"deploy\" is not supported by executor git, expected one of [create_issue poll_issue_status]"}},
+		},
+		{
+			name: "reference to unknown step",
+			input: WorkflowInput{Steps: []models.Step{
+				withVariables(terraformStep("subnet"), map[string]any{"vpc_id": "${vpc.vpc_id}"}),
+			}},
+			want: []ValidationProblem{{StepID: "subnet", Field: "variables.vpc_id", Message: `${vpc.vpc_id} references unknown step "vpc"`}},
+		},
+		{
+			name: "reference to a step that is not a dependency",
+			input: WorkflowInput{Steps: []models.Step{
+				terraformStep("vpc"),
+				withVariables(terraformStep("subnet"), map[string]any{"cidrs": []any{"${vpc.cidr}"}}),
+			}},
+			want: []ValidationProblem{{StepID: "subnet", Field: "variables.cidrs", Message: `${vpc.cidr} references step "vpc" which is not listed in depends_on`}},
+		},
+		{
+			name: "reference to own output",
+			input: WorkflowInput{Steps: []models.Step{
+				withVariables(terraformStep("vpc"), map[string]any{"name": "vpc-${vpc.vpc_id}"}),
+			}},
+			want: []ValidationProblem{{StepID: "vpc", Field: "variables.name", Message: "${vpc.vpc_id} references the step's own output"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateWorkflowInput(tt.input))
+		})
+	}
+}
+
+func TestExecutionLayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []models.Step
+		reverse bool
+		want    [][]string
+	}{
+		{
+			name:  "independent steps share a layer",
+			steps: []models.Step{terraformStep("vpc"), terraformStep("bucket"), terraformStep("dns")},
+			want:  [][]string{{"vpc", "bucket", "dns"}},
+		},
+		{
+			name: "diamond",
+			steps: []models.Step{
+				terraformStep("instance", "subnet", "sg"),
+				terraformStep("subnet", "vpc"),
+				terraformStep("sg", "vpc"),
+				terraformStep("vpc"),
+			},
+			want: [][]string{{"vpc"}, {"subnet", "sg"}, {"instance"}},
+		},
+		{
+			name: "delete runs dependents first",
+			steps: []models.Step{
+				terraformStep("vpc"),
+				terraformStep("subnet", "vpc"),
+				terraformStep("sg", "vpc"),
+				terraformStep("instance", "subnet", "sg"),
+			},
+			reverse: true,
+			want:    [][]string{{"instance"}, {"subnet", "sg"}, {"vpc"}},
+		},
+		{
+			name: "cycles and unknown dependencies are left out",
+			steps: []models.Step{
+				terraformStep("vpc"),
+				terraformStep("a", "b"),
+				terraformStep("b", "a"),
+				terraformStep("subnet", "network"),
+				terraformStep("instance", "vpc"),
+			},
+			want: [][]string{{"vpc"}, {"instance"}},
+		},
+		{
+			name: "no steps",
+			want: [][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, executionLayers(tt.steps, tt.reverse))
+		})
+	}
+}
+
+func TestAnalyzeWorkflowInput(t *testing.T) {
+	input := WorkflowInput{
+		Action:         "create",
+		MaxParallelism: -1,
+		Steps: []models.Step{
+			terraformStep("vpc"),
+			withVariables(terraformStep("subnet", "vpc", "vpc"), map[string]any{
+				"vpc_id": "${vpc.vpc_id}",
+				"name":   "subnet-${vpc.name}",
+			}),
+		},
+	}
+
+	analysis := AnalyzeWorkflowInput(input)
+
+	assert.True(t, analysis.Valid)
+	assert.Empty(t, analysis.Problems)
+	assert.Equal(t, [][]string{{"vpc"}, {"subnet"}}, analysis.ExecutionOrder)
+	assert.Equal(t, map[string][]VariableReference{
+		"subnet": {
+			{Variable: "name", Expression: "${vpc.name}", StepID: "vpc", Output: "name"},
+			{Variable: "vpc_id", Expression: "${vpc.vpc_id}", StepID: "vpc", Output: "vpc_id"},
+		},
+	}, analysis.References)
+	assert.Equal(t, []ValidationProblem{
+		{Field: "max_parallelism", Message: "negative max_parallelism is treated as unlimited"},
+		{StepID: "vpc", Field: "workspace", Message: "terraform steps need a workspace"},
+		{StepID: "subnet", Field: "depends_on", Message: `"vpc" is listed more than once`},
+		{StepID: "subnet", Field: "workspace", Message: "terraform steps need a workspace"},
+	}, analysis.Warnings)
+}
+
+func TestAnalyzeWorkflowInputInvalid(t *testing.T) {
+	analysis := AnalyzeWorkflowInput(WorkflowInput{Steps: []models.Step{
+		{ID: "vpc", Executor: "terraform", Workspace: "resources/does-not-exist", DependsOn: []string{"subnet"}},
+		{ID: "subnet", Executor: "terraform", Workspace: "resources/does-not-exist", DependsOn: []string{"vpc"}},
+	}})
+
+	assert.False(t, analysis.Valid)
+	assert.Equal(t, []ValidationProblem{
+		{StepID: "vpc", Field: "depends_on", Message: "dependency cycle: vpc -> subnet -> vpc"},
+	}, analysis.Problems)
+	assert.Equal(t, [][]string{}, analysis.ExecutionOrder)
+	assert.Equal(t, []ValidationProblem{
+		{StepID: "vpc", Field: "workspace", Message: "workspace resources/does-not-exist is not present on this host"},
+		{StepID: "subnet", Field: "workspace", Message: "workspace resources/does-not-exist is not present on this host"},
+	}, analysis.Warnings)
+}
@@ -2,16 +2,22 @@ package workflows
 
 import (
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/surajsub/temporal-rest-dsl/activities"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/executors"
 	"github.com/surajsub/temporal-rest-dsl/models"
 	"go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// acts only names the activities the workflow executes, the worker registers the instance that
+// runs them. Activities are registered under their method names, so the names stay the same as
+// when they were plain functions.
+var acts *activities.Activities
+
 type ActivityInput struct {
 	TaskDescription string         `json:"taskDescription"`
 	Parameters      map[string]any `json:"parameters"`
@@ -22,15 +28,14 @@ type RetrySignal struct {
 	StepID string                 `json:"step_id"`
 	Inputs map[string]interface{} `json:"inputs"`
 	Action string                 `json:"action"`
+	// SentBy is as the caller claims it, see models.RetrySignal
+	SentBy string `json:"sent_by,omitempty"`
 }
 
 type WorkflowState struct {
 	Results map[string]map[string]any
 }
 
-// Regex to match ${dependency.output} placeholders
-var variableRegex = regexp.MustCompile(`\${([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+)}`)
-
 func reverseSteps(steps []models.Step) []models.Step {
 	reversed := make([]models.Step, len(steps))
 	for i, step := range steps {
@@ -39,7 +44,7 @@ func reverseSteps(steps []models.Step) []models.Step {
 	return reversed
 }
 
-func TemporalExecutorWorkflow(ctx workflow.Context, input WorkflowInput) (map[string]map[string]interface{}, error) {
+func TemporalExecutorWorkflow(ctx workflow.Context, input WorkflowInput) (_ map[string]map[string]interface{}, err error) {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting NewTemporalExecutorWorkflow")
 	signalChan := workflow.GetSignalChannel(ctx, "step_control_signal")
@@ -49,7 +54,7 @@ func TemporalExecutorWorkflow(ctx workflow.Context, input WorkflowInput) (map[st
 		Results: make(map[string]map[string]interface{}),
 	}
 
-	stateFile := fmt.Sprintf("%s-%s-%s", input.Project, input.DeploymentId, input.Account)
+	stateKey := db.DeploymentKey{Account: input.Account, Project: input.Project, DeploymentID: input.DeploymentId}
 	retryPolicy := &temporal.RetryPolicy{
 		InitialInterval:    5 * time.Second,
 		BackoffCoefficient: 2.0,
@@ -62,108 +67,396 @@ func TemporalExecutorWorkflow(ctx workflow.Context, input WorkflowInput) (map[st
 		RetryPolicy:         retryPolicy,
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+	// A submission that fails has to say so or it stays RUNNING. Cancellations and rollbacks
+	// record their own status.
+	defer func() {
+		if err == nil || temporal.IsCanceledError(err) || rollbackError(err) {
+			return
+		}
+		ctx, _ := workflow.NewDisconnectedContext(ctx)
+		if statusErr := workflow.ExecuteActivity(ctx, acts.SubmissionStatusActivity, input.SubmissionID, db.SubmissionFailed).Get(ctx, nil); statusErr != nil {
+			logger.Error("Failed to record failed submission", "submissionID", input.SubmissionID, "error", statusErr)
+		}
+	}()
+	// Deletes and updates work off the state saved by the create. Creates load it too, their save
+	// is checked against the version they started from.
+	var saved db.DeploymentState
+	if input.Action == "create" || input.Action == "update" || input.Action == "delete" {
+		logger.Info("Loading state for " + input.Action)
+		if err := workflow.ExecuteActivity(ctx, acts.LoadStateActivity, stateKey).Get(ctx, &saved); err != nil {
+			return nil, fmt.Errorf("failed to load state for %s: %w", input.Action, err)
+		}
+	}
+	// A create would overwrite the outputs the deployment's resources were built from
+	if input.Action == "create" && saved.Status == db.StateActive {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("deployment %s already exists, submit action: update to change it", stateKey), ErrDeploymentExists, nil)
+	}
+	if input.Action == "delete" || input.Action == "update" {
+		if saved.Status != db.StateActive {
+			return nil, fmt.Errorf("deployment %s has no state to %s", stateKey, input.Action)
+		}
+		results, err := saved.Outputs()
+		if err != nil {
+			return nil, err
+		}
+		state.Results = results
+	}
+	// From here on the submission changes the deployment, plans only look at it
+	if input.Action != "plan" {
+		defer func() { recordDeployment(ctx, input, err) }()
+	}
 	// Handle delete order
 	if input.Action == "delete" {
-		logger.Info("Loading state for delete")
-		if err := workflow.ExecuteActivity(ctx, activities.LoadStateFromStorage, stateFile).Get(ctx, &state.Results); err != nil {
-			return nil, fmt.Errorf("failed to load state for delete: %w", err)
-		}
 		input.Steps = reverseSteps(input.Steps)
 	}
 
-	// placeholder for the steps
-	//
-	pendingSteps := input.Steps
-	completedSteps := make(map[string]bool)
+	waitsOn := stepDependencies(input.Steps, input.Action == "delete")
+	runner := &stepRunner{
+		input:          input,
+		logger:         logger,
+		results:        state.Results,
+		waitsOn:        waitsOn,
+		skipped:        make(map[string]bool),
+		awaiting:       make(map[string]workflow.Channel),
+		approvals:      make(map[string]workflow.Channel),
+		earlyApprovals: make(map[string]models.ApprovalSignal),
+		prior:          make(map[string]map[string]any, len(state.Results)),
+	}
+	for id, result := range state.Results {
+		runner.prior[id] = result
+	}
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		runner.dispatchSignals(ctx, signalChan)
+	})
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		runner.dispatchApprovals(ctx, workflow.GetSignalChannel(ctx, ApprovalSignalName))
+	})
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		runner.watchCancellation(ctx, workflow.GetSignalChannel(ctx, CancelSignalName))
+	})
+
+	var completed []models.Step
+	scheduler := &stepScheduler{
+		waitsOn:        waitsOn,
+		maxParallelism: input.MaxParallelism,
+		prepare: func(step models.Step) models.Step {
+			logger.Info("[** Executing Step **]", "[** step ID **]", step.ID)
+			return prepareStepWithContext(step, input)
+		},
+		run: runner.executeStep,
+		handle: func(outcome stepOutcome) (bool, error) {
+			if outcome.Err != nil && !interrupted(ctx, outcome.Err) {
+				return false, outcome.Err
+			}
+			for _, item := range outcome.Items {
+				state.Results[item.Step.ID] = item.Result
+				if !item.Ignored && !item.RollBack {
+					completed = append(completed, item.Step)
+				}
+			}
+			if outcome.Err != nil {
+				// Cancelled while it ran, whatever sub-steps finished are kept for the cleanup
+				return false, nil
+			}
+			if outcome.RollBack {
+				runner.startRollback(outcome.Step.ID)
+				return false, nil
+			}
+			state.Results[outcome.Step.ID] = outcome.Result
+			// A for_each step is rolled back through its sub-steps
+			if !outcome.Ignored && len(outcome.Items) == 0 {
+				completed = append(completed, outcome.Step)
+			}
+			logger.Info("Completed step", "stepID", outcome.Step.ID)
+			return !runner.rollingBack && ctx.Err() == nil, nil
+		},
+	}
+	if _, err := scheduler.Run(ctx, input.Steps); err != nil {
+		return nil, err
+	}
 
-	for len(pendingSteps) > 0 {
-		nextPending := []models.Step{}
+	// A rollback already under way is finished before the cancellation is handled
+	if ctx.Err() != nil && !runner.rollingBack {
+		return nil, runner.cancel(ctx, completed)
+	}
 
-		for _, step := range pendingSteps {
-			if !dependenciesMet(step, completedSteps) {
-				nextPending = append(nextPending, step)
-				continue
-			}
-			logger.Info("[** Executing Step **]", "[** step ID **]", step.ID)
-			step.RoleID = input.RoleID
-			step.SecretId = input.SecretId
-			if input.Action == "create" || input.Action == "delete" {
-				step.Variables = ProcessStepVariables(step, state.Results[step.ID])
+	if runner.rollingBack {
+		return nil, runner.rollback(ctx, completed)
+	}
+	// Every step has finished, a cancellation arriving now must not lose what they did
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+
+	// For updates state.Results started out as the previous state, so steps that were not
+	// part of this submission are carried over
+	switch input.Action {
+	case "create", "update":
+		logger.Info("Saving workflow state")
+		if err := workflow.ExecuteActivity(ctx, acts.SaveStateActivity, stateKey, state.Results, saved.Version, input.SubmissionID).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to save state: %w", err)
+		}
+	case "delete":
+		logger.Info("Deleting workflow state")
+		if err := workflow.ExecuteActivity(ctx, acts.DeleteStateActivity, stateKey, saved.Version, input.SubmissionID).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to delete state: %w", err)
+		}
+	}
+
+	if err := workflow.ExecuteActivity(ctx, acts.SubmissionStatusActivity, input.SubmissionID, db.SubmissionCompleted).Get(ctx, nil); err != nil {
+		return nil, fmt.Errorf("db COMPLETED submission %s: %w", input.SubmissionID, err)
+	}
+
+	logger.Info("Workflow complete")
+	return state.Results, nil
+
+}
+
+// stepRunner holds the state shared between the scheduler loop and the step coroutines.
+type stepRunner struct {
+	input  WorkflowInput
+	logger log.Logger
+	// Results of the steps that have finished so far, shared with the workflow state
+	results map[string]map[string]any
+	// The steps each step waits on, as scheduled
+	waitsOn map[string][]string
+	// Steps skipped because their when: condition was false or a step they wait on was skipped
+	skipped map[string]bool
+	// Steps that fail park on their own channel until an operator signals them
+	awaiting map[string]workflow.Channel
+	// Approval steps wait on their own channel for a decision
+	approvals map[string]workflow.Channel
+	// Decisions that arrived before the approval step started waiting
+	earlyApprovals map[string]models.ApprovalSignal
+	// Set once a failure triggered a rollback, no new steps are started after that
+	rollingBack bool
+	// What the cancel endpoint asked for, read once the workflow is cancelled
+	cancelRequest models.CancelSignal
+	// Step results loaded from storage before the submission started (delete and update)
+	prior map[string]map[string]any
+}
+
+// dispatchSignals routes step_control_signal to whichever parked step it names so
+// that concurrent failures don't steal each other's signals.
+func (r *stepRunner) dispatchSignals(ctx workflow.Context, signalChan workflow.ReceiveChannel) {
+	for {
+		var signal RetrySignal
+		signalChan.Receive(ctx, &signal)
+		ch, ok := r.awaiting[signal.StepID]
+		if !ok {
+			r.logger.Warn("Received signal for a step that is not waiting on one", "stepID", signal.StepID, "action", signal.Action)
+			continue
+		}
+		if !ch.SendAsync(signal) {
+			r.logger.Warn("Step already has a pending signal, dropping", "stepID", signal.StepID, "action", signal.Action)
+		}
+	}
+}
+
+// executeStep runs a single step including its DB bookkeeping. When the step
+// fails it is marked FAILED and then either triggers a rollback or blocks until
+// a retry/ignore/rollback signal for it arrives. Err is only set for bookkeeping
+// failures, which are fatal to the workflow.
+func (r *stepRunner) executeStep(ctx workflow.Context, step models.Step) stepOutcome {
+	stepCtx := workflow.WithValue(ctx, "step", step.ID)
+
+	reason, skip, err := r.skipReason(step)
+	if err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+	if skip {
+		r.logger.Info("Skipping step", "stepID", step.ID, "reason", reason)
+		r.skipped[step.ID] = true
+		result := map[string]any{"status": "skipped", "reason": reason}
+		if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepSkipped, Result: result}); err != nil {
+			return stepOutcome{Step: step, Err: err}
+		}
+		if prior, exists := r.prior[step.ID]; exists && step.Action == "update" {
+			// Keep what is deployed in the saved state, the update just leaves it alone
+			result = prior
+		}
+		return stepOutcome{Step: step, Result: result, Ignored: true}
+	}
+
+	if step.ForEach != nil {
+		return r.executeForEach(stepCtx, step)
+	}
+
+	if step.Type == StepTypeApproval && (step.Action == "delete" || step.Action == "plan") {
+		// Gates only guard changes that create or modify resources
+		result := map[string]any{"status": "skipped", "reason": fmt.Sprintf("approvals are not required for %s", step.Action)}
+		if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepSkipped, Result: result}); err != nil {
+			return stepOutcome{Step: step, Err: err}
+		}
+		return stepOutcome{Step: step, Result: result, Ignored: true}
+	}
+
+	if step.Action == "update" && step.Type != StepTypeApproval && !executors.CanUpdate(step.Executor) {
+		prior, exists := r.prior[step.ID]
+		if exists {
+			// Nothing to re-apply, keep what the previous run produced
+			r.logger.Info("Executor does not support updates, keeping previous result", "stepID", step.ID, "executor", step.Executor)
+			if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepSuccess, Result: prior}); err != nil {
+				return stepOutcome{Step: step, Err: err}
 			}
-			step = PrepareStep(step, input, state.Results)
-			stepCtx := workflow.WithValue(ctx, "step", step.ID)
+			return stepOutcome{Step: step, Result: prior}
+		}
+		// The step is new in this submission
+		step.Action = "create"
+	}
 
-			if err := workflow.ExecuteActivity(stepCtx, activities.DBActivity, step, input.SubmissionID, step.Activity, "STARTED", map[string]any{}).Get(stepCtx, nil); err != nil {
-				return nil, fmt.Errorf("db STARTED step %s: %w", step.ID, err)
+	if step.Action == "plan" && !executors.CanPlan(step.Executor) {
+		r.logger.Info("Executor can not run in plan-only mode, skipping", "stepID", step.ID, "executor", step.Executor)
+		result := map[string]any{"status": "skipped", "reason": fmt.Sprintf("executor %s does not support plan-only runs", step.Executor)}
+		if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepSkipped, Result: result}); err != nil {
+			return stepOutcome{Step: step, Err: err}
+		}
+		return stepOutcome{Step: step, Result: result, Ignored: true}
+	}
+
+	// Resolved only now, a skipped step may reference outputs that were never produced
+	variables, err := interpolateVariables(step.Variables, newInterpolationEnv(r.input, r.results, step.Each))
+	if err != nil {
+		err = fmt.Errorf("step %s: %w", step.ID, err)
+		result := map[string]any{"error": err.Error()}
+		if dbErr := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepFailed, Result: result, Error: err.Error()}); dbErr != nil {
+			r.logger.Error("Failed to record variable failure", "stepID", step.ID, "error", dbErr)
+		}
+		return stepOutcome{Step: step, Err: err}
+	}
+	step.Variables = variables
+
+	if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepStarted}); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+
+	attempt := 1
+	step.Attempt, step.Trigger = attempt, models.TriggerInitial
+	if err := r.startAttempt(stepCtx, step, attempt); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+	result, execErr := r.runStep(stepCtx, step)
+	if interrupted(stepCtx, execErr) {
+		return stepOutcome{Step: step, Err: execErr}
+	}
+	if execErr != nil {
+		r.logger.Error("Deploy Resource Step failed", "stepID", step.ID, "action", step.Action, "error", execErr)
+		if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepFailed, Result: result, Error: execErr.Error(), Attempt: attempt}); err != nil {
+			return stepOutcome{Step: step, Err: err}
+		}
+
+		if r.rollingBack || (r.input.OnFailure == OnFailureRollback && step.Action == "create") {
+			return stepOutcome{Step: step, RollBack: true}
+		}
+
+		// Retries record their own attempts, a failed bookkeeping write fails the attempt so
+		// the step keeps waiting for the operator
+		retry := func(ctx workflow.Context, retryStep models.Step) (map[string]any, error) {
+			attempt++
+			retryStep.Attempt = attempt
+			if err := r.startAttempt(ctx, retryStep, attempt); err != nil {
+				return nil, err
 			}
-			var result map[string]any
-			execErr := workflow.ExecuteActivity(stepCtx, activities.RunActivity, step).Get(stepCtx, &result)
-			if execErr != nil{
-				logger.Info("********** Deploy Resource Step failed..Updating the db with status ***********")
-				if err := workflow.ExecuteActivity(stepCtx, activities.DBActivity, step, input.SubmissionID, step.Activity, "FAILED", result).Get(stepCtx, nil); err != nil {
-					return nil, fmt.Errorf("Failed to update the DB with the right status step %s: %w", step.ID, err)
+			result, err := r.runStep(ctx, retryStep)
+			if err != nil {
+				if dbErr := r.setStatus(ctx, retryStep, activities.StepStatusUpdate{Status: db.StepFailed, Result: result, Error: err.Error(), Attempt: attempt}); dbErr != nil {
+					return nil, dbErr
 				}
-				
+				r.recordWaiting(ctx, retryStep, attempt, err)
 			}
+			return result, err
+		}
 
-			if execErr != nil {
-				logger.Error("[******* Deploy Resource Step failed: %s, %v ", step.Action, execErr)
-				for {
-					var signal RetrySignal
-					signalChan.Receive(ctx, &signal)
-					logger.Info("[**************Received signal ***********]]", "signal", signal)
-
-					if signal.StepID != step.ID {
-						logger.Debug("The signal and step are NOT THE SAME.. WHY")
-						continue
-					}
-
-					switch signal.Action {
-					case "ignore":
-						logger.Info("Step %s ignored via signal", step.ID)
-						result = map[string]any{"message": "Step ignored manually"}
-					case "retry":
-						retryStep := step
-						if signal.Inputs != nil {
-							retryStep.Variables = deepCopy(signal.Inputs)
-						}
-						var retryResult map[string]any
-						retryErr := workflow.ExecuteActivity(stepCtx, activities.RunActivity, retryStep).Get(stepCtx, &retryResult)
-						if retryErr != nil {
-							logger.Error("Retry failed again for step %s: %v", step.ID, retryErr)
-							continue
-						}
-						result = retryResult
-					default:
-						logger.Warn("Unknown signal action received", "action", signal.Action)
-						continue
-					}
-					break
-				}
+		ch := workflow.NewBufferedChannel(ctx, 1)
+		r.awaiting[step.ID] = ch
+		r.recordWaiting(stepCtx, step, attempt, execErr)
+		var action string
+		result, action = handleStepFailureWithSignal(stepCtx, step, ch, retry, r.logger)
+		delete(r.awaiting, step.ID)
+
+		switch action {
+		case "cancel":
+			return stepOutcome{Step: step, Err: stepCtx.Err()}
+		case "rollback":
+			return stepOutcome{Step: step, RollBack: true}
+		case "ignore":
+			if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepIgnored, Result: result, Attempt: attempt}); err != nil {
+				return stepOutcome{Step: step, Err: err}
 			}
+			return stepOutcome{Step: step, Result: result, Ignored: true}
+		}
+	}
+
+	if err := r.setStatus(stepCtx, step, activities.StepStatusUpdate{Status: db.StepSuccess, Result: result, Attempt: attempt}); err != nil {
+		return stepOutcome{Step: step, Err: err}
+	}
+	return stepOutcome{Step: step, Result: result}
+}
 
-			state.Results[step.ID] = result
-			completedSteps[step.ID] = true
+// setStatus records the step's status in submission_steps. The error is fatal to the workflow
+// unless the caller decides otherwise.
+func (r *stepRunner) setStatus(ctx workflow.Context, step models.Step, update activities.StepStatusUpdate) error {
+	if err := workflow.ExecuteActivity(ctx, acts.DBActivity, step, r.input.SubmissionID, update).Get(ctx, nil); err != nil {
+		return fmt.Errorf("db %s step %s: %w", update.Status, step.ID, err)
+	}
+	return nil
+}
 
-			if err := workflow.ExecuteActivity(stepCtx, activities.DBActivity, step, input.SubmissionID, step.Activity, "SUCCESS", result).Get(stepCtx, nil); err != nil {
-				return nil, fmt.Errorf("db SUCCESS step %s: %w", step.ID, err)
-			}
+// recordWaiting tells the submission's event stream that a failed step waits for an operator's
+// retry, ignore or rollback. The event is informational, so a failure to record it is only logged.
+func (r *stepRunner) recordWaiting(ctx workflow.Context, step models.Step, attempt int, stepErr error) {
+	event := db.SubmissionEvent{
+		SubmissionID: r.input.SubmissionID,
+		StepID:       step.ID,
+		Type:         db.EventStepWaiting,
+		Status:       db.StepFailed,
+		Attempt:      attempt,
+		Message:      activities.RedactSecrets(stepErr.Error(), step.Variables),
+	}
+	if err := workflow.ExecuteActivity(ctx, acts.RecordEventActivity, event).Get(ctx, nil); err != nil {
+		r.logger.Warn("Failed to record waiting step", "stepID", step.ID, "error", err)
+	}
+}
 
-			logger.Info("Completed step", "stepID", step.ID)
+// startAttempt records that a run of the step begins. Retries go through RETRYING first, and
+// approval gates record WAITING_APPROVAL themselves.
+func (r *stepRunner) startAttempt(ctx workflow.Context, step models.Step, attempt int) error {
+	if attempt > 1 {
+		if err := r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepRetrying, Attempt: attempt}); err != nil {
+			return err
 		}
-		pendingSteps = nextPending
 	}
+	if step.Type == StepTypeApproval {
+		return nil
+	}
+	return r.setStatus(ctx, step, activities.StepStatusUpdate{Status: db.StepRunning, Attempt: attempt})
+}
 
-	if input.Action == "create" {
-		logger.Info("Saving workflow state")
-		if err := workflow.ExecuteActivity(ctx, activities.SaveStateToStorage, state.Results, stateFile).Get(ctx, nil); err != nil {
-			return nil, fmt.Errorf("failed to save state: %w", err)
+// skipReason decides whether a step is skipped. A step is skipped along with a step it waits on
+// unless it sets if_dependency_skipped: run, and when its when: condition is false.
+func (r *stepRunner) skipReason(step models.Step) (string, bool, error) {
+	if step.IfDependencySkipped != DependencySkippedRun {
+		for _, dep := range r.waitsOn[step.ID] {
+			if r.skipped[dep] {
+				return fmt.Sprintf("step %s was skipped", dep), true, nil
+			}
 		}
 	}
+	if step.When == "" {
+		return "", false, nil
+	}
 
-	logger.Info("Workflow complete")
-	return state.Results, nil
-
+	cond, err := parseCondition(step.When)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid when condition for step %s: %w", step.ID, err)
+	}
+	ok, err := cond.evaluate(conditionEnv{submission: submissionFields(r.input), results: r.results})
+	if err != nil {
+		return "", false, fmt.Errorf("evaluate when condition for step %s: %w", step.ID, err)
+	}
+	if !ok {
+		return fmt.Sprintf("condition %q is false", step.When), true, nil
+	}
+	return "", false, nil
 }
 
 func deepCopy(input map[string]interface{}) map[string]interface{} {
@@ -174,8 +467,8 @@ func deepCopy(input map[string]interface{}) map[string]interface{} {
 	return copy
 }
 
-func dependenciesMet(step models.Step, completed map[string]bool) bool {
-	for _, dep := range step.DependsOn {
+func dependenciesMet(deps []string, completed map[string]bool) bool {
+	for _, dep := range deps {
 		if !completed[dep] {
 			return false
 		}
@@ -183,19 +476,35 @@ func dependenciesMet(step models.Step, completed map[string]bool) bool {
 	return true
 }
 
-func prepareStepWithContext(step models.Step, input WorkflowInput, results map[string]map[string]interface{}) models.Step {
+func prepareStepWithContext(step models.Step, input WorkflowInput) models.Step {
 	step.RoleID = input.RoleID
 	step.SecretId = input.SecretId
 
-	if input.Action == "create" || input.Action == "delete" {
-		step.Variables = ProcessStepVariables(step, results[step.ID])
-	}
+	return PrepareStep(step, input)
+}
 
-	return PrepareStep(step, input, results)
+// runStep does the actual work of a step, either an executor activity or an approval gate
+func (r *stepRunner) runStep(ctx workflow.Context, step models.Step) (map[string]any, error) {
+	if step.Type == StepTypeApproval {
+		return r.awaitApproval(ctx, step)
+	}
+	activityCtx, err := stepActivityOptions(ctx, step)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	err = workflow.ExecuteActivity(activityCtx, acts.RunActivity, step).Get(activityCtx, &result)
+	return result, err
 }
 
-func handleStepFailureWithSignal(ctx workflow.Context, step models.Step, signalChan workflow.ReceiveChannel, logger log.Logger) (map[string]any, error) {
+// handleStepFailureWithSignal blocks until an operator decides what to do with a
+// failed step. It returns the step result together with the action that resolved
+// the failure, "cancel" once the workflow is cancelled.
+func handleStepFailureWithSignal(ctx workflow.Context, step models.Step, signalChan workflow.ReceiveChannel, run func(workflow.Context, models.Step) (map[string]any, error), logger log.Logger) (map[string]any, string) {
 	for {
+		if ctx.Err() != nil {
+			return nil, "cancel"
+		}
 		var signal RetrySignal
 		signalChan.Receive(ctx, &signal)
 		logger.Info("Received signal", "signal", signal)
@@ -206,160 +515,33 @@ func handleStepFailureWithSignal(ctx workflow.Context, step models.Step, signalC
 		}
 
 		switch signal.Action {
+		case "cancel":
+			// Only wakes the step up, the check above ends the wait once the workflow is cancelled
+			continue
 		case "ignore":
-			logger.Info("Step %s ignored via signal", step.ID)
-			return map[string]any{"message": "Step ignored manually"}, nil
+			logger.Info("Step ignored via signal", "stepID", step.ID)
+			return map[string]any{"message": "Step ignored manually"}, signal.Action
 		case "retry":
 			retryStep := step
 			if signal.Inputs != nil {
 				retryStep.Variables = deepCopy(signal.Inputs)
 			}
-			var retryResult map[string]any
-			err := workflow.ExecuteActivity(ctx, activities.RunActivity, retryStep).Get(ctx, &retryResult)
+			retryStep.Trigger, retryStep.TriggeredBy = models.TriggerRetry, signal.SentBy
+			retryResult, err := run(ctx, retryStep)
 			if err != nil {
-				logger.Error("Retry failed again for step %s: %v", step.ID, err)
+				logger.Error("Retry failed again", "stepID", step.ID, "error", err)
+				continue
+			}
+			return retryResult, signal.Action
+		case "rollback":
+			if step.Action != "create" {
+				logger.Warn("Rollback is only supported for create submissions", "stepID", step.ID, "action", step.Action)
 				continue
 			}
-			return retryResult, nil
+			logger.Info("Rollback requested via signal", "stepID", step.ID)
+			return nil, signal.Action
 		default:
 			logger.Warn("Unknown signal action received", "action", signal.Action)
 		}
 	}
 }
-
-// New Function
-//
-
-func LTemporalExecutorWorkflow(ctx workflow.Context, input WorkflowInput) (map[string]map[string]interface{}, error) {
-	logger := workflow.GetLogger(ctx)
-	logger.Info("Starting TemporalExecutorWorkflow")
-
-	signalChan := workflow.GetSignalChannel(ctx, "step_control_signal")
-	//	var signal RetrySignal
-
-	state := WorkflowState{
-		Results: make(map[string]map[string]interface{}),
-	}
-
-	stateFile := fmt.Sprintf("%s-%s-%s", input.Project, input.DeploymentId, input.Account)
-	retryPolicy := &temporal.RetryPolicy{
-		InitialInterval:    5 * time.Second,
-		BackoffCoefficient: 2.0,
-		MaximumInterval:    time.Minute,
-		MaximumAttempts:    5,
-	}
-
-	activityOptions := workflow.ActivityOptions{
-		StartToCloseTimeout: 30 * time.Minute,
-		RetryPolicy:         retryPolicy,
-	}
-	ctx = workflow.WithActivityOptions(ctx, activityOptions)
-
-	// Handle delete order
-	if input.Action == "delete" {
-		logger.Info("Loading state for delete")
-		if err := workflow.ExecuteActivity(ctx, activities.LoadStateFromStorage, stateFile).Get(ctx, &state.Results); err != nil {
-			return nil, fmt.Errorf("failed to load state for delete: %w", err)
-		}
-		input.Steps = reverseSteps(input.Steps)
-	}
-
-	for _, step := range input.Steps {
-
-		logger.Info("Executing step", "stepID", step.ID)
-		// Inject credentials
-		step.RoleID = input.RoleID
-		step.SecretId = input.SecretId
-
-		if input.Action == "create" || input.Action == "delete" {
-			step.Variables = ProcessStepVariables(step, state.Results[step.ID])
-		}
-
-		if err := WaitForDependencies(ctx, step, state.Results); err != nil {
-			return nil, err
-		}
-
-		step = PrepareStep(step, input, state.Results)
-		stepCtx := workflow.WithValue(ctx, "step", step.ID)
-
-		// Track STARTED
-		//future := workflow.ExecuteActivity(ctx workflow.Context, activity interface{}, args ...interface{})
-		if err := workflow.ExecuteActivity(stepCtx, activities.DBActivity, step, input.SubmissionID, step.Activity, "STARTED", map[string]any{}).Get(stepCtx, nil); err != nil {
-			return nil, fmt.Errorf("db STARTED step %s: %w", step.ID, err)
-		}
-
-		var result map[string]any
-
-		execErr := workflow.ExecuteActivity(stepCtx, activities.RunActivity, step).Get(stepCtx, &result)
-
-		if execErr != nil {
-
-			logger.Error("[******* Deploy Resource Step failed: %s, %v ", step.Action, execErr)
-			for {
-				var signal RetrySignal
-				signalChan.Receive(ctx, &signal)
-				logger.Info("[**************Received signal ***********]]", "signal", signal)
-
-				logger.Debug("Received signal for StepID: %s. Current failing StepID: %s", signal.StepID, step.ID)
-
-				logger.Debug("The step that has failed is %s", step.ID)
-				logger.Debug("Step is passed in as the signa is %s", signal.StepID)
-				if signal.StepID != step.ID {
-					logger.Debug("The signal and step are NOT THE SAME.. WHY")
-					continue
-				}
-				logger.Info("Step %s finished", step.Action)
-
-				switch signal.Action {
-				case "ignore":
-					logger.Info("Step %s ignored via signal", step.ID)
-					result = map[string]any{"message": "Step ignored manually"}
-				case "retry":
-
-					logger.Debug("Received signal", "stepID", signal.StepID, "action", signal.Action)
-					retryStep := step
-					logger.Debug("[*******************Signal Inputs are %v ***************]", signal.Inputs)
-					logger.Debug("Step is passed in as the signa is %v", step.Variables)
-					logger.Debug("******** PRINTING THE RETRY STEP ********** %v", retryStep)
-					// Updated code START HERE
-					//retryStep = step
-					if signal.Inputs != nil {
-
-						retryStep.Variables = deepCopy(signal.Inputs)
-					}
-					logger.Debug("[******* RETRYING STEP %s with variables %s", retryStep.ID, retryStep.Variables)
-					var retryResult map[string]any
-					retryErr := workflow.ExecuteActivity(stepCtx, activities.RunActivity, retryStep).Get(stepCtx, &retryResult)
-					if retryErr != nil {
-						logger.Error("Retry failed again for step %s: %v", step.ID, retryErr)
-						continue // Wait for next signal
-					}
-					result = retryResult
-				default:
-					logger.Warn("Unknown signal action received", "action", signal.Action)
-					continue
-				}
-
-				break
-			}
-		}
-
-		state.Results[step.ID] = result
-		// Mark activity as SUCCESS in DB
-		if err := workflow.ExecuteActivity(stepCtx, activities.DBActivity, step, input.SubmissionID, step.Activity, "SUCCESS", result).Get(stepCtx, nil); err != nil {
-			return nil, fmt.Errorf("db SUCCESS step %s: %w", step.ID, err)
-		}
-
-		logger.Info("Completed step", "stepID", step.ID)
-	}
-
-	if input.Action == "create" {
-		logger.Info("Saving workflow state")
-		if err := workflow.ExecuteActivity(ctx, activities.SaveStateToStorage, state.Results, stateFile).Get(ctx, nil); err != nil {
-			return nil, fmt.Errorf("failed to save state: %w", err)
-		}
-	}
-
-	logger.Info("Workflow complete")
-	return state.Results, nil
-}
@@ -0,0 +1,89 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/surajsub/temporal-rest-dsl/db"
+	"github.com/surajsub/temporal-rest-dsl/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCreateOfActiveDeploymentFails(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.OnActivity(acts.LoadStateActivity, mock.Anything, mock.Anything).
+		Return(db.DeploymentState{Version: 3, Status: db.StateActive}, nil)
+	env.OnActivity(acts.SubmissionStatusActivity, mock.Anything, "submission-1", db.SubmissionFailed).
+		Return(nil).Once()
+
+	env.ExecuteWorkflow(TemporalExecutorWorkflow, WorkflowInput{
+		Account:      "acme",
+		Project:      "network",
+		DeploymentId: "vpc",
+		Action:       "create",
+		SubmissionID: "submission-1",
+		Steps:        []models.Step{{ID: "vpc", Executor: "terraform"}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(env.GetWorkflowError(), &appErr))
+	assert.Equal(t, ErrDeploymentExists, appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+	env.AssertExpectations(t)
+}
+
+func TestRollbackDependenciesFanOutToSubSteps(t *testing.T) {
+	graph := []models.Step{
+		{ID: "vpc"},
+		{ID: "subnet", DependsOn: []string{"vpc"}},
+		{ID: "instance", DependsOn: []string{"subnet"}},
+	}
+	steps := []models.Step{
+		{ID: "vpc"},
+		{ID: "subnet[0]", DependsOn: []string{"vpc"}},
+		{ID: "subnet[1]", DependsOn: []string{"vpc"}},
+		{ID: "instance", DependsOn: []string{"subnet"}},
+	}
+
+	waitsOn := rollbackDependencies(graph, steps)
+
+	assert.Equal(t, []string{"instance"}, waitsOn["subnet[0]"])
+	assert.Equal(t, []string{"instance"}, waitsOn["subnet[1]"])
+	assert.Equal(t, []string{"instance", "subnet[0]", "subnet[1]"}, waitsOn["vpc"])
+	assert.Empty(t, waitsOn["instance"])
+	// The steps handed to the rollback keep their own dependencies
+	assert.Equal(t, []string{"subnet"}, steps[3].DependsOn)
+}
+
+func TestRollbackDependenciesThroughStepsNotRolledBack(t *testing.T) {
+	graph := []models.Step{
+		{ID: "a"},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "c", DependsOn: []string{"b"}},
+		{ID: "estimate", DependsOn: []string{"c"}},
+		{ID: "d", DependsOn: []string{"estimate"}},
+	}
+	// b was skipped and the estimate has nothing to tear down, neither is rolled back
+	steps := []models.Step{graph[0], graph[2], graph[4]}
+
+	waitsOn := rollbackDependencies(graph, steps)
+
+	assert.Equal(t, map[string][]string{
+		"a": {"c", "d"},
+		"c": {"d"},
+		"d": nil,
+	}, waitsOn)
+}
+
+func TestRollbackErrors(t *testing.T) {
+	assert.True(t, rollbackError(temporal.NewNonRetryableApplicationError("rolled back", ErrRolledBack, nil)))
+	assert.True(t, rollbackError(temporal.NewNonRetryableApplicationError("rollback failed", ErrRollbackFailed, nil)))
+	assert.False(t, rollbackError(temporal.NewNonRetryableApplicationError("exists", ErrDeploymentExists, nil)))
+	assert.False(t, rollbackError(errors.New("step failed")))
+}